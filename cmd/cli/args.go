@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+)
+
+// argsFlag is a flag.Value which collects protocol specific name=value arguments.
+type argsFlag map[string]interface{}
+
+// String implements flag.Value.
+func (a argsFlag) String() string {
+	args := make([]string, 0, len(a))
+	for k, v := range a {
+		args = append(args, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(args)
+	return strings.Join(args, ",")
+}
+
+// Set implements flag.Value.
+func (a argsFlag) Set(v string) error {
+	parts := strings.SplitN(v, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("invalid argument %q expected name=value", v)
+	}
+	a[parts[0]] = parts[1]
+	return nil
+}
+
+// options returns the client options which set the arguments.
+func (a argsFlag) options() []svrquery.Option {
+	opts := make([]svrquery.Option, 0, len(a))
+	for k, v := range a {
+		opts = append(opts, svrquery.WithArg(k, v))
+	}
+	return opts
+}
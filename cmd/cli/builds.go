@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+)
+
+// buildsCmd implements the builds sub command.
+func buildsCmd(args []string) {
+	fs := flag.NewFlagSet("builds", flag.ExitOnError)
+	cfgFile := fs.String("config", "", "Config file defining servers and groups (default ~/"+defaultConfigFile+")")
+	expect := fs.String("expect", "", "Build servers are expected to report (default the most common build)")
+	jsonOut := fs.Bool("json", false, "Write the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s builds [-config <file>] [-expect <build>] <group>\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Reports the builds of the servers of a group, exiting with 1 if any servers are outliers.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	l := log.New(os.Stderr, "", 0)
+	cfg, err := loadConfig(*cfgFile)
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	targets, err := cfg.group(fs.Arg(0))
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	results, err := queryGroup(targets)
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	br := newBuildReport(results, *expect)
+	if *jsonOut {
+		err = br.writeJSON(os.Stdout)
+	} else {
+		err = br.write(os.Stdout)
+	}
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	if len(br.Outliers) > 0 {
+		os.Exit(1)
+	}
+}
+
+// buildReport is the report of the builds reported by the servers of a
+// fleet.
+type buildReport struct {
+	// Expected is the build servers are expected to report.
+	Expected string `json:"expected"`
+
+	// Builds are the reported builds, most common first.
+	Builds []buildCount `json:"builds"`
+
+	// Outliers are servers which reported a build other than Expected.
+	Outliers []buildServer `json:"outliers"`
+
+	// Unknown are servers which responded without reporting a build.
+	Unknown []buildServer `json:"unknown"`
+
+	// Down are servers which didn't respond.
+	Down []failure `json:"down"`
+}
+
+// buildCount is the servers which reported a build.
+type buildCount struct {
+	Build   string   `json:"build"`
+	Servers []string `json:"servers"`
+}
+
+// buildServer is a server and the build it reported.
+type buildServer struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Build   string `json:"build,omitempty"`
+}
+
+// newBuildReport returns the build report of results. If expected is empty
+// the most common build is expected, ties are broken by the lowest build.
+func newBuildReport(results []poller.Result, expected string) *buildReport {
+	br := &buildReport{
+		Expected: expected,
+		Builds:   []buildCount{},
+		Outliers: []buildServer{},
+		Unknown:  []buildServer{},
+		Down:     []failure{},
+	}
+
+	servers := make(map[string][]string)
+	for _, r := range results {
+		if r.Err != nil {
+			br.Down = append(br.Down, failure{Name: r.Target.Name, Address: r.Target.Address, Error: r.Err.Error()})
+			continue
+		}
+
+		b := r.BuildID()
+		if b == "" {
+			br.Unknown = append(br.Unknown, buildServer{Name: r.Target.Name, Address: r.Target.Address})
+			continue
+		}
+		servers[b] = append(servers[b], r.Target.Name)
+	}
+
+	for b, names := range servers {
+		br.Builds = append(br.Builds, buildCount{Build: b, Servers: names})
+	}
+	sort.Slice(br.Builds, func(i, j int) bool {
+		if len(br.Builds[i].Servers) != len(br.Builds[j].Servers) {
+			return len(br.Builds[i].Servers) > len(br.Builds[j].Servers)
+		}
+		return br.Builds[i].Build < br.Builds[j].Build
+	})
+
+	if br.Expected == "" && len(br.Builds) > 0 {
+		br.Expected = br.Builds[0].Build
+	}
+
+	for _, r := range results {
+		if b := r.BuildID(); r.Err == nil && b != "" && b != br.Expected {
+			br.Outliers = append(br.Outliers, buildServer{Name: r.Target.Name, Address: r.Target.Address, Build: b})
+		}
+	}
+
+	return br
+}
+
+// write writes the report to w in a human readable form.
+func (br *buildReport) write(w io.Writer) error {
+	var servers, expected int
+	for _, bc := range br.Builds {
+		servers += len(bc.Servers)
+		if bc.Build == br.Expected {
+			expected = len(bc.Servers)
+		}
+	}
+
+	build := br.Expected
+	if build == "" {
+		build = "none"
+	}
+	if _, err := fmt.Fprintf(w, "Expected build: %s (%d of %d servers)\n\n", build, expected, servers); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Build\tServers")
+	for _, bc := range br.Builds {
+		fmt.Fprintf(tw, "%s\t%d\n", bc.Build, len(bc.Servers))
+	}
+
+	section := func(title string, n int) {
+		if n > 0 {
+			fmt.Fprintf(tw, "\n%s:\n", title)
+		}
+	}
+
+	section("Outliers", len(br.Outliers))
+	for _, s := range br.Outliers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Name, s.Address, s.Build)
+	}
+
+	section("Unknown build", len(br.Unknown))
+	for _, s := range br.Unknown {
+		fmt.Fprintf(tw, "%s\t%s\n", s.Name, s.Address)
+	}
+
+	section("Down", len(br.Down))
+	for _, f := range br.Down {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Name, f.Address, f.Error)
+	}
+
+	return tw.Flush()
+}
+
+// writeJSON writes the report to w as indented JSON.
+func (br *buildReport) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(br)
+}
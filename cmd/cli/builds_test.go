@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/stretchr/testify/require"
+)
+
+func buildResult(name, build string) poller.Result {
+	return poller.Result{
+		Target:   poller.Target{Name: name, Protocol: "sqp", Address: name + ":12121"},
+		Response: &sqp.QueryResponse{ServerInfo: &sqp.ServerInfoChunk{BuildID: build}},
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	results := []poller.Result{
+		buildResult("eu-1", "1.2.4"),
+		buildResult("eu-2", "1.2.3"),
+		buildResult("eu-3", "1.2.4"),
+		buildResult("eu-4", ""),
+		{Target: poller.Target{Name: "eu-5", Address: "eu-5:12121"}, Err: errors.New("timeout")},
+	}
+
+	br := newBuildReport(results, "")
+	require.Equal(t, "1.2.4", br.Expected)
+	require.Equal(t, []buildCount{
+		{Build: "1.2.4", Servers: []string{"eu-1", "eu-3"}},
+		{Build: "1.2.3", Servers: []string{"eu-2"}},
+	}, br.Builds)
+	require.Equal(t, []buildServer{{Name: "eu-2", Address: "eu-2:12121", Build: "1.2.3"}}, br.Outliers)
+	require.Equal(t, []buildServer{{Name: "eu-4", Address: "eu-4:12121"}}, br.Unknown)
+	require.Equal(t, []failure{{Name: "eu-5", Address: "eu-5:12121", Error: "timeout"}}, br.Down)
+
+	var buf bytes.Buffer
+	require.NoError(t, br.write(&buf))
+	require.Equal(t, `Expected build: 1.2.4 (2 of 3 servers)
+
+Build  Servers
+1.2.4  2
+1.2.3  1
+
+Outliers:
+eu-2  eu-2:12121  1.2.3
+
+Unknown build:
+eu-4  eu-4:12121
+
+Down:
+eu-5  eu-5:12121  timeout
+`, buf.String())
+
+	// An expected build which no server reports makes all outliers.
+	br = newBuildReport(results, "1.2.5")
+	require.Equal(t, "1.2.5", br.Expected)
+	require.Len(t, br.Outliers, 3)
+
+	buf.Reset()
+	require.NoError(t, br.writeJSON(&buf))
+	var v map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &v))
+	require.Equal(t, "1.2.5", v["expected"])
+	require.Len(t, v["outliers"], 3)
+}
+
+func TestBuildReportEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, newBuildReport(nil, "").writeJSON(&buf))
+	require.JSONEq(t, `{"expected":"","builds":[],"outliers":[],"unknown":[],"down":[]}`, buf.String())
+}
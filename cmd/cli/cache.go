@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheEntry is a query result stored in the cache.
+type cacheEntry struct {
+	Time     time.Time       `json:"time"`
+	Protocol string          `json:"protocol"`
+	Address  string          `json:"address"`
+	Response json.RawMessage `json:"response"`
+}
+
+// resultCache stores the results of queries between invocations of the CLI,
+// so scripts can avoid re-querying a server and compare against the previous
+// result.
+type resultCache struct {
+	dir string
+}
+
+// defaultCacheDir returns the default cache directory, svrquery in the user
+// cache directory e.g. ~/.cache/svrquery.
+func defaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "svrquery"), nil
+}
+
+// cacheKey returns the key of the results of querying address using proto
+// with the given arguments, which identify what the result contains.
+func cacheKey(proto, address string, args ...string) string {
+	h := sha256.New()
+	for _, s := range append([]string{proto, address}, args...) {
+		// Null terminate so the boundaries between values are unambiguous.
+		io.WriteString(h, s+"\x00")
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// load returns the entry of key, or nil if there is none.
+func (c resultCache) load(key string) (*cacheEntry, error) {
+	b, err := ioutil.ReadFile(c.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var e cacheEntry
+	if err = json.Unmarshal(b, &e); err != nil {
+		// A corrupt entry is replaced by the next store.
+		return nil, nil
+	}
+	return &e, nil
+}
+
+// store stores e as the entry of key.
+func (c resultCache) store(key string, e cacheEntry) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(c.path(key), 0600, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(e)
+	})
+}
+
+// path returns the path of the file storing the entry of key.
+func (c resultCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// writeDiff writes the differences between the JSON values prev and cur to
+// w, one line per changed leaf value identified by its path, prefixed with -
+// for the previous and + for the current value.
+func writeDiff(w io.Writer, prev, cur []byte) error {
+	pv, err := flattenJSON(prev)
+	if err != nil {
+		return err
+	}
+	cv, err := flattenJSON(cur)
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(pv)+len(cv))
+	for p := range pv {
+		paths = append(paths, p)
+	}
+	for p := range cv {
+		if _, ok := pv[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		o, ok1 := pv[p]
+		n, ok2 := cv[p]
+		if ok1 && ok2 && o == n {
+			continue
+		}
+		if ok1 {
+			if _, err = fmt.Fprintf(w, "- %s: %s\n", p, o); err != nil {
+				return err
+			}
+		}
+		if ok2 {
+			if _, err = fmt.Fprintf(w, "+ %s: %s\n", p, n); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// flattenJSON returns the leaf values of the JSON value b, encoded as JSON,
+// keyed by their dot separated path.
+func flattenJSON(b []byte) (map[string]string, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	leaves := make(map[string]string)
+	var flatten func(path []string, v interface{})
+	flatten = func(path []string, v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, e := range v {
+				flatten(append(path[:len(path):len(path)], k), e)
+			}
+		case []interface{}:
+			for i, e := range v {
+				flatten(append(path[:len(path):len(path)], fmt.Sprint(i)), e)
+			}
+		default:
+			b, _ := json.Marshal(v)
+			leaves[strings.Join(path, ".")] = string(b)
+		}
+	}
+	flatten(nil, v)
+
+	return leaves, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheKey(t *testing.T) {
+	k := cacheKey("sqp", "127.0.0.1:12121", "chunks=2")
+	require.Equal(t, k, cacheKey("sqp", "127.0.0.1:12121", "chunks=2"))
+	require.NotEqual(t, k, cacheKey("sqp", "127.0.0.1:12121", "chunks=3"))
+	require.NotEqual(t, k, cacheKey("tf2e", "127.0.0.1:12121", "chunks=2"))
+
+	// Values can't run into each other.
+	require.NotEqual(t, cacheKey("ab", "c"), cacheKey("a", "bc"))
+}
+
+func TestResultCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rc := resultCache{dir: filepath.Join(dir, "svrquery")}
+	e, err := rc.load("key")
+	require.NoError(t, err)
+	require.Nil(t, e)
+
+	stored := cacheEntry{
+		Time:     time.Date(2021, 5, 4, 12, 0, 0, 0, time.UTC),
+		Protocol: "sqp",
+		Address:  "127.0.0.1:12121",
+		Response: json.RawMessage(`{"version":1}`),
+	}
+	require.NoError(t, rc.store("key", stored))
+
+	e, err = rc.load("key")
+	require.NoError(t, err)
+	require.Equal(t, stored, *e)
+
+	fi, err := os.Stat(rc.path("key"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	// Corrupt entries are ignored.
+	require.NoError(t, ioutil.WriteFile(rc.path("key"), []byte("{"), 0600))
+	e, err = rc.load("key")
+	require.NoError(t, err)
+	require.Nil(t, e)
+}
+
+func TestWriteDiff(t *testing.T) {
+	prev := []byte(`{"server_info":{"current_players":1,"map":"Foundry"},"rules":["a","b"],"removed":true}`)
+	cur := []byte(`{"server_info":{"current_players":2,"map":"Foundry"},"rules":["a","c"],"added":null}`)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeDiff(&buf, prev, cur))
+	require.Equal(t, `+ added: null
+- removed: true
+- rules.1: "b"
++ rules.1: "c"
+- server_info.current_players: 1
++ server_info.current_players: 2
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeDiff(&buf, cur, cur))
+	require.Empty(t, buf.String())
+
+	require.Error(t, writeDiff(&buf, []byte("{"), cur))
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+// protocolStats are the aggregated results of repeatedly querying a server
+// with a protocol.
+type protocolStats struct {
+	Protocol      string
+	Queries       int
+	Errors        map[string]int
+	Latencies     []time.Duration
+	BytesSent     int
+	BytesReceived int
+
+	// Fields is the most populated fields seen in a response.
+	Fields int
+}
+
+// compareCmd implements the compare sub command.
+func compareCmd(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	rounds := fs.Int("rounds", 100, "Number of times to query the server with each protocol")
+	interval := fs.Duration("interval", time.Millisecond*100, "Interval between rounds")
+	timeout := fs.Duration("timeout", svrquery.DefaultTimeout, "Timeout for each query")
+	qargs := make(argsFlag)
+	fs.Var(qargs, "arg", "Protocol specific argument e.g. handshake=cached, can be repeated")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s compare [-rounds <n>] [-interval <duration>] <protocol> <protocol> <host:port>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 3 || *rounds < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	l := log.New(os.Stderr, "", 0)
+	protos, err := parseProtocols(fs.Arg(0) + "," + fs.Arg(1))
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	address := fs.Arg(2)
+	options := append(qargs.options(), svrquery.WithTimeout(*timeout))
+	stats, err := compareProtocols(address, protos, *rounds, *interval, options...)
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	if err = writeComparison(os.Stdout, address, *rounds, stats); err != nil {
+		l.Fatal(err)
+	}
+}
+
+// compareProtocols queries address with each of protos in turn for rounds,
+// waiting interval between each round, so the protocols are compared under
+// the same network and server conditions.
+func compareProtocols(address string, protos []string, rounds int, interval time.Duration, options ...svrquery.Option) ([]*protocolStats, error) {
+	stats := make([]*protocolStats, len(protos))
+	for i, p := range protos {
+		stats[i] = &protocolStats{Protocol: p, Errors: make(map[string]int)}
+	}
+
+	for i := 0; i < rounds; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+
+		for j, p := range protos {
+			results, err := queryGroup([]poller.Target{{Name: p, Protocol: p, Address: address, Options: options}})
+			if err != nil {
+				return nil, err
+			}
+			stats[j].add(results[0])
+		}
+	}
+
+	return stats, nil
+}
+
+// add adds the result r to ps.
+func (ps *protocolStats) add(r poller.Result) {
+	ps.Queries++
+	ps.BytesSent += r.Stats.BytesSent
+	ps.BytesReceived += r.Stats.BytesReceived
+	if r.Err != nil {
+		ps.Errors[errorCategory(r.Err)]++
+		return
+	}
+
+	ps.Latencies = append(ps.Latencies, r.Latency)
+	if n := countFields(r.Response); n > ps.Fields {
+		ps.Fields = n
+	}
+}
+
+// countFields returns the number of populated fields in the JSON
+// representation of resp, which is a measure of the completeness of the data
+// a protocol provides.
+func countFields(resp protocol.Responser) int {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return 0
+	}
+
+	var v interface{}
+	if err = json.Unmarshal(b, &v); err != nil {
+		return 0
+	}
+	return countValues(v)
+}
+
+// countValues returns the number of non-zero leaf values of v.
+func countValues(v interface{}) int {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		var n int
+		for _, e := range v {
+			n += countValues(e)
+		}
+		return n
+	case []interface{}:
+		var n int
+		for _, e := range v {
+			n += countValues(e)
+		}
+		return n
+	case string:
+		if v != "" {
+			return 1
+		}
+	case float64:
+		if v != 0 {
+			return 1
+		}
+	case bool:
+		if v {
+			return 1
+		}
+	}
+	return 0
+}
+
+// writeComparison writes a report comparing stats to w.
+func writeComparison(w io.Writer, address string, rounds int, stats []*protocolStats) error {
+	if _, err := fmt.Fprintf(w, "Comparison of %d queries to %s\n\n", rounds, address); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	row := func(name string, f func(ps *protocolStats) string) {
+		cols := []string{name}
+		for _, ps := range stats {
+			cols = append(cols, f(ps))
+		}
+		fmt.Fprintln(tw, strings.Join(cols, "\t"))
+	}
+	latency := func(f func(sorted []time.Duration) time.Duration) func(ps *protocolStats) string {
+		return func(ps *protocolStats) string {
+			if len(ps.Latencies) == 0 {
+				return "-"
+			}
+			sorted := append([]time.Duration(nil), ps.Latencies...)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			return f(sorted).Round(time.Microsecond).String()
+		}
+	}
+
+	row("", func(ps *protocolStats) string { return ps.Protocol })
+	row("Success", func(ps *protocolStats) string {
+		return fmt.Sprintf("%.1f%%", float64(len(ps.Latencies))*100/float64(ps.Queries))
+	})
+	row("Latency mean", latency(func(sorted []time.Duration) time.Duration {
+		var total time.Duration
+		for _, d := range sorted {
+			total += d
+		}
+		return total / time.Duration(len(sorted))
+	}))
+	row("Latency p50", latency(func(sorted []time.Duration) time.Duration { return percentile(sorted, 50) }))
+	row("Latency p95", latency(func(sorted []time.Duration) time.Duration { return percentile(sorted, 95) }))
+	row("Latency max", latency(func(sorted []time.Duration) time.Duration { return sorted[len(sorted)-1] }))
+	row("Sent/query", func(ps *protocolStats) string { return fmt.Sprintf("%dB", ps.BytesSent/ps.Queries) })
+	row("Received/query", func(ps *protocolStats) string { return fmt.Sprintf("%dB", ps.BytesReceived/ps.Queries) })
+	row("Fields", func(ps *protocolStats) string { return fmt.Sprint(ps.Fields) })
+	row("Errors", func(ps *protocolStats) string {
+		errs := make([]string, 0, len(ps.Errors))
+		for c, n := range ps.Errors {
+			errs = append(errs, fmt.Sprintf("%s=%d", c, n))
+		}
+		if len(errs) == 0 {
+			return "none"
+		}
+		sort.Strings(errs)
+		return strings.Join(errs, ",")
+	})
+
+	return tw.Flush()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteComparison(t *testing.T) {
+	a := &protocolStats{Protocol: "sqp", Errors: make(map[string]int)}
+	b := &protocolStats{Protocol: "tf2e", Errors: make(map[string]int)}
+	for i := 1; i <= 4; i++ {
+		a.add(poller.Result{
+			Latency:  time.Duration(i) * time.Millisecond,
+			Stats:    svrquery.QueryStats{BytesSent: 13, BytesReceived: 40},
+			Response: &sqp.QueryResponse{ServerInfo: &sqp.ServerInfoChunk{CurrentPlayers: 1, MaxPlayers: 2, Map: "Map"}},
+		})
+		b.add(poller.Result{Err: testTimeoutErr{}})
+	}
+	b.add(poller.Result{Err: errors.New("bad")})
+
+	// current_players, max_players and map.
+	require.Equal(t, 3, a.Fields)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeComparison(&buf, "10.0.0.1:12121", 4, []*protocolStats{a, b}))
+	require.Equal(t, `Comparison of 4 queries to 10.0.0.1:12121
+
+                sqp     tf2e
+Success         100.0%  0.0%
+Latency mean    2.5ms   -
+Latency p50     2ms     -
+Latency p95     4ms     -
+Latency max     4ms     -
+Sent/query      13B     0B
+Received/query  40B     0B
+Fields          3       0
+Errors          none    other=1,timeout=4
+`, buf.String())
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultConfigFile is the name of the config file in the users home directory.
+const defaultConfigFile = ".svrquery.yaml"
+
+// serverConfig is the configuration of a named server.
+type serverConfig struct {
+	Protocol string            `yaml:"protocol"`
+	Address  string            `yaml:"address"`
+	Key      string            `yaml:"key"`
+	Timeout  time.Duration     `yaml:"timeout"`
+	Args     map[string]string `yaml:"args"`
+	Schema   string            `yaml:"schema"`
+
+	// MTU is the MTU of the path to the server, see svrquery.WithMTU.
+	MTU int `yaml:"mtu"`
+
+	// DontFragment sets or clears the don't fragment flag of queries, if not
+	// set the operating system setting is used.
+	DontFragment *bool `yaml:"dont_fragment"`
+}
+
+// groupConfig is the configuration of a named group of servers. Protocol,
+// Timeout, Args, Schema, MTU and DontFragment are used as defaults for the
+// servers of the group.
+type groupConfig struct {
+	Servers      []string          `yaml:"servers"`
+	Protocol     string            `yaml:"protocol"`
+	Timeout      time.Duration     `yaml:"timeout"`
+	Args         map[string]string `yaml:"args"`
+	Schema       string            `yaml:"schema"`
+	MTU          int               `yaml:"mtu"`
+	DontFragment *bool             `yaml:"dont_fragment"`
+
+	// Confirmations is the number of consecutive results which must agree
+	// before a server of the group is considered down or up when watched.
+	Confirmations int `yaml:"confirmations"`
+
+	// Derive are fields derived from the responses of the group's servers,
+	// mapping each field name to a text/template, see poller.Deriver.
+	Derive map[string]string `yaml:"derive"`
+
+	// Canaries are servers of the group which are compared to the rest of
+	// the group when watched, reporting drift such as a different build.
+	Canaries []string `yaml:"canaries"`
+}
+
+// canaries returns the canaries of g, each with the servers of g which aren't
+// canaries as its baseline.
+func (g groupConfig) canaries() []poller.Canary {
+	if len(g.Canaries) == 0 {
+		return nil
+	}
+
+	isCanary := make(map[string]bool, len(g.Canaries))
+	for _, c := range g.Canaries {
+		isCanary[c] = true
+	}
+
+	var baseline []string
+	for _, s := range g.Servers {
+		if !isCanary[s] {
+			baseline = append(baseline, s)
+		}
+	}
+
+	canaries := make([]poller.Canary, len(g.Canaries))
+	for i, c := range g.Canaries {
+		canaries[i] = poller.Canary{Name: c, Baseline: baseline}
+	}
+	return canaries
+}
+
+// config is the configuration of named servers, groups and schemas.
+type config struct {
+	Servers map[string]serverConfig  `yaml:"servers"`
+	Groups  map[string]groupConfig   `yaml:"groups"`
+	Schemas map[string]poller.Schema `yaml:"schemas"`
+}
+
+// defaultConfigPath returns the path of the default config file.
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return defaultConfigFile
+	}
+	return filepath.Join(home, defaultConfigFile)
+}
+
+// loadConfig loads and validates the config from file, or the default config
+// file if empty.
+func loadConfig(file string) (*config, error) {
+	if file == "" {
+		file = defaultConfigPath()
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &config{}
+	if err = yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("config %s: %w", file, err)
+	}
+
+	if err = cfg.validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", file, err)
+	}
+
+	return cfg, nil
+}
+
+// validate checks that all servers have an address and all groups reference
+// valid servers which have a protocol, and that referenced schemas exist.
+func (c *config) validate() error {
+	for name, s := range c.Servers {
+		if s.Address == "" {
+			return fmt.Errorf("server %q: no address", name)
+		}
+
+		if _, err := c.schema(s, groupConfig{}); err != nil {
+			return fmt.Errorf("server %q: %w", name, err)
+		}
+	}
+
+	for name, g := range c.Groups {
+		if g.Confirmations < 0 {
+			return fmt.Errorf("group %q: negative confirmations", name)
+		}
+
+		if _, err := c.group(name); err != nil {
+			return err
+		}
+
+		if _, err := poller.NewDeriver(g.Derive); err != nil {
+			return fmt.Errorf("group %q: %w", name, err)
+		}
+
+		if err := g.validateCanaries(); err != nil {
+			return fmt.Errorf("group %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// validateCanaries checks that the canaries of g are servers of g and that
+// at least one server of g isn't a canary.
+func (g groupConfig) validateCanaries() error {
+	servers := make(map[string]bool, len(g.Servers))
+	for _, s := range g.Servers {
+		servers[s] = true
+	}
+
+	for _, c := range g.Canaries {
+		if !servers[c] {
+			return fmt.Errorf("canary %q not in group", c)
+		}
+		delete(servers, c)
+	}
+
+	if len(g.Canaries) > 0 && len(servers) == 0 {
+		return errors.New("no servers which aren't canaries")
+	}
+	return nil
+}
+
+// group returns the poller targets for the servers of group name.
+func (c *config) group(name string) ([]poller.Target, error) {
+	g, ok := c.Groups[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown group %q", name)
+	} else if len(g.Servers) == 0 {
+		return nil, fmt.Errorf("group %q: no servers", name)
+	}
+
+	targets := make([]poller.Target, len(g.Servers))
+	for i, sn := range g.Servers {
+		s, ok := c.Servers[sn]
+		if !ok {
+			return nil, fmt.Errorf("group %q: unknown server %q", name, sn)
+		}
+
+		t, err := s.target(sn, g)
+		if err != nil {
+			return nil, fmt.Errorf("group %q: %w", name, err)
+		}
+
+		if t.Schema, err = c.schema(s, g); err != nil {
+			return nil, fmt.Errorf("group %q: server %q: %w", name, sn, err)
+		}
+		targets[i] = t
+	}
+
+	return targets, nil
+}
+
+// schema returns the schema of server s using the default from g, or nil if
+// it has none.
+func (c *config) schema(s serverConfig, g groupConfig) (poller.Schema, error) {
+	name := s.Schema
+	if name == "" {
+		name = g.Schema
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	sc, ok := c.Schemas[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown schema %q", name)
+	}
+	return sc, nil
+}
+
+// target returns the poller target for the server named name using the
+// defaults from g.
+func (s serverConfig) target(name string, g groupConfig) (poller.Target, error) {
+	t := poller.Target{
+		Name:     name,
+		Protocol: s.Protocol,
+		Address:  s.Address,
+	}
+	if t.Protocol == "" {
+		t.Protocol = g.Protocol
+	}
+	if t.Protocol == "" {
+		return t, fmt.Errorf("server %q: no protocol", name)
+	}
+
+	args := make(argsFlag, len(g.Args)+len(s.Args))
+	for k, v := range g.Args {
+		args[k] = v
+	}
+	for k, v := range s.Args {
+		args[k] = v
+	}
+	t.Options = args.options()
+
+	switch {
+	case s.Timeout > 0:
+		t.Options = append(t.Options, svrquery.WithTimeout(s.Timeout))
+	case g.Timeout > 0:
+		t.Options = append(t.Options, svrquery.WithTimeout(g.Timeout))
+	}
+
+	if s.Key != "" {
+		t.Options = append(t.Options, svrquery.WithKey(s.Key))
+	}
+
+	switch {
+	case s.MTU > 0:
+		t.Options = append(t.Options, svrquery.WithMTU(s.MTU))
+	case g.MTU > 0:
+		t.Options = append(t.Options, svrquery.WithMTU(g.MTU))
+	}
+
+	switch {
+	case s.DontFragment != nil:
+		t.Options = append(t.Options, svrquery.WithDontFragment(*s.DontFragment))
+	case g.DontFragment != nil:
+		t.Options = append(t.Options, svrquery.WithDontFragment(*g.DontFragment))
+	}
+
+	return t, nil
+}
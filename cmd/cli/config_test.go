@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := loadConfig(filepath.Join("testdata", "config.yaml"))
+	require.NoError(t, err)
+
+	targets, err := cfg.group("prod-eu")
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+
+	require.Equal(t, "eu-1", targets[0].Name)
+	require.Equal(t, "sqp", targets[0].Protocol)
+	require.Equal(t, "127.0.0.1:12121", targets[0].Address)
+	require.Len(t, targets[0].Options, 3) // handshake arg, group timeout and group mtu
+
+	require.Equal(t, "eu-2", targets[1].Name)
+	require.Equal(t, "tf2e", targets[1].Protocol)
+	require.Len(t, targets[1].Options, 5) // handshake arg, timeout, key, mtu and dont fragment
+
+	require.Equal(t, 3, cfg.Groups["prod-eu"].Confirmations)
+	require.Contains(t, cfg.Groups["prod-eu"].Derive, "occupancy")
+	require.Equal(t, []string{"gametype", "playlist"}, targets[0].Schema["mode"])
+	require.Equal(t, []poller.Canary{{Name: "eu-2", Baseline: []string{"eu-1"}}}, cfg.Groups["prod-eu"].canaries())
+
+	_, err = cfg.group("unknown")
+	require.Error(t, err)
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	cases := []struct {
+		name   string
+		config string
+	}{
+		{name: "unknown-field", config: "servers:\n  a:\n    addr: 127.0.0.1:1\n"},
+		{name: "no-address", config: "servers:\n  a:\n    protocol: sqp\n"},
+		{name: "no-protocol", config: "servers:\n  a:\n    address: 127.0.0.1:1\ngroups:\n  g:\n    servers: [a]\n"},
+		{name: "unknown-server", config: "groups:\n  g:\n    protocol: sqp\n    servers: [a]\n"},
+		{name: "negative-confirmations", config: "groups:\n  g:\n    confirmations: -1\n"},
+		{name: "no-servers", config: "groups:\n  g:\n    protocol: sqp\n"},
+		{name: "unknown-schema", config: "servers:\n  a:\n    address: 127.0.0.1:1\n    schema: x\n"},
+		{name: "unknown-group-schema", config: "servers:\n  a:\n    address: 127.0.0.1:1\ngroups:\n  g:\n    protocol: sqp\n    schema: x\n    servers: [a]\n"},
+		{name: "unknown-canary", config: "servers:\n  a:\n    address: 127.0.0.1:1\ngroups:\n  g:\n    protocol: sqp\n    servers: [a]\n    canaries: [b]\n"},
+		{name: "all-canaries", config: "servers:\n  a:\n    address: 127.0.0.1:1\ngroups:\n  g:\n    protocol: sqp\n    servers: [a]\n    canaries: [a]\n"},
+		{name: "invalid-derive", config: "servers:\n  a:\n    address: 127.0.0.1:1\ngroups:\n  g:\n    protocol: sqp\n    servers: [a]\n    derive:\n      f: '{{.Name'\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, ioutil.WriteFile(f, []byte(tc.config), 0600))
+			_, err := loadConfig(f)
+			require.Error(t, err)
+		})
+	}
+
+	_, err := loadConfig(filepath.Join("testdata", "missing.yaml"))
+	require.Error(t, err)
+}
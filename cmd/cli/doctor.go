@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/titanfall"
+)
+
+// checkStatus is the outcome of a diagnostic check.
+type checkStatus string
+
+const (
+	statusOK   checkStatus = "OK"
+	statusWarn checkStatus = "WARN"
+	statusFail checkStatus = "FAIL"
+	statusSkip checkStatus = "SKIP"
+)
+
+// doctor runs a sequence of diagnostics against a server to determine why
+// queries to it fail.
+type doctor struct {
+	w       io.Writer
+	proto   string
+	addr    string
+	host    string
+	timeout time.Duration
+	options []svrquery.Option
+
+	resp     protocol.Responser
+	warnings int
+	verdict  string
+	results  []checkResult
+}
+
+// checkResult is the result of a diagnostic check.
+type checkResult struct {
+	name   string
+	status checkStatus
+	detail string
+}
+
+// doctorCmd implements the doctor sub command.
+func doctorCmd(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	proto := fs.String("proto", "", "Protocol e.g. sqp, tf2e, tf2e-v7, tf2e-v8, tf2e-auto")
+	timeout := fs.Duration("timeout", svrquery.DefaultTimeout, "Timeout for each network operation")
+	qargs := make(argsFlag)
+	fs.Var(qargs, "arg", "Protocol specific argument e.g. handshake=cached, can be repeated")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s doctor -proto <protocol> <host:port>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 || *proto == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	d := &doctor{
+		w:       os.Stdout,
+		proto:   *proto,
+		addr:    fs.Arg(0),
+		timeout: *timeout,
+		options: append(qargs.options(), svrquery.WithTimeout(*timeout)),
+	}
+	if !d.run() {
+		os.Exit(1)
+	}
+}
+
+// run runs all checks, stopping at the first failure, and prints a verdict.
+// It returns true if no check failed.
+func (d *doctor) run() bool {
+	checks := []struct {
+		name string
+		f    func() (checkStatus, string)
+	}{
+		{name: "address", f: d.checkAddress},
+		{name: "protocol", f: d.checkProtocol},
+		{name: "dns", f: d.checkDNS},
+		{name: "reachable", f: d.checkReachable},
+		{name: "challenge", f: d.checkChallenge},
+		{name: "query", f: d.checkQuery},
+		{name: "mtu", f: d.checkMTU},
+		{name: "version", f: d.checkVersion},
+	}
+
+	for _, c := range checks {
+		status, detail := c.f()
+		d.results = append(d.results, checkResult{name: c.name, status: status, detail: detail})
+		fmt.Fprintf(d.w, "[%-4s] %-9s %s\n", status, c.name, detail)
+		switch status {
+		case statusFail:
+			fmt.Fprintf(d.w, "\nVerdict: %s\n", d.verdict)
+			return false
+		case statusWarn:
+			d.warnings++
+		}
+	}
+
+	if d.warnings > 0 {
+		fmt.Fprintf(d.w, "\nVerdict: queries succeed but %d warning(s) need attention\n", d.warnings)
+	} else {
+		fmt.Fprintln(d.w, "\nVerdict: server is healthy")
+	}
+	return true
+}
+
+// fail records verdict as the reason for failure and returns a failed status.
+func (d *doctor) fail(verdict, format string, args ...interface{}) (checkStatus, string) {
+	d.verdict = verdict
+	return statusFail, fmt.Sprintf(format, args...)
+}
+
+func (d *doctor) checkAddress() (checkStatus, string) {
+	host, port, err := net.SplitHostPort(d.addr)
+	if err != nil {
+		return d.fail("the address must be in the form host:port, IPv6 addresses must be enclosed in brackets", "%v", err)
+	}
+	d.host = host
+	return statusOK, fmt.Sprintf("host %q port %q", host, port)
+}
+
+func (d *doctor) checkProtocol() (checkStatus, string) {
+	if !protocol.Supported(d.proto) {
+		return d.fail("the protocol is not supported", "unknown protocol %q", d.proto)
+	}
+	return statusOK, d.proto
+}
+
+func (d *doctor) checkDNS() (checkStatus, string) {
+	if net.ParseIP(d.host) != nil {
+		return statusSkip, "host is an IP address"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, d.host)
+	if err != nil {
+		return d.fail("the host name could not be resolved, check it's spelt correctly and DNS is working", "%v", err)
+	}
+	return statusOK, fmt.Sprintf("resolved to %v in %v", addrs, time.Since(start).Round(time.Microsecond))
+}
+
+func (d *doctor) checkReachable() (checkStatus, string) {
+	// ICMP requires elevated privileges, so use TCP which will typically be
+	// actively refused on a query port if the host is up.
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", d.addr, d.timeout)
+	rtt := time.Since(start).Round(time.Microsecond)
+	switch {
+	case err == nil:
+		_ = conn.Close()
+		return statusOK, fmt.Sprintf("TCP connection succeeded in %v", rtt)
+	case isRefused(err):
+		return statusOK, fmt.Sprintf("host responded to TCP in %v", rtt)
+	}
+	return statusWarn, fmt.Sprintf("no TCP response, host may be down or firewalled: %v", err)
+}
+
+func (d *doctor) checkChallenge() (checkStatus, string) {
+	c, err := svrquery.NewClient(d.proto, d.addr, d.options...)
+	if err != nil {
+		return d.fail("the client could not be created", "%v", err)
+	}
+	defer c.Close()
+
+	ch, ok := c.Queryer.(protocol.Challenger)
+	if !ok {
+		return statusSkip, "protocol doesn't use a challenge"
+	}
+
+	start := time.Now()
+	if err := ch.Challenge(); err != nil {
+		return d.fail(d.networkVerdict(err), "%v", err)
+	}
+	return statusOK, fmt.Sprintf("challenge received in %v", time.Since(start).Round(time.Microsecond))
+}
+
+func (d *doctor) checkQuery() (checkStatus, string) {
+	c, err := svrquery.NewClient(d.proto, d.addr, d.options...)
+	if err != nil {
+		return d.fail("the client could not be created", "%v", err)
+	}
+	defer c.Close()
+
+	start := time.Now()
+	if d.resp, err = c.Query(); err != nil {
+		return d.fail(d.networkVerdict(err), "%v", err)
+	}
+	return statusOK, fmt.Sprintf("%d/%d players in %v", d.resp.NumClients(), d.resp.MaxClients(), time.Since(start).Round(time.Microsecond))
+}
+
+func (d *doctor) checkMTU() (checkStatus, string) {
+	if d.proto != "sqp" {
+		return statusSkip, "protocol doesn't support requesting larger responses"
+	}
+
+	c, err := svrquery.NewClient(d.proto, d.addr, d.options...)
+	if err != nil {
+		return d.fail("the client could not be created", "%v", err)
+	}
+	defer c.Close()
+
+	res, err := sqp.ProbeMTU(c)
+	switch {
+	case err != nil:
+		return statusWarn, fmt.Sprintf("probe failed: %v", err)
+	case res.Blackhole:
+		return statusWarn, fmt.Sprintf("responses larger than %d bytes are dropped by the path, limit the server max packet size to %d", res.MaxPacket, res.SuggestedMaxPacketSize)
+	}
+
+	last := res.Steps[len(res.Steps)-1]
+	if last.Err != nil {
+		return statusWarn, fmt.Sprintf("requesting chunks 0x%02x failed: %v", last.Chunks, last.Err)
+	}
+	return statusOK, fmt.Sprintf("full response of %d bytes in %d packet(s) received", last.Bytes, last.Packets)
+}
+
+func (d *doctor) checkVersion() (checkStatus, string) {
+	switch r := d.resp.(type) {
+	case *sqp.QueryResponse:
+		if r.Version != sqp.Version {
+			return statusWarn, fmt.Sprintf("server responded with SQP version %d, client supports %d", r.Version, sqp.Version)
+		} else if ignored := r.IgnoredChunks(); ignored != 0 {
+			return statusWarn, fmt.Sprintf("server ignored requested chunks 0x%02x", ignored)
+		}
+		return statusOK, fmt.Sprintf("SQP version %d", r.Version)
+	case *titanfall.Info:
+		return statusOK, fmt.Sprintf("info version %d", r.Version)
+	}
+	return statusSkip, "protocol has no version"
+}
+
+// networkVerdict returns a verdict explaining the network error err.
+func (d *doctor) networkVerdict(err error) string {
+	switch {
+	case isRefused(err):
+		return "the host is up but nothing is listening on the UDP query port, check the port and that the server is running"
+	case isTimeout(err):
+		return "no response was received, the server may be down, the port or protocol wrong, or UDP blocked by a firewall"
+	}
+	return "the server sent an invalid response, check the protocol is correct"
+}
+
+// isRefused returns true if err indicates the connection was refused.
+func isRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// isTimeout returns true if err is a timeout.
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
+	"github.com/stretchr/testify/require"
+)
+
+// runDoctor runs the doctor against addr and returns whether it passed, the
+// status of each check run and the output.
+func runDoctor(t *testing.T, proto, addr string) (bool, map[string]checkStatus, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	timeout := time.Millisecond * 200
+	d := &doctor{
+		w:       &buf,
+		proto:   proto,
+		addr:    addr,
+		timeout: timeout,
+		options: []svrquery.Option{svrquery.WithTimeout(timeout)},
+	}
+	ok := d.run()
+
+	statuses := make(map[string]checkStatus)
+	for _, r := range d.results {
+		statuses[r.name] = r.status
+	}
+	return ok, statuses, buf.String()
+}
+
+func TestDoctor(t *testing.T) {
+	s, err := server.New(
+		server.WithAddress("127.0.0.1:0"),
+		server.WithProtocol("sqp"),
+		server.WithState(common.QueryState{CurrentPlayers: 1, MaxPlayers: 2, Map: "map"}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	// A UDP port nothing is listening on.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed := pc.LocalAddr().String()
+	require.NoError(t, pc.Close())
+
+	t.Run("healthy", func(t *testing.T) {
+		ok, statuses, out := runDoctor(t, "sqp", s.Addr().String())
+		require.True(t, ok, out)
+		require.Equal(t, map[string]checkStatus{
+			"address":   statusOK,
+			"protocol":  statusOK,
+			"dns":       statusSkip,
+			"reachable": statusOK,
+			"challenge": statusOK,
+			"query":     statusOK,
+			"mtu":       statusOK,
+			"version":   statusOK,
+		}, statuses, out)
+		require.Contains(t, out, "Verdict: server is healthy")
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		ok, statuses, out := runDoctor(t, "sqp", closed)
+		require.False(t, ok, out)
+		require.Equal(t, statusFail, statuses["challenge"], out)
+		require.NotContains(t, statuses, "query")
+		require.Contains(t, out, "nothing is listening on the UDP query port")
+	})
+
+	t.Run("wrong-protocol", func(t *testing.T) {
+		ok, statuses, out := runDoctor(t, "tf2e", s.Addr().String())
+		require.False(t, ok, out)
+		require.Equal(t, statusSkip, statuses["challenge"], out)
+		require.Equal(t, statusFail, statuses["query"], out)
+		require.Contains(t, out, "no response was received")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		ok, statuses, out := runDoctor(t, "sqp", "127.0.0.1")
+		require.False(t, ok, out)
+		require.Equal(t, map[string]checkStatus{"address": statusFail}, statuses)
+
+		ok, statuses, _ = runDoctor(t, "unknown", s.Addr().String())
+		require.False(t, ok)
+		require.Equal(t, statusFail, statuses["protocol"])
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/exec"
+)
+
+// Environment variables configuring the policy of exec protocol adapters,
+// they're not flags so the policy applies to all commands and can be set by
+// operators independently of the servers queried.
+const (
+	execAllowEnv       = "SVRQUERY_EXEC_ALLOW"
+	execTrustedKeysEnv = "SVRQUERY_EXEC_TRUSTED_KEYS"
+	execCleanEnvEnv    = "SVRQUERY_EXEC_CLEAN_ENV"
+)
+
+// execPolicy returns the policy of exec protocol adapters configured by the
+// environment variables returned by getenv.
+func execPolicy(getenv func(string) string) (exec.Policy, error) {
+	var p exec.Policy
+	if v := getenv(execAllowEnv); v != "" {
+		p.Allowed = filepath.SplitList(v)
+	}
+
+	if v := getenv(execTrustedKeysEnv); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+			if err != nil || len(k) != ed25519.PublicKeySize {
+				return p, fmt.Errorf("%s: invalid key %q", execTrustedKeysEnv, s)
+			}
+			p.TrustedKeys = append(p.TrustedKeys, k)
+		}
+	}
+
+	if v := getenv(execCleanEnvEnv); v != "" {
+		var err error
+		if p.CleanEnv, err = strconv.ParseBool(v); err != nil {
+			return p, fmt.Errorf("%s: %w", execCleanEnvEnv, err)
+		}
+	}
+
+	return p, nil
+}
+
+// setExecPolicy sets the policy of exec protocol adapters to be configured by
+// the environment variables returned by getenv. They're only read when an exec
+// client is created, so an invalid policy doesn't affect other protocols.
+func setExecPolicy(getenv func(string) string) {
+	exec.SetPolicyFunc(func() (exec.Policy, error) {
+		return execPolicy(getenv)
+	})
+}
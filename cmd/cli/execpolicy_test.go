@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/exec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecPolicy(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	key := base64.StdEncoding.EncodeToString(pub)
+
+	env := map[string]string{
+		execAllowEnv:       "/usr/local/bin/a:/usr/local/bin/b",
+		execTrustedKeysEnv: key + ", " + key,
+		execCleanEnvEnv:    "true",
+	}
+	p, err := execPolicy(func(k string) string { return env[k] })
+	require.NoError(t, err)
+	require.Equal(t, []string{"/usr/local/bin/a", "/usr/local/bin/b"}, p.Allowed)
+	require.Len(t, p.TrustedKeys, 2)
+	require.Equal(t, ed25519.PublicKey(pub), p.TrustedKeys[0])
+	require.True(t, p.CleanEnv)
+
+	p, err = execPolicy(func(string) string { return "" })
+	require.NoError(t, err)
+	require.Empty(t, p.Allowed)
+	require.Empty(t, p.TrustedKeys)
+	require.False(t, p.CleanEnv)
+
+	for k, v := range map[string]string{execTrustedKeysEnv: "c2hvcnQ=", execCleanEnvEnv: "maybe"} {
+		_, err = execPolicy(func(name string) string {
+			if name == k {
+				return v
+			}
+			return ""
+		})
+		require.Error(t, err, k)
+	}
+}
+
+func TestSetExecPolicy(t *testing.T) {
+	defer exec.SetPolicy(exec.Policy{})
+
+	// An invalid policy only fails exec clients.
+	setExecPolicy(func(k string) string {
+		if k == execCleanEnvEnv {
+			return "maybe"
+		}
+		return ""
+	})
+	_, err := svrquery.NewClient("sqp", "localhost:12121")
+	require.NoError(t, err)
+	_, err = svrquery.NewClient("exec", "localhost:12121", svrquery.WithArg(exec.CommandArg, "adapter"))
+	require.Error(t, err)
+}
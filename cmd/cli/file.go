@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes the data written by write to path with permissions
+// perm. The file is replaced atomically so readers, which may read it at any
+// time, never see partial data.
+func writeFileAtomic(path string, perm os.FileMode, write func(w io.Writer) error) (err error) {
+	// The temporary file is hidden so directory scanners ignore it.
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = write(f); err != nil {
+		return err
+	} else if err = f.Chmod(perm); err != nil {
+		// TempFile creates files only readable by the owner.
+		return err
+	} else if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/metrics"
+)
+
+// serverSelector selects the servers chosen by the dashboard server variable.
+const serverSelector = `{` + metrics.LabelServer + `=~"$server"}`
+
+// grafanaDashboard is a Grafana dashboard.
+type grafanaDashboard struct {
+	Title         string          `json:"title"`
+	Tags          []string        `json:"tags"`
+	SchemaVersion int             `json:"schemaVersion"`
+	Refresh       string          `json:"refresh"`
+	Time          grafanaTime     `json:"time"`
+	Templating    grafanaTemplate `json:"templating"`
+	Panels        []grafanaPanel  `json:"panels"`
+}
+
+// grafanaTime is the default time range of a dashboard.
+type grafanaTime struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// grafanaTemplate holds the variables of a dashboard.
+type grafanaTemplate struct {
+	List []grafanaVariable `json:"list"`
+}
+
+// grafanaVariable is a dashboard variable.
+type grafanaVariable struct {
+	Name       string             `json:"name"`
+	Label      string             `json:"label"`
+	Type       string             `json:"type"`
+	Query      string             `json:"query"`
+	Datasource *grafanaDatasource `json:"datasource,omitempty"`
+	Multi      bool               `json:"multi,omitempty"`
+	IncludeAll bool               `json:"includeAll,omitempty"`
+	Refresh    int                `json:"refresh,omitempty"`
+}
+
+// grafanaDatasource references a data source.
+type grafanaDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// grafanaPanel is a dashboard panel.
+type grafanaPanel struct {
+	ID          int                `json:"id"`
+	Title       string             `json:"title"`
+	Type        string             `json:"type"`
+	Datasource  grafanaDatasource  `json:"datasource"`
+	GridPos     grafanaGridPos     `json:"gridPos"`
+	FieldConfig grafanaFieldConfig `json:"fieldConfig"`
+	Targets     []grafanaTarget    `json:"targets"`
+}
+
+// grafanaGridPos is the position of a panel.
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// grafanaFieldConfig configures the fields of a panel.
+type grafanaFieldConfig struct {
+	Defaults grafanaFieldDefaults `json:"defaults"`
+}
+
+// grafanaFieldDefaults are the default field options of a panel.
+type grafanaFieldDefaults struct {
+	Unit string `json:"unit,omitempty"`
+}
+
+// grafanaTarget is a query of a panel.
+type grafanaTarget struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	Instant      bool   `json:"instant,omitempty"`
+	Format       string `json:"format,omitempty"`
+}
+
+// grafanaCmd implements the grafana-dashboard sub command.
+func grafanaCmd(args []string) {
+	fs := flag.NewFlagSet("grafana-dashboard", flag.ExitOnError)
+	title := fs.String("title", "Game Servers", "Title of the dashboard")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s grafana-dashboard [-title <title>] > dashboard.json\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if err := writeDashboard(os.Stdout, *title); err != nil {
+		log.New(os.Stderr, "", 0).Fatal(err)
+	}
+}
+
+// writeDashboard writes a Grafana dashboard for the exported metrics to w.
+func writeDashboard(w io.Writer, title string) error {
+	b, err := json.MarshalIndent(dashboard(title), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// dashboard returns a Grafana dashboard which displays the exported metrics
+// of the servers selected by its server variable.
+func dashboard(title string) grafanaDashboard {
+	ds := grafanaDatasource{Type: "prometheus", UID: "${datasource}"}
+	legend := "{{" + metrics.LabelServer + "}}"
+	panel := func(title, typ, unit string, pos grafanaGridPos, targets ...grafanaTarget) grafanaPanel {
+		for i := range targets {
+			targets[i].RefID = string(rune('A' + i))
+		}
+		return grafanaPanel{
+			Title:       title,
+			Type:        typ,
+			Datasource:  ds,
+			GridPos:     pos,
+			FieldConfig: grafanaFieldConfig{Defaults: grafanaFieldDefaults{Unit: unit}},
+			Targets:     targets,
+		}
+	}
+
+	panels := []grafanaPanel{
+		panel("Servers up", "stat", "none", grafanaGridPos{H: 4, W: 6, X: 0, Y: 0},
+			grafanaTarget{Expr: "sum(" + metrics.Up.Name + serverSelector + ")"},
+		),
+		panel("Players", "stat", "none", grafanaGridPos{H: 4, W: 6, X: 6, Y: 0},
+			grafanaTarget{Expr: "sum(" + metrics.Players.Name + serverSelector + ")"},
+		),
+		panel("Capacity used", "gauge", "percentunit", grafanaGridPos{H: 4, W: 6, X: 12, Y: 0},
+			grafanaTarget{Expr: "sum(" + metrics.Players.Name + serverSelector + ") / sum(" + metrics.MaxPlayers.Name + serverSelector + ")"},
+		),
+		panel("Availability", "state-timeline", "none", grafanaGridPos{H: 4, W: 6, X: 18, Y: 0},
+			grafanaTarget{Expr: metrics.Up.Name + serverSelector, LegendFormat: legend},
+		),
+		panel("Players by server", "timeseries", "none", grafanaGridPos{H: 8, W: 12, X: 0, Y: 4},
+			grafanaTarget{Expr: metrics.Players.Name + serverSelector, LegendFormat: legend},
+		),
+		panel("Query latency", "timeseries", "s", grafanaGridPos{H: 8, W: 12, X: 12, Y: 4},
+			grafanaTarget{Expr: metrics.QueryDuration.Name + serverSelector, LegendFormat: legend},
+		),
+		panel("Query traffic", "timeseries", "bytes", grafanaGridPos{H: 8, W: 12, X: 0, Y: 12},
+			grafanaTarget{Expr: metrics.BytesSent.Name + serverSelector, LegendFormat: legend + " sent"},
+			grafanaTarget{Expr: metrics.BytesReceived.Name + serverSelector, LegendFormat: legend + " received"},
+		),
+		panel("Current map", "table", "none", grafanaGridPos{H: 8, W: 12, X: 12, Y: 12},
+			grafanaTarget{Expr: metrics.MapInfo.Name + serverSelector, Instant: true, Format: "table"},
+		),
+	}
+	for i := range panels {
+		panels[i].ID = i + 1
+	}
+
+	return grafanaDashboard{
+		Title:         title,
+		Tags:          []string{"svrquery"},
+		SchemaVersion: 36,
+		Refresh:       "30s",
+		Time:          grafanaTime{From: "now-6h", To: "now"},
+		Templating: grafanaTemplate{List: []grafanaVariable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+			{
+				Name:       metrics.LabelServer,
+				Label:      "Server",
+				Type:       "query",
+				Query:      "label_values(" + metrics.Up.Name + ", " + metrics.LabelServer + ")",
+				Datasource: &ds,
+				Multi:      true,
+				IncludeAll: true,
+				Refresh:    2,
+			},
+		}},
+		Panels: panels,
+	}
+}
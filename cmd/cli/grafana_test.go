@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDashboard(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeDashboard(&buf, "Test"))
+
+	var d grafanaDashboard
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &d))
+	require.Equal(t, "Test", d.Title)
+
+	// Every exported metric is displayed and every query uses a metric.
+	used := make(map[string]bool)
+	for _, p := range d.Panels {
+		require.NotEmpty(t, p.Targets, p.Title)
+		for _, tgt := range p.Targets {
+			var found bool
+			for _, m := range metrics.All() {
+				if strings.Contains(tgt.Expr, m.Name+"{") {
+					used[m.Name] = true
+					found = true
+				}
+			}
+			require.True(t, found, tgt.Expr)
+		}
+	}
+	require.Len(t, used, len(metrics.All()))
+}
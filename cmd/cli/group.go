@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+// groupResult is the result of querying a server of a group.
+type groupResult struct {
+	Name     string             `json:"name"`
+	Protocol string             `json:"protocol"`
+	Address  string             `json:"address"`
+	Latency  float64            `json:"latency_ms"`
+	Error    string             `json:"error,omitempty"`
+	Response protocol.Responser `json:"response,omitempty"`
+	Fields   map[string]string  `json:"fields,omitempty"`
+	Derived  map[string]string  `json:"derived,omitempty"`
+	// DeriveError is set if the derived fields couldn't be computed.
+	DeriveError string `json:"derive_error,omitempty"`
+	svrquery.QueryStats
+}
+
+// groupCmd implements the group sub command.
+func groupCmd(args []string) {
+	fs := flag.NewFlagSet("group", flag.ExitOnError)
+	cfgFile := fs.String("config", "", "Config file defining servers and groups (default ~/"+defaultConfigFile+")")
+	quiet := fs.Bool("quiet", false, "Don't print the summary footer")
+	playerNames := fs.String("player-names", "keep", playerNamesUsage)
+	textfile := fs.String("textfile", "", "Also write Prometheus metrics of the results to this file for the node_exporter textfile collector")
+	summaryJSON := fs.String("summary-json", "", "Append a single line JSON summary to this file e.g. /dev/fd/3")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s group [-config <file>] [-textfile <file>] <group>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	l := log.New(os.Stderr, "", 0)
+	cfg, err := loadConfig(*cfgFile)
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	targets, err := cfg.group(fs.Arg(0))
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	names, err := nameFilterOptions(*playerNames)
+	if err != nil {
+		l.Fatal(err)
+	}
+	for i := range targets {
+		targets[i].Options = append(targets[i].Options, names...)
+	}
+
+	deriver, err := poller.NewDeriver(cfg.Groups[fs.Arg(0)].Derive)
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	start := time.Now()
+	results, err := queryGroup(targets)
+	if err != nil {
+		l.Fatal(err)
+	}
+	duration := time.Since(start)
+
+	if err = writeGroup(os.Stdout, results, deriver); err != nil {
+		l.Fatal(err)
+	}
+
+	if *textfile != "" {
+		if err = writeTextfile(*textfile, results); err != nil {
+			l.Fatal(err)
+		}
+	}
+
+	s := summarize(results)
+	if !*quiet {
+		// Written to stderr so stdout remains valid JSON.
+		if err = s.write(os.Stderr); err != nil {
+			l.Fatal(err)
+		}
+	}
+
+	if *summaryJSON != "" {
+		if err = appendSummary(*summaryJSON, s, fs.Arg(0), start, duration); err != nil {
+			l.Fatal(err)
+		}
+	}
+}
+
+// appendSummary appends the JSON summary s of querying group to the file at
+// path, creating it if needed. Appending allows path to be a file descriptor
+// such as /dev/fd/3 or a log collecting the summaries of several runs.
+func appendSummary(path string, s summary, group string, start time.Time, duration time.Duration) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err = s.writeJSON(f, group, start, duration); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// queryGroup queries all targets concurrently and returns the results in
+// target order.
+func queryGroup(targets []poller.Target) ([]poller.Result, error) {
+	results := make([]poller.Result, 0, len(targets))
+	p, err := poller.New(
+		poller.WithTargets(targets...),
+		poller.WithHandler(func(r poller.Result) {
+			results = append(results, r)
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.Poll()
+
+	return results, nil
+}
+
+// newGroupResult returns the groupResult for r.
+func newGroupResult(r poller.Result) groupResult {
+	gr := groupResult{
+		Name:       r.Target.Name,
+		Protocol:   r.Target.Protocol,
+		Address:    r.Target.Address,
+		Latency:    float64(r.Latency) / float64(time.Millisecond),
+		QueryStats: r.Stats,
+	}
+	if r.Err != nil {
+		gr.Error = r.Err.Error()
+	} else {
+		gr.Response = r.Response
+		gr.Fields = r.Fields
+	}
+	return gr
+}
+
+// writeGroup writes results to w as a JSON array, including the fields
+// derived by d.
+func writeGroup(w io.Writer, results []poller.Result, d *poller.Deriver) error {
+	grs := make([]groupResult, len(results))
+	for i, r := range results {
+		grs[i] = newGroupResult(r)
+		derived, err := d.Derive(r)
+		if err != nil {
+			grs[i].DeriveError = err.Error()
+		}
+		grs[i].Derived = derived
+	}
+
+	b, err := json.MarshalIndent(grs, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
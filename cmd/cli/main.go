@@ -1,27 +1,108 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/multiplay/go-svrquery/lib/svrquery"
-	"github.com/multiplay/go-svrquery/lib/svrsample"
+	"github.com/multiplay/go-svrquery/lib/svrquery/heartbeat"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+	"github.com/multiplay/go-svrquery/lib/svrsample/announce"
 	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/multiplay/go-svrquery/lib/svrsample/fake"
+	sqpsample "github.com/multiplay/go-svrquery/lib/svrsample/protocol/sqp"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
 )
 
+// autoProtocol is the protocol name which detects the protocol of a server.
+const autoProtocol = "auto"
+
 func main() {
-	clientAddr := flag.String("addr", "", "Address to connect to e.g. 127.0.0.1:12345")
-	proto := flag.String("proto", "", "Protocol e.g. sqp, tf2e, tf2e-v7, tf2e-v8")
+	setExecPolicy(os.Getenv)
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "doctor":
+			doctorCmd(os.Args[2:])
+			return
+		case "watch":
+			watchCmd(os.Args[2:])
+			return
+		case "group":
+			groupCmd(os.Args[2:])
+			return
+		case "proxy":
+			proxyCmd(os.Args[2:])
+			return
+		case "compare":
+			compareCmd(os.Args[2:])
+			return
+		case "grafana-dashboard":
+			grafanaCmd(os.Args[2:])
+			return
+		case "spoof-check":
+			spoofCheckCmd(os.Args[2:])
+			return
+		case "builds":
+			buildsCmd(os.Args[2:])
+			return
+		}
+	}
+
+	clientAddr := flag.String("addr", "", "Address to connect to e.g. 127.0.0.1:12345, [2001:db8::1]:12345")
+	proto := flag.String("proto", "", "Protocol e.g. sqp, tf2e, tf2e-v7, tf2e-v8, tf2e-auto, exec, or auto to detect it")
+	protocols := flag.String("protocols", "", "Comma separated protocols to query the address with, merging the results e.g. sqp,tf2e")
 	serverAddr := flag.String("server", "", "Address to start server e.g. 127.0.0.1:12121, :23232")
+	dualStack := flag.Bool("dualstack", false, "Listen on separate IPv4 and IPv6 sockets in server mode")
+	maxPacketSize := flag.Int("max-packet-size", 0, "Max size of sqp response packets in server mode, larger responses are split")
+	fakeState := flag.Bool("fake", false, "Respond with a random realistic state in server mode")
+	seed := flag.Int64("seed", 0, "Seed for the random state generated by -fake, 0 uses a random seed")
+	playerNames := flag.String("player-names", "keep", playerNamesUsage)
+	pacing := flag.Duration("pacing", 0, "Minimum gap between request packets of a query e.g. 5ms")
+	mtu := flag.Int("mtu", 0, "MTU of the path to the server e.g. 1420 for WireGuard tunnels")
+	dontFragment := flag.Bool("dont-fragment", false, "Set (true) or clear (false) the don't fragment flag of queries, by default the OS setting is used (Linux only)")
+	var cache cacheConfig
+	flag.DurationVar(&cache.ttl, "cached", 0, "Use the cached result of a previous query if younger than this instead of querying e.g. 30s")
+	flag.BoolVar(&cache.diff, "diff", false, "Write the differences from the previous cached result to stderr")
+	flag.StringVar(&cache.dir, "cache-dir", "", "Directory of the result cache (default svrquery in the user cache directory)")
+	var ann announceConfig
+	flag.StringVar(&ann.endpoint, "announce", "", "Heartbeat endpoint to register with in server mode e.g. udp://watcher:9080, http://watcher:9080")
+	flag.StringVar(&ann.token, "announce-token", "", "Token to send in heartbeats")
+	flag.StringVar(&ann.name, "announce-name", "", "Name to register as, defaults to the address")
+	flag.DurationVar(&ann.interval, "announce-interval", announce.DefaultInterval, "Interval between heartbeats")
+	args := make(argsFlag)
+	flag.Var(args, "arg", "Protocol specific argument e.g. handshake=cached, can be repeated")
 	flag.Parse()
 
 	l := log.New(os.Stderr, "", 0)
 
+	options, err := nameFilterOptions(*playerNames)
+	if err != nil {
+		l.Fatal(err)
+	}
+	options = append(options, args.options()...)
+	if *pacing > 0 {
+		options = append(options, svrquery.WithPacing(*pacing))
+	}
+	if *mtu > 0 {
+		options = append(options, svrquery.WithMTU(*mtu))
+	}
+	flag.Visit(func(f *flag.Flag) {
+		// Only override the OS setting if the flag is given.
+		if f.Name == "dont-fragment" {
+			options = append(options, svrquery.WithDontFragment(*dontFragment))
+		}
+	})
+
 	if *serverAddr != "" && *clientAddr != "" {
 		bail(l, "Cannot run both a server and a client. Specify either -addr OR -server flags")
 	}
@@ -31,94 +112,257 @@ func main() {
 		if *proto == "" {
 			bail(l, "No protocol provided in client mode")
 		}
-		serverMode(l, *proto, *serverAddr)
+		state, err := sampleState(*fakeState, *seed)
+		if err != nil {
+			l.Fatal(err)
+		}
+		serverMode(l, *proto, *serverAddr, state, *dualStack, *maxPacketSize, ann)
+	case *clientAddr != "" && *protocols != "":
+		if *proto != "" {
+			bail(l, "Specify either -proto OR -protocols")
+		}
+		protocolsMode(l, *protocols, *clientAddr, options...)
 	case *clientAddr != "":
 		if *proto == "" {
 			bail(l, "Protocol required in server mode")
 		}
-		queryMode(l, *proto, *clientAddr)
+		if cache.enabled() {
+			cache.key = cacheKey(*proto, *clientAddr, args.String(), *playerNames)
+		}
+		queryMode(l, *proto, *clientAddr, cache, options...)
 	default:
 		bail(l, "Please supply some options")
 	}
 }
 
-func queryMode(l *log.Logger, proto, address string) {
-	if err := query(proto, address); err != nil {
+// cacheConfig is the configuration of the result cache in query mode.
+type cacheConfig struct {
+	ttl  time.Duration
+	diff bool
+	dir  string
+	key  string
+}
+
+// enabled returns true if the result cache is used.
+func (c cacheConfig) enabled() bool {
+	return c.ttl > 0 || c.diff
+}
+
+func queryMode(l *log.Logger, proto, address string, cache cacheConfig, options ...svrquery.Option) {
+	if !cache.enabled() {
+		b, err := query(proto, address, options...)
+		if err != nil {
+			l.Fatal(err)
+		}
+		fmt.Printf("%s\n", b)
+		return
+	}
+
+	if err := cachedQuery(l, proto, address, cache, options...); err != nil {
 		l.Fatal(err)
 	}
 }
 
-func query(proto, address string) error {
-	c, err := svrquery.NewClient(proto, address)
-	if err != nil {
-		return err
+// cachedQuery writes the result of querying address to stdout, using the
+// cached result if it's younger than the ttl of cache. Otherwise the server
+// is queried, optionally writing the differences from the cached result to
+// stderr, and the result is cached.
+func cachedQuery(l *log.Logger, proto, address string, cache cacheConfig, options ...svrquery.Option) error {
+	if cache.dir == "" {
+		dir, err := defaultCacheDir()
+		if err != nil {
+			return err
+		}
+		cache.dir = dir
 	}
-	defer c.Close()
 
-	r, err := c.Query()
+	rc := resultCache{dir: cache.dir}
+	prev, err := rc.load(cache.key)
 	if err != nil {
 		return err
 	}
 
-	b, err := json.MarshalIndent(r, "", "\t")
+	if prev != nil && cache.ttl > 0 {
+		if age := time.Since(prev.Time); age >= 0 && age <= cache.ttl {
+			l.Printf("Using result cached %v ago", age.Round(time.Millisecond))
+			// Encoding compacts the response, restore its indentation.
+			var buf bytes.Buffer
+			if err = json.Indent(&buf, prev.Response, "", "\t"); err != nil {
+				return err
+			}
+			fmt.Printf("%s\n", buf.Bytes())
+			return nil
+		}
+	}
+
+	b, err := query(proto, address, options...)
 	if err != nil {
 		return err
 	}
 	fmt.Printf("%s\n", b)
-	return nil
+
+	if cache.diff && prev != nil {
+		l.Printf("Changes since %s:", prev.Time.Format(time.RFC3339))
+		if err = writeDiff(os.Stderr, prev.Response, b); err != nil {
+			return err
+		}
+	}
+
+	return rc.store(cache.key, cacheEntry{Time: time.Now(), Protocol: proto, Address: address, Response: b})
 }
 
-func serverMode(l *log.Logger, proto, serverAddr string) {
-	if err := server(l, proto, serverAddr); err != nil {
+// query queries the server at address using proto and returns the response
+// as indented JSON.
+func query(proto, address string, options ...svrquery.Option) ([]byte, error) {
+	var r protocol.Responser
+	if proto == autoProtocol {
+		d, err := svrquery.Detect(address, options...)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Detected protocol %s in %v\n", d.Protocol, d.Latency.Round(time.Microsecond))
+		r = d.Response
+	} else {
+		c, err := svrquery.NewClient(proto, address, options...)
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+
+		if r, err = c.Query(); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.MarshalIndent(r, "", "\t")
+}
+
+// sampleState returns the state the sample server responds with, which is
+// randomly generated using seed if random is true.
+func sampleState(random bool, seed int64) (common.QueryState, error) {
+	if !random {
+		return common.QueryState{
+			CurrentPlayers: 1,
+			MaxPlayers:     2,
+			ServerName:     "Name",
+			GameType:       "Game Type",
+			Map:            "Map",
+			Port:           1000,
+		}, nil
+	}
+
+	var options []fake.Option
+	if seed != 0 {
+		options = append(options, fake.WithSeed(seed))
+	}
+	g, err := fake.New(options...)
+	if err != nil {
+		return common.QueryState{}, err
+	}
+	state := g.State()
+	state.Port = 1000
+	return state, nil
+}
+
+func protocolsMode(l *log.Logger, protocols, address string, options ...svrquery.Option) {
+	protos, err := parseProtocols(protocols)
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	results, err := queryProtocols(address, protos, options...)
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	if err = writeProtocols(os.Stdout, address, results); err != nil {
 		l.Fatal(err)
 	}
 }
 
-func server(l *log.Logger, proto, address string) error {
+// announceConfig is the configuration of the heartbeats sent in server mode.
+type announceConfig struct {
+	endpoint string
+	token    string
+	name     string
+	interval time.Duration
+}
+
+func serverMode(l *log.Logger, proto, serverAddr string, state common.QueryState, dualStack bool, maxPacketSize int, ann announceConfig) {
+	if err := serve(l, proto, serverAddr, state, dualStack, maxPacketSize, ann); err != nil {
+		l.Fatal(err)
+	}
+}
+
+func serve(l *log.Logger, proto, address string, state common.QueryState, dualStack bool, maxPacketSize int, ann announceConfig) error {
 	l.Printf("Starting sample server using protocol %s on %s", proto, address)
-	responder, err := svrsample.GetResponder(proto, common.QueryState{
-		CurrentPlayers: 1,
-		MaxPlayers:     2,
-		ServerName:     "Name",
-		GameType:       "Game Type",
-		Map:            "Map",
-		Port:           1000,
-	})
+	options := []server.Option{
+		server.WithAddress(address),
+		server.WithLogger(l),
+	}
+
+	if maxPacketSize > 0 {
+		if proto != "sqp" {
+			return fmt.Errorf("max packet size not supported by protocol %s", proto)
+		}
+		r, err := sqpsample.NewQueryResponder(state, sqpsample.WithMaxPacketSize(maxPacketSize))
+		if err != nil {
+			return err
+		}
+		options = append(options, server.WithResponder(r))
+	} else {
+		options = append(options, server.WithProtocol(proto), server.WithState(state))
+	}
+	if dualStack {
+		options = append(options, server.WithDualStack())
+	}
 
-	addr, err := net.ResolveUDPAddr("udp4", address)
+	s, err := server.New(options...)
 	if err != nil {
 		return err
 	}
 
-	conn, err := net.ListenUDP("udp4", addr)
-	if err != nil {
+	if err = s.Start(context.Background()); err != nil {
 		return err
 	}
 
-	for {
-		buf := make([]byte, 16)
-		_, to, err := conn.ReadFromUDP(buf)
+	if ann.endpoint != "" {
+		// The host of the address may be empty, in which case the endpoint
+		// uses the address heartbeats are sent from.
+		host, _, err := net.SplitHostPort(address)
 		if err != nil {
-			l.Println("read from udp", err)
-			continue
+			return err
 		}
-
-		resp, err := responder.Respond(to.String(), buf)
+		_, port, err := net.SplitHostPort(s.Addr().String())
 		if err != nil {
-			l.Println("error responding to query", err)
-			continue
+			return err
 		}
 
-		if err = conn.SetWriteDeadline(time.Now().Add(1 * time.Second)); err != nil {
-			l.Println("error setting write deadline")
-			continue
+		a, err := announce.New(ann.endpoint, heartbeat.Announcement{
+			Name:     ann.name,
+			Address:  net.JoinHostPort(host, port),
+			Protocol: proto,
+			Token:    ann.token,
+		}, announce.WithInterval(ann.interval), announce.WithLogger(l))
+		if err != nil {
+			return err
 		}
 
-		if _, err = conn.WriteTo(resp, to); err != nil {
-			l.Println("error writing response")
-		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		l.Printf("Announcing to %s every %v", ann.endpoint, ann.interval)
+		go a.Run(ctx)
 	}
 
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+
+	l.Println("Shutting down sample server")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	return s.Shutdown(ctx)
 }
 
 func bail(l *log.Logger, msg string) {
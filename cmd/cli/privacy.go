@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+)
+
+// nameKeyEnv is the environment variable containing the key used to hash
+// player names, it's not a flag so it isn't visible in the process list.
+const nameKeyEnv = "SVRQUERY_NAME_KEY"
+
+// playerNamesUsage is the usage of the -player-names flag.
+const playerNamesUsage = "Player names in results: keep, hash (keyed by $" + nameKeyEnv + ") or redact"
+
+// nameFilterOptions returns the client options which filter player names
+// according to mode.
+func nameFilterOptions(mode string) ([]svrquery.Option, error) {
+	var f svrquery.NameFilter
+	switch mode {
+	case "keep":
+		return nil, nil
+	case "redact":
+		f = svrquery.RedactNames()
+	case "hash":
+		key := os.Getenv(nameKeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("%s must be set to hash player names", nameKeyEnv)
+		}
+		f = svrquery.HashNames([]byte(key))
+	default:
+		return nil, fmt.Errorf("unknown player names mode %q", mode)
+	}
+
+	return []svrquery.Option{svrquery.WithPlayerNameFilter(f)}, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameFilterOptions(t *testing.T) {
+	defer os.Setenv(nameKeyEnv, os.Getenv(nameKeyEnv))
+	require.NoError(t, os.Unsetenv(nameKeyEnv))
+
+	opts, err := nameFilterOptions("keep")
+	require.NoError(t, err)
+	require.Empty(t, opts)
+
+	opts, err = nameFilterOptions("redact")
+	require.NoError(t, err)
+	require.Len(t, opts, 1)
+
+	_, err = nameFilterOptions("hash")
+	require.Error(t, err)
+
+	require.NoError(t, os.Setenv(nameKeyEnv, "secret"))
+	opts, err = nameFilterOptions("hash")
+	require.NoError(t, err)
+	require.Len(t, opts, 1)
+
+	_, err = nameFilterOptions("scramble")
+	require.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+// protocolsResult is the result of querying a server with multiple protocols.
+type protocolsResult struct {
+	Address string          `json:"address"`
+	Merged  *mergedResponse `json:"merged,omitempty"`
+	Results []groupResult   `json:"results"`
+}
+
+// mergedResponse is the merged data of the successful responses.
+type mergedResponse struct {
+	NumClients int64  `json:"num_clients"`
+	MaxClients int64  `json:"max_clients"`
+	Map        string `json:"map,omitempty"`
+
+	// Sources are the protocols each field was taken from.
+	Sources map[string]string `json:"sources"`
+}
+
+// parseProtocols returns the protocols of the comma separated list s.
+func parseProtocols(s string) ([]string, error) {
+	var protos []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		} else if !protocol.Supported(p) {
+			return nil, fmt.Errorf("unknown protocol %q", p)
+		}
+		protos = append(protos, p)
+	}
+
+	if len(protos) == 0 {
+		return nil, fmt.Errorf("no protocols in %q", s)
+	}
+	return protos, nil
+}
+
+// queryProtocols queries address with each of protos concurrently.
+func queryProtocols(address string, protos []string, options ...svrquery.Option) ([]poller.Result, error) {
+	targets := make([]poller.Target, len(protos))
+	for i, p := range protos {
+		targets[i] = poller.Target{Name: p, Protocol: p, Address: address, Options: options}
+	}
+	return queryGroup(targets)
+}
+
+// mergeResults returns the result of querying address with results. Fields
+// of the merged response are taken from the first successful response, in
+// protocol order, which provides them.
+func mergeResults(address string, results []poller.Result) protocolsResult {
+	pr := protocolsResult{Address: address, Results: make([]groupResult, len(results))}
+	for i, r := range results {
+		pr.Results[i] = newGroupResult(r)
+		if r.Err != nil {
+			continue
+		}
+
+		if pr.Merged == nil {
+			pr.Merged = &mergedResponse{
+				NumClients: r.Response.NumClients(),
+				MaxClients: r.Response.MaxClients(),
+				Sources: map[string]string{
+					"num_clients": r.Target.Protocol,
+					"max_clients": r.Target.Protocol,
+				},
+			}
+		}
+
+		if m, ok := r.Response.(protocol.Mapper); ok && pr.Merged.Map == "" && m.MapName() != "" {
+			pr.Merged.Map = m.MapName()
+			pr.Merged.Sources["map"] = r.Target.Protocol
+		}
+	}
+	return pr
+}
+
+// writeProtocols writes the merged result of querying address to w as JSON
+// and returns an error if all protocols failed.
+func writeProtocols(w io.Writer, address string, results []poller.Result) error {
+	pr := mergeResults(address, results)
+	b, err := json.MarshalIndent(pr, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if _, err = fmt.Fprintf(w, "%s\n", b); err != nil {
+		return err
+	} else if pr.Merged == nil {
+		return fmt.Errorf("all %d protocols failed", len(results))
+	}
+	return nil
+}
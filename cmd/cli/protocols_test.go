@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/titanfall"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProtocols(t *testing.T) {
+	protos, err := parseProtocols("sqp, tf2e,")
+	require.NoError(t, err)
+	require.Equal(t, []string{"sqp", "tf2e"}, protos)
+
+	_, err = parseProtocols("sqp,unknown")
+	require.Error(t, err)
+
+	_, err = parseProtocols(",")
+	require.Error(t, err)
+}
+
+func TestMergeResults(t *testing.T) {
+	results := []poller.Result{
+		{Target: poller.Target{Protocol: "tf2e-v8"}, Err: errors.New("timeout")},
+		{
+			Target:   poller.Target{Protocol: "sqp"},
+			Latency:  time.Millisecond,
+			Response: &sqp.QueryResponse{ServerInfo: &sqp.ServerInfoChunk{CurrentPlayers: 2, MaxPlayers: 8}},
+		},
+		{
+			Target:   poller.Target{Protocol: "tf2e"},
+			Latency:  time.Millisecond * 2,
+			Response: &titanfall.Info{BasicInfo: titanfall.BasicInfo{NumClients: 3, MaxClients: 8, Map: "Harbour"}},
+		},
+	}
+
+	pr := mergeResults("10.0.0.1:12121", results)
+	require.Len(t, pr.Results, 3)
+	require.Equal(t, "timeout", pr.Results[0].Error)
+	require.Equal(t, float64(1), pr.Results[1].Latency)
+	require.Equal(t, &mergedResponse{
+		NumClients: 2,
+		MaxClients: 8,
+		Map:        "Harbour",
+		Sources: map[string]string{
+			"num_clients": "sqp",
+			"max_clients": "sqp",
+			"map":         "tf2e",
+		},
+	}, pr.Merged)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeProtocols(&buf, "10.0.0.1:12121", results))
+	require.Error(t, writeProtocols(&buf, "10.0.0.1:12121", results[:1]))
+}
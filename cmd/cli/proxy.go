@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrsample/multi"
+	"github.com/multiplay/go-svrquery/lib/svrsample/proxy"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
+)
+
+// proxyCmd implements the proxy sub command.
+func proxyCmd(args []string) {
+	fs := flag.NewFlagSet("proxy", flag.ExitOnError)
+	ttl := fs.Duration("cache-ttl", proxy.DefaultCacheTTL, "Time the state of each game server is cached for")
+	timeout := fs.Duration("timeout", svrquery.DefaultTimeout, "Timeout for queries to game servers")
+	rate := fs.Float64("rate", 0, "Requests per second allowed from each client host, 0 disables rate limiting")
+	burst := fs.Int("burst", 10, "Burst of requests allowed from each client host when rate limiting")
+	playerNames := fs.String("player-names", "keep", playerNamesUsage)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s proxy [-cache-ttl <duration>] [-rate <rps>] <listen>=<backend>...\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "e.g. %s proxy :12121=127.0.0.1:22121 :12122=127.0.0.1:22122\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	names, err := nameFilterOptions(*playerNames)
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	options := []proxy.Option{proxy.WithCacheTTL(*ttl), proxy.WithTimeout(*timeout), proxy.WithQueryOptions(names...)}
+	if *rate > 0 {
+		options = append(options, proxy.WithRateLimit(*rate, *burst))
+	}
+	if err := runProxy(l, fs.Args(), options...); err != nil {
+		l.Fatal(err)
+	}
+}
+
+// runProxy answers queries on each listen address using the state of the
+// game server at the paired backend address, until interrupted.
+func runProxy(l *log.Logger, pairs []string, options ...proxy.Option) error {
+	p, err := proxy.New(multi.PortRoute, options...)
+	if err != nil {
+		return err
+	}
+
+	listen := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid proxy %q, expected <listen>=<backend>", pair)
+		}
+
+		_, port, err := net.SplitHostPort(parts[0])
+		if err != nil {
+			return fmt.Errorf("invalid listen address %q: %w", parts[0], err)
+		}
+
+		if err = p.Add(port, parts[1]); err != nil {
+			return err
+		}
+		listen = append(listen, parts[0])
+	}
+
+	s, err := server.New(server.WithAddresses(listen...), server.WithResponder(p), server.WithLogger(l))
+	if err != nil {
+		return err
+	}
+
+	if err = s.Start(context.Background()); err != nil {
+		return err
+	}
+	l.Printf("Proxying queries for %d server(s)", len(pairs))
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	return s.Shutdown(ctx)
+}
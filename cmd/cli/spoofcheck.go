@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+)
+
+// spoofCheckCmd implements the spoof-check sub command.
+func spoofCheckCmd(args []string) {
+	fs := flag.NewFlagSet("spoof-check", flag.ExitOnError)
+	proto := fs.String("proto", "", "Protocol e.g. sqp, tf2e, tf2e-v7, tf2e-v8, tf2e-auto")
+	local := fs.String("local", "", "Local address to send queries from e.g. 192.0.2.10:0")
+	timeout := fs.Duration("timeout", svrquery.DefaultTimeout, "Timeout for each network operation")
+	qargs := make(argsFlag)
+	fs.Var(qargs, "arg", "Protocol specific argument e.g. handshake=cached, can be repeated")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s spoof-check -proto <protocol> -local <host:port> <host:port>\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "For lab use, checks responses return to the local address from the server.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 || *proto == "" || *local == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	options := append(qargs.options(), svrquery.WithTimeout(*timeout))
+	sc, err := svrquery.CheckSpoofing(*proto, fs.Arg(0), *local, options...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	writeSpoofCheck(os.Stdout, fs.Arg(0), sc)
+	if !sc.OK() {
+		os.Exit(1)
+	}
+}
+
+// writeSpoofCheck writes the result of a spoof check of addr to w.
+func writeSpoofCheck(w io.Writer, addr string, sc *svrquery.SpoofCheck) {
+	fmt.Fprintf(w, "Sent from:  %s\n", sc.LocalAddr)
+	fmt.Fprintf(w, "Server:     %s\n", addr)
+	fmt.Fprintf(w, "Received:   %d packet(s) from the server\n", sc.Stats.PacketsReceived)
+	for _, s := range sc.Strays {
+		fmt.Fprintf(w, "Stray:      packet from %s\n", s)
+	}
+	if sc.Err != nil {
+		fmt.Fprintf(w, "Query:      %v\n", sc.Err)
+	}
+
+	switch {
+	case sc.OK():
+		fmt.Fprintln(w, "PASS responses returned to the local address from the server")
+	case len(sc.Strays) > 0:
+		fmt.Fprintln(w, "FAIL responses returned from an unexpected address, check NAT and anti-spoofing rules")
+	default:
+		fmt.Fprintln(w, "FAIL no response returned to the local address, check routing of the local address")
+	}
+}
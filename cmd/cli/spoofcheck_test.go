@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSpoofCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		sc     svrquery.SpoofCheck
+		expect string
+	}{
+		{
+			name:   "pass",
+			sc:     svrquery.SpoofCheck{LocalAddr: "192.0.2.10:5000", Stats: svrquery.QueryStats{PacketsReceived: 2}},
+			expect: "PASS",
+		},
+		{
+			name:   "stray",
+			sc:     svrquery.SpoofCheck{LocalAddr: "192.0.2.10:5000", Err: errors.New("i/o timeout"), Strays: []string{"198.51.100.1:12121"}},
+			expect: "Stray:      packet from 198.51.100.1:12121\nQuery:      i/o timeout\nFAIL responses returned from an unexpected address",
+		},
+		{
+			name:   "no-response",
+			sc:     svrquery.SpoofCheck{LocalAddr: "192.0.2.10:5000", Err: errors.New("i/o timeout")},
+			expect: "FAIL no response returned",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeSpoofCheck(&buf, "192.0.2.1:12121", &tc.sc)
+			require.Contains(t, buf.String(), "Sent from:  192.0.2.10:5000\nServer:     192.0.2.1:12121\n")
+			require.Contains(t, buf.String(), tc.expect)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+)
+
+// Error categories used in summaries.
+const (
+	errTimeout = "timeout"
+	errRefused = "refused"
+	errOther   = "other"
+)
+
+// summary is the aggregate of the results of a bulk query.
+type summary struct {
+	Servers     int
+	Up          int
+	Down        int
+	Players     int64
+	MaxPlayers  int64
+	MeanLatency time.Duration
+	P95Latency  time.Duration
+	Errors      map[string]int
+	Traffic     map[string]*traffic
+	Failures    []failure
+}
+
+// failure is a server whose query failed.
+type failure struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Error   string `json:"error"`
+}
+
+// traffic is the network traffic of the queries of a protocol.
+type traffic struct {
+	Queries       int
+	BytesSent     int
+	BytesReceived int
+}
+
+// summarize returns the summary of results.
+func summarize(results []poller.Result) summary {
+	s := summary{
+		Servers: len(results),
+		Errors:  make(map[string]int),
+		Traffic: make(map[string]*traffic),
+	}
+
+	latencies := make([]time.Duration, 0, len(results))
+	var total time.Duration
+	for _, r := range results {
+		t, ok := s.Traffic[r.Target.Protocol]
+		if !ok {
+			t = &traffic{}
+			s.Traffic[r.Target.Protocol] = t
+		}
+		t.Queries++
+		t.BytesSent += r.Stats.BytesSent
+		t.BytesReceived += r.Stats.BytesReceived
+
+		if r.Err != nil {
+			s.Down++
+			s.Errors[errorCategory(r.Err)]++
+			s.Failures = append(s.Failures, failure{Name: r.Target.Name, Address: r.Target.Address, Error: r.Err.Error()})
+			continue
+		}
+
+		s.Up++
+		s.Players += r.Response.NumClients()
+		s.MaxPlayers += r.Response.MaxClients()
+		latencies = append(latencies, r.Latency)
+		total += r.Latency
+	}
+
+	if n := len(latencies); n > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		s.MeanLatency = total / time.Duration(n)
+		s.P95Latency = percentile(latencies, 95)
+	}
+
+	return s
+}
+
+// percentile returns the nearest rank p percentile of the sorted values.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// errorCategory returns the summary category of err.
+func errorCategory(err error) string {
+	switch {
+	case isTimeout(err):
+		return errTimeout
+	case isRefused(err):
+		return errRefused
+	}
+	return errOther
+}
+
+// write writes the summary to w as a human readable footer.
+func (s summary) write(w io.Writer) error {
+	errs := make([]string, 0, len(s.Errors))
+	for c, n := range s.Errors {
+		errs = append(errs, fmt.Sprintf("%s=%d", c, n))
+	}
+	sort.Strings(errs)
+	if len(errs) == 0 {
+		errs = append(errs, "none")
+	}
+
+	protos := make([]string, 0, len(s.Traffic))
+	for p := range s.Traffic {
+		protos = append(protos, p)
+	}
+	sort.Strings(protos)
+	traffic := make([]string, len(protos))
+	for i, p := range protos {
+		t := s.Traffic[p]
+		traffic[i] = fmt.Sprintf("%s sent %dB received %dB (%dB/%dB per query)",
+			p, t.BytesSent, t.BytesReceived, t.BytesSent/t.Queries, t.BytesReceived/t.Queries,
+		)
+	}
+	if len(traffic) == 0 {
+		traffic = append(traffic, "none")
+	}
+
+	_, err := fmt.Fprintf(w, "\nServers: %d up, %d down of %d\nPlayers: %d/%d\nLatency: mean %v, p95 %v\nErrors:  %s\nTraffic: %s\n",
+		s.Up, s.Down, s.Servers,
+		s.Players, s.MaxPlayers,
+		s.MeanLatency.Round(time.Microsecond), s.P95Latency.Round(time.Microsecond),
+		strings.Join(errs, ", "),
+		strings.Join(traffic, ", "),
+	)
+	return err
+}
+
+// summaryEvent is the machine readable form of a summary.
+type summaryEvent struct {
+	Event       string         `json:"event"`
+	Group       string         `json:"group"`
+	Time        time.Time      `json:"time"`
+	Duration    float64        `json:"duration_ms"`
+	Servers     int            `json:"servers"`
+	Up          int            `json:"up"`
+	Down        int            `json:"down"`
+	Players     int64          `json:"players"`
+	MaxPlayers  int64          `json:"max_players"`
+	MeanLatency float64        `json:"latency_mean_ms"`
+	P95Latency  float64        `json:"latency_p95_ms"`
+	Errors      map[string]int `json:"errors"`
+	Failures    []failure      `json:"failures"`
+}
+
+// writeJSON writes the summary of querying group, which started at start and
+// took duration, to w as a single line of JSON so CI jobs can parse it
+// without the full results.
+func (s summary) writeJSON(w io.Writer, group string, start time.Time, duration time.Duration) error {
+	e := summaryEvent{
+		Event:       "summary",
+		Group:       group,
+		Time:        start.UTC(),
+		Duration:    float64(duration) / float64(time.Millisecond),
+		Servers:     s.Servers,
+		Up:          s.Up,
+		Down:        s.Down,
+		Players:     s.Players,
+		MaxPlayers:  s.MaxPlayers,
+		MeanLatency: float64(s.MeanLatency) / float64(time.Millisecond),
+		P95Latency:  float64(s.P95Latency) / float64(time.Millisecond),
+		Errors:      s.Errors,
+		Failures:    s.Failures,
+	}
+	if e.Failures == nil {
+		// Always an array so consumers needn't handle null.
+		e.Failures = []failure{}
+	}
+
+	// Encode terminates the line with a newline.
+	return json.NewEncoder(w).Encode(e)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/stretchr/testify/require"
+)
+
+type testTimeoutErr struct{}
+
+func (testTimeoutErr) Error() string   { return "i/o timeout" }
+func (testTimeoutErr) Timeout() bool   { return true }
+func (testTimeoutErr) Temporary() bool { return true }
+
+func TestSummarize(t *testing.T) {
+	var results []poller.Result
+	for i := 1; i <= 20; i++ {
+		results = append(results, poller.Result{
+			Target:  poller.Target{Protocol: "sqp"},
+			Stats:   svrquery.QueryStats{BytesSent: 13, BytesReceived: 35},
+			Latency: time.Duration(i) * time.Millisecond,
+			Response: &sqp.QueryResponse{ServerInfo: &sqp.ServerInfoChunk{
+				CurrentPlayers: 1,
+				MaxPlayers:     10,
+			}},
+		})
+	}
+	results = append(results,
+		poller.Result{Target: poller.Target{Protocol: "tf2e"}, Stats: svrquery.QueryStats{BytesSent: 30}, Err: testTimeoutErr{}},
+		poller.Result{Target: poller.Target{Protocol: "tf2e"}, Err: fmt.Errorf("read: %w", syscall.ECONNREFUSED)},
+		poller.Result{Target: poller.Target{Protocol: "tf2e"}, Err: errors.New("malformed")},
+		poller.Result{Target: poller.Target{Protocol: "tf2e"}, Err: errors.New("malformed")},
+	)
+
+	s := summarize(results)
+	require.Equal(t, 24, s.Servers)
+	require.Equal(t, 20, s.Up)
+	require.Equal(t, 4, s.Down)
+	require.Equal(t, int64(20), s.Players)
+	require.Equal(t, int64(200), s.MaxPlayers)
+	require.Equal(t, time.Microsecond*10500, s.MeanLatency)
+	require.Equal(t, time.Millisecond*19, s.P95Latency)
+	require.Equal(t, map[string]int{errTimeout: 1, errRefused: 1, errOther: 2}, s.Errors)
+
+	var buf bytes.Buffer
+	require.NoError(t, s.write(&buf))
+	require.Equal(t, "\nServers: 20 up, 4 down of 24\nPlayers: 20/200\nLatency: mean 10.5ms, p95 19ms\nErrors:  other=2, refused=1, timeout=1\n"+
+		"Traffic: sqp sent 260B received 700B (13B/35B per query), tf2e sent 30B received 0B (7B/0B per query)\n", buf.String())
+
+	s = summarize(nil)
+	buf.Reset()
+	require.NoError(t, s.write(&buf))
+	require.Contains(t, buf.String(), "Errors:  none")
+	require.Contains(t, buf.String(), "Traffic: none")
+}
+
+func TestSummaryWriteJSON(t *testing.T) {
+	results := []poller.Result{
+		{
+			Target:   poller.Target{Name: "eu-1", Protocol: "sqp", Address: "10.0.0.1:12121"},
+			Latency:  time.Millisecond * 2,
+			Response: &sqp.QueryResponse{ServerInfo: &sqp.ServerInfoChunk{CurrentPlayers: 3, MaxPlayers: 10}},
+		},
+		{
+			Target: poller.Target{Name: "eu-2", Protocol: "sqp", Address: "10.0.0.2:12121"},
+			Err:    testTimeoutErr{},
+		},
+	}
+
+	start := time.Date(2021, 5, 4, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	require.NoError(t, summarize(results).writeJSON(&buf, "prod-eu", start, time.Millisecond*1500))
+	require.Equal(t, `{"event":"summary","group":"prod-eu","time":"2021-05-04T12:00:00Z","duration_ms":1500,"servers":2,"up":1,"down":1,`+
+		`"players":3,"max_players":10,"latency_mean_ms":2,"latency_p95_ms":2,"errors":{"timeout":1},`+
+		`"failures":[{"name":"eu-2","address":"10.0.0.2:12121","error":"i/o timeout"}]}`+"\n", buf.String())
+
+	// Failures are always an array.
+	buf.Reset()
+	require.NoError(t, summarize(nil).writeJSON(&buf, "empty", start, 0))
+	require.Contains(t, buf.String(), `"failures":[]`)
+}
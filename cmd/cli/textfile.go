@@ -0,0 +1,17 @@
+package main
+
+import (
+	"io"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/metrics"
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+)
+
+// writeTextfile writes the metrics of results to path for the node_exporter
+// textfile collector, which only reads files ending in .prom. The collector
+// often runs as another user so the file is world readable.
+func writeTextfile(path string, results []poller.Result) error {
+	return writeFileAtomic(path, 0644, func(w io.Writer) error {
+		return metrics.Write(w, results)
+	})
+}
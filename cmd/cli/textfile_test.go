@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTextfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "textfile")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "svrquery.prom")
+	require.NoError(t, ioutil.WriteFile(path, []byte("stale"), 0644))
+
+	results := []poller.Result{{
+		Target: poller.Target{Name: "eu-1", Protocol: "sqp", Address: "10.0.0.1:12121"},
+		Response: &sqp.QueryResponse{ServerInfo: &sqp.ServerInfoChunk{
+			CurrentPlayers: 3,
+			MaxPlayers:     10,
+		}},
+	}}
+	require.NoError(t, writeTextfile(path, results))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `svrquery_players{server="eu-1",protocol="sqp",address="10.0.0.1:12121"} 3`)
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0644), fi.Mode().Perm())
+
+	// No temporary files are left behind.
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	require.Error(t, writeTextfile(filepath.Join(dir, "missing", "svrquery.prom"), results))
+}
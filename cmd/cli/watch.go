@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/heartbeat"
+	"github.com/multiplay/go-svrquery/lib/svrquery/notify"
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+)
+
+// stringsFlag is a flag.Value which collects repeated string values.
+type stringsFlag []string
+
+// String implements flag.Value.
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set implements flag.Value.
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// logNotifier is a notify.Notifier which logs events.
+type logNotifier struct {
+	l *log.Logger
+}
+
+// Notify implements notify.Notifier.
+func (n logNotifier) Notify(ctx context.Context, e notify.Event) error {
+	n.l.Printf("[%s] %s", e.Type, e.Message())
+	return nil
+}
+
+// watchCmd implements the watch sub command.
+func watchCmd(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	proto := fs.String("proto", "", "Protocol e.g. sqp, tf2e, tf2e-v7, tf2e-v8, tf2e-auto")
+	interval := fs.Duration("interval", poller.DefaultInterval, "Interval between queries")
+	concurrency := fs.Int("concurrency", poller.DefaultConcurrency, "Max number of servers queried at once")
+	timeout := fs.Duration("timeout", svrquery.DefaultTimeout, "Timeout for each query")
+	format := fs.String("webhook-format", string(notify.FormatJSON), "Webhook payload format, one of json, slack or discord")
+	tmpl := fs.String("webhook-template", notify.DefaultTemplate, "Go text/template for slack and discord webhook messages")
+	group := fs.String("group", "", "Group of servers from the config file to watch instead of addresses")
+	cfgFile := fs.String("config", "", "Config file defining servers and groups (default ~/"+defaultConfigFile+")")
+	confirmations := fs.Int("confirmations", 1, "Number of consecutive results which must agree before a server is considered down or up")
+	keepLastGood := fs.Duration("keep-last-good", 0, "Treat failed queries as stale rather than down while the last good response is younger than this")
+	heartbeatUDP := fs.String("heartbeat-udp", "", "UDP address to listen on for server heartbeats e.g. :9080")
+	heartbeatHTTP := fs.String("heartbeat-http", "", "HTTP address to listen on for server heartbeats e.g. :9080")
+	heartbeatTTL := fs.Duration("heartbeat-ttl", heartbeat.DefaultTTL, "Time a server is watched after its last heartbeat")
+	heartbeatMax := fs.Int("heartbeat-max-servers", heartbeat.DefaultMaxTargets, "Max number of servers registered by heartbeats")
+	var webhooks, tokens, allowedHosts stringsFlag
+	fs.Var(&webhooks, "webhook", "URL to post events to, can be repeated")
+	fs.Var(&tokens, "heartbeat-token", "Token servers must send in heartbeats, can be repeated")
+	fs.Var(&allowedHosts, "heartbeat-allow-host", "IP or CIDR network servers may announce addresses in, other than the IP they send heartbeats from, can be repeated")
+	qargs := make(argsFlag)
+	fs.Var(qargs, "arg", "Protocol specific argument e.g. handshake=cached, can be repeated")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s watch -proto <protocol> [-webhook <url>] <host:port>...\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "       %s watch -group <group> [-config <file>] [-webhook <url>]\n", os.Args[0])
+		fmt.Fprintf(fs.Output(), "       %s watch -heartbeat-udp <address> -heartbeat-token <token> [-webhook <url>]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	heartbeats := *heartbeatUDP != "" || *heartbeatHTTP != ""
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if err := checkWatchFlags(set, fs.NArg(), heartbeats); err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	l := log.New(os.Stdout, "", log.LstdFlags)
+	options := append(qargs.options(), svrquery.WithTimeout(*timeout))
+	var targets []poller.Target
+	var canaries []poller.Canary
+	detectorOptions := []notify.DetectorOption{notify.WithConfirmations(*confirmations)}
+	if *group != "" {
+		c, err := loadConfig(*cfgFile)
+		if err != nil {
+			l.Fatal(err)
+		}
+
+		if targets, err = c.group(*group); err != nil {
+			l.Fatal(err)
+		}
+
+		if n := c.Groups[*group].Confirmations; n > 0 {
+			for _, t := range targets {
+				detectorOptions = append(detectorOptions, notify.WithTargetConfirmations(t.Name, n))
+			}
+		}
+		canaries = c.Groups[*group].canaries()
+	} else {
+		for _, a := range fs.Args() {
+			targets = append(targets, poller.Target{Protocol: *proto, Address: a, Options: options})
+		}
+	}
+
+	var registry *heartbeat.Registry
+	if heartbeats {
+		var err error
+		registry, err = heartbeat.New(
+			heartbeat.WithTokens(tokens...),
+			heartbeat.WithTTL(*heartbeatTTL),
+			heartbeat.WithMaxTargets(*heartbeatMax),
+			heartbeat.WithAllowedHosts(allowedHosts...),
+			heartbeat.WithTargetOptions(options...),
+		)
+		if err != nil {
+			l.Fatal(err)
+		}
+	}
+
+	cfg := watchConfig{
+		targets:         targets,
+		canaries:        canaries,
+		registry:        registry,
+		heartbeatUDP:    *heartbeatUDP,
+		heartbeatHTTP:   *heartbeatHTTP,
+		detectorOptions: detectorOptions,
+		interval:        *interval,
+		concurrency:     *concurrency,
+		keepLastGood:    *keepLastGood,
+		webhooks:        webhooks,
+		format:          *format,
+		template:        *tmpl,
+	}
+	if err := watch(l, cfg); err != nil {
+		l.Fatal(err)
+	}
+}
+
+// checkWatchFlags checks the flags which were set, and the number of
+// addresses given, select servers to watch without conflicting.
+func checkWatchFlags(set map[string]bool, addresses int, heartbeats bool) error {
+	switch {
+	case set["group"] && addresses > 0:
+		return errors.New("addresses can't be used with -group")
+	case set["group"] && set["proto"]:
+		return errors.New("-proto can't be used with -group, set the protocol of the group in the config")
+	case set["group"] && !heartbeats && (set["timeout"] || set["arg"]):
+		// Heartbeat targets use them, but group targets are configured by
+		// the config.
+		return errors.New("-timeout and -arg can't be used with -group, set them for the group in the config")
+	case addresses > 0 && !set["proto"]:
+		return errors.New("-proto is required with addresses")
+	case !set["group"] && addresses == 0 && !heartbeats:
+		return errors.New("no servers to watch, give addresses, -group or a heartbeat listener")
+	}
+	return nil
+}
+
+// watchConfig is the configuration of the watch sub command.
+type watchConfig struct {
+	targets         []poller.Target
+	canaries        []poller.Canary
+	registry        *heartbeat.Registry
+	heartbeatUDP    string
+	heartbeatHTTP   string
+	detectorOptions []notify.DetectorOption
+	interval        time.Duration
+	concurrency     int
+	keepLastGood    time.Duration
+	webhooks        []string
+	format          string
+	template        string
+}
+
+// watch polls the configured targets and logs state transitions, posting
+// them to each of the webhooks, until interrupted.
+func watch(l *log.Logger, cfg watchConfig) error {
+	f, err := notify.ParseFormat(cfg.format)
+	if err != nil {
+		return err
+	}
+
+	webhooks := make([]notify.Notifier, len(cfg.webhooks))
+	for i, u := range cfg.webhooks {
+		if webhooks[i], err = notify.NewWebhook(u, notify.WithFormat(f), notify.WithTemplate(cfg.template)); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	errh := func(e notify.Event, err error) {
+		l.Printf("Failed to send %s event for %s: %v", e.Type, e.Name, err)
+	}
+
+	// Webhooks are sent from a queue so slow endpoints don't delay polls.
+	notifiers := []notify.Notifier{logNotifier{l: l}}
+	errc := make(chan error, 4)
+	if len(webhooks) > 0 {
+		q, err := notify.NewQueue(notify.DefaultQueueSize, errh, webhooks...)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, q)
+		go func() { errc <- q.Run(ctx) }()
+	}
+
+	d, err := notify.NewDetector(cfg.detectorOptions...)
+	if err != nil {
+		return err
+	}
+
+	notifyHandler := notify.Handler(ctx, d, errh, notifiers...)
+	options := []poller.Option{
+		poller.WithInterval(cfg.interval),
+		poller.WithConcurrency(cfg.concurrency),
+		// Release the state of servers which are no longer polled, such as
+		// expired heartbeat servers.
+		poller.WithPollHandler(d.Prune),
+		poller.WithTargets(cfg.targets...),
+		poller.WithHandler(func(r poller.Result) {
+			if r.Stale {
+				l.Printf("[stale] %s last responded %v ago: %v", r.Target.Name, r.Age.Round(time.Second), r.Err)
+			}
+			notifyHandler(r)
+		}),
+	}
+	if cfg.keepLastGood > 0 {
+		options = append(options, poller.WithKeepLastGood(cfg.keepLastGood))
+	}
+	if cfg.registry != nil {
+		options = append(options, poller.WithSource(cfg.registry))
+	}
+	if len(cfg.canaries) > 0 {
+		options = append(options, poller.WithCanaries(notify.DriftHandler(ctx, errh, notifiers...), cfg.canaries...))
+	}
+
+	p, err := poller.New(options...)
+	if err != nil {
+		return err
+	}
+
+	if err = serveHeartbeats(ctx, l, cfg, errc); err != nil {
+		return err
+	}
+
+	l.Printf("Watching %d server(s) every %v", len(cfg.targets), cfg.interval)
+	go func() { errc <- p.Run(ctx) }()
+
+	// Stop on the first error, from the poller, queue or a heartbeat listener.
+	if err = <-errc; err != context.Canceled {
+		return err
+	}
+	return nil
+}
+
+// serveHeartbeats starts the heartbeat listeners of cfg, if any, which serve
+// until ctx is done. Listeners which fail send the error to errc.
+func serveHeartbeats(ctx context.Context, l *log.Logger, cfg watchConfig, errc chan<- error) error {
+	errh := func(addr net.Addr, err error) {
+		l.Printf("Invalid heartbeat from %v: %v", addr, err)
+	}
+
+	if cfg.heartbeatUDP != "" {
+		conn, err := net.ListenPacket("udp", cfg.heartbeatUDP)
+		if err != nil {
+			return err
+		}
+
+		l.Printf("Listening for heartbeats on udp %v", conn.LocalAddr())
+		go func() { errc <- cfg.registry.ServePacket(ctx, conn, errh) }()
+	}
+
+	if cfg.heartbeatHTTP != "" {
+		ln, err := net.Listen("tcp", cfg.heartbeatHTTP)
+		if err != nil {
+			return err
+		}
+
+		s := &http.Server{Handler: cfg.registry.Handler(errh), ReadTimeout: time.Second * 10}
+		go func() {
+			<-ctx.Done()
+			s.Close()
+		}()
+
+		l.Printf("Listening for heartbeats on http %v", ln.Addr())
+		go func() {
+			if err := s.Serve(ln); err != http.ErrServerClosed {
+				errc <- err
+			}
+		}()
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckWatchFlags(t *testing.T) {
+	tests := []struct {
+		name       string
+		set        []string
+		addresses  int
+		heartbeats bool
+		err        string
+	}{
+		{name: "addresses", set: []string{"proto", "timeout", "arg"}, addresses: 2},
+		{name: "group", set: []string{"group", "config"}},
+		{name: "heartbeats", heartbeats: true},
+		{name: "group-heartbeats", set: []string{"group", "timeout"}, heartbeats: true},
+		{name: "group-addresses", set: []string{"group"}, addresses: 1, err: "addresses can't be used with -group"},
+		{name: "group-proto", set: []string{"group", "proto"}, err: "-proto can't be used with -group"},
+		{name: "group-timeout", set: []string{"group", "timeout"}, err: "-timeout and -arg can't be used with -group"},
+		{name: "group-arg", set: []string{"group", "arg"}, err: "-timeout and -arg can't be used with -group"},
+		{name: "no-proto", addresses: 1, err: "-proto is required with addresses"},
+		{name: "nothing", set: []string{"proto"}, err: "no servers to watch"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			set := make(map[string]bool)
+			for _, f := range tc.set {
+				set[f] = true
+			}
+
+			err := checkWatchFlags(set, tc.addresses, tc.heartbeats)
+			if tc.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package svrquery
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
 	"time"
 
@@ -15,23 +18,49 @@ var (
 
 	// DefaultNetwork is the default network for a new client.
 	DefaultNetwork = "udp"
+
+	// ErrNotConnected is returned when reading or writing a Client whose
+	// protocol is protocol.Connectionless.
+	ErrNotConnected = errors.New("client not connected")
 )
 
 // Option represents a Client option.
 type Option func(*Client) error
 
+// Dialer is a function which returns a connection to address on the named network.
+type Dialer func(network, address string) (net.Conn, error)
+
 // Client provides the ability to query a server.
 type Client struct {
 	protocol string
 	network  string
 	addr     string
-	ua       *net.UDPAddr
 	key      string
 	timeout  time.Duration
-	c        *net.UDPConn
+	dialer   Dialer
+	args     map[string]interface{}
+	c        net.Conn
+	stats    QueryStats
+	names    NameFilter
+	maxPkt   int
+	logger   protocol.Logger
+	qc       *protocol.QueryContext
+	ctx      context.Context
+	pacing   time.Duration
+	written  time.Time
+	mtu      int
+	df       dontFragment
 	protocol.Queryer
 }
 
+// QueryStats are the network statistics of a query.
+type QueryStats struct {
+	BytesSent       int `json:"bytes_sent"`
+	BytesReceived   int `json:"bytes_received"`
+	PacketsSent     int `json:"packets_sent"`
+	PacketsReceived int `json:"packets_received"`
+}
+
 // WithKey sets the key used for request by for the client.
 func WithKey(key string) Option {
 	return func(c *Client) error {
@@ -48,9 +77,81 @@ func WithTimeout(t time.Duration) Option {
 	}
 }
 
+// WithArg sets the protocol specific argument name to value.
+func WithArg(name string, value interface{}) Option {
+	return func(c *Client) error {
+		c.args[name] = value
+		return nil
+	}
+}
+
+// WithMaxPacketSize sets the maximum size of packets sent and received by
+// protocols which implement protocol.ContextQueryer.
+func WithMaxPacketSize(size int) Option {
+	return func(c *Client) error {
+		c.maxPkt = size
+		return nil
+	}
+}
+
+// WithLogger sets the logger which receives debug messages from protocols
+// which implement protocol.ContextQueryer.
+func WithLogger(l protocol.Logger) Option {
+	return func(c *Client) error {
+		c.logger = l
+		return nil
+	}
+}
+
+// WithPacing sets the minimum gap between consecutive packets sent by the
+// client. Protocols which send several request packets per query would
+// otherwise send them back to back, on congested links pacing them a few
+// milliseconds apart reduces the chance of them all being lost together.
+func WithPacing(gap time.Duration) Option {
+	return func(c *Client) error {
+		if gap < 0 {
+			return fmt.Errorf("pacing %v must not be negative", gap)
+		}
+		c.pacing = gap
+		return nil
+	}
+}
+
+// WithNetwork sets the network used by the client e.g. "udp4" or "udp6".
+func WithNetwork(network string) Option {
+	return func(c *Client) error {
+		c.network = network
+		return nil
+	}
+}
+
+// WithDialer sets the dialer used to create the clients connection.
+// This allows queries to be sent via proxies, userspace network stacks or
+// in-memory pipes for tests.
+func WithDialer(d Dialer) Option {
+	return func(c *Client) error {
+		c.dialer = d
+		return nil
+	}
+}
+
+// WithPacketConn sets the packet connection used by the client to send queries.
+// Only packets received from the clients address are processed, all others are
+// discarded. The client takes ownership of pc and closes it when it is closed.
+func WithPacketConn(pc net.PacketConn) Option {
+	return WithDialer(func(network, address string) (net.Conn, error) {
+		ua, err := net.ResolveUDPAddr(network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		return newPacketConn(pc, ua), nil
+	})
+}
+
 // NewClient creates a new client that talks to addr.
 func NewClient(proto, addr string, options ...Option) (*Client, error) {
-	f, err := protocol.Get(proto)
+	f, err := protocol.GetChecked(proto)
 	if err != nil {
 		return nil, err
 	}
@@ -60,8 +161,11 @@ func NewClient(proto, addr string, options ...Option) (*Client, error) {
 		addr:     addr,
 		network:  DefaultNetwork,
 		timeout:  DefaultTimeout,
+		dialer:   dialUDP,
+		args:     make(map[string]interface{}),
+		maxPkt:   protocol.DefaultMaxPacketSize,
+		ctx:      context.Background(),
 	}
-	c.Queryer = f(c)
 
 	for _, o := range options {
 		if err := o(c); err != nil {
@@ -69,45 +173,164 @@ func NewClient(proto, addr string, options ...Option) (*Client, error) {
 		}
 	}
 
-	if c.ua, err = net.ResolveUDPAddr(c.network, addr); err != nil {
+	if c.Queryer, err = f(c); err != nil {
 		return nil, err
 	}
 
-	if c.c, err = net.DialUDP(c.network, nil, c.ua); err != nil {
+	if _, ok := c.Queryer.(protocol.Connectionless); ok {
+		// The address may not even be resolvable by the client.
+		return c, nil
+	}
+
+	if c.c, err = c.dialer(c.network, addr); err != nil {
+		return nil, err
+	}
+
+	if err = c.applyMTU(); err != nil {
+		c.c.Close()
 		return nil, err
 	}
 
 	return c, nil
 }
 
+// deadline returns the deadline of the next read or write, which is the
+// earlier of the timeout and the deadline of the query context.
+func (c *Client) deadline() time.Time {
+	d := time.Now().Add(c.timeout)
+	if cd, ok := c.ctx.Deadline(); ok && cd.Before(d) {
+		return cd
+	}
+	return d
+}
+
+// pace waits until the pacing gap has passed since the previous write, or
+// the query context is done.
+func (c *Client) pace() error {
+	if c.pacing <= 0 || c.written.IsZero() {
+		return nil
+	}
+
+	wait := time.Until(c.written.Add(c.pacing))
+	if wait <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
 // Write implements io.Writer.
 func (c *Client) Write(b []byte) (int, error) {
-	if err := c.c.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+	if c.c == nil {
+		return 0, ErrNotConnected
+	} else if err := c.pace(); err != nil {
+		return 0, err
+	} else if err = c.c.SetWriteDeadline(c.deadline()); err != nil {
+		return 0, err
+	} else if err = c.ctx.Err(); err != nil {
 		return 0, err
 	}
 
-	return c.c.Write(b)
+	n, err := c.c.Write(b)
+	if n > 0 {
+		c.stats.BytesSent += n
+		c.stats.PacketsSent++
+	}
+	if c.pacing > 0 {
+		c.written = time.Now()
+	}
+	return n, err
 }
 
 // Read implements io.Reader.
 func (c *Client) Read(b []byte) (int, error) {
-	if err := c.c.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+	if c.c == nil {
+		return 0, ErrNotConnected
+	} else if err := c.c.SetReadDeadline(c.deadline()); err != nil {
+		return 0, err
+	} else if err = c.ctx.Err(); err != nil {
 		return 0, err
 	}
 
-	for {
-		n, addr, err := c.c.ReadFromUDP(b)
-		if err != nil {
-			return 0, err
-		} else if addr.String() == c.ua.String() { // We use String as IP's can be different byte but the same value.
-			return n, nil
+	n, err := c.c.Read(b)
+	if n > 0 {
+		c.stats.BytesReceived += n
+		c.stats.PacketsReceived++
+	}
+	return n, err
+}
+
+// Query implements protocol.Queryer, recording the network statistics of the
+// query which are available from QueryStats.
+func (c *Client) Query() (protocol.Responser, error) {
+	return c.QueryContext(context.Background())
+}
+
+// QueryContext is like Query but the query is aborted when ctx is done, and
+// ctx is passed to protocols which implement protocol.ContextQueryer.
+func (c *Client) QueryContext(ctx context.Context) (protocol.Responser, error) {
+	c.stats = QueryStats{}
+	c.ctx = ctx
+	defer func() { c.ctx = context.Background() }()
+
+	if ctx.Done() != nil && c.c != nil {
+		// Unblock any outstanding read or write when ctx is done, Read and
+		// Write check ctx after setting their deadline so can't miss it.
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				_ = c.c.SetDeadline(time.Now())
+			case <-done:
+			}
+		}()
+	}
+
+	var r protocol.Responser
+	var err error
+	if cq, ok := c.Queryer.(protocol.ContextQueryer); ok {
+		// The query context, and so its scratch buffer, is reused by each
+		// query of the client.
+		if c.qc == nil {
+			c.qc = protocol.NewQueryContext(ctx, c.maxPkt, c.logger)
 		}
-		// Packet from unexpected source just ignore.
+		c.qc.Context = ctx
+		r, err = cq.QueryContext(c.qc)
+	} else {
+		r, err = c.Queryer.Query()
 	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	if pn, ok := r.(protocol.PlayerNamer); ok && c.names != nil {
+		pn.MapPlayerNames(c.names)
+	}
+	return r, nil
+}
+
+// QueryStats returns the network statistics of the last query, including any
+// challenge handshake.
+func (c *Client) QueryStats() QueryStats {
+	return c.stats
 }
 
 // Close implements io.Closer.
 func (c *Client) Close() error {
+	if c.c == nil {
+		return nil
+	}
 	return c.c.Close()
 }
 
@@ -121,6 +344,11 @@ func (c *Client) Address() string {
 	return c.addr
 }
 
+// Args implements protocol.Arguer.
+func (c *Client) Args() map[string]interface{} {
+	return c.args
+}
+
 // Protocol returns the protocol of the client.
 func (c *Client) Protocol() string {
 	return c.protocol
@@ -1,11 +1,15 @@
 package svrquery
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
 	"github.com/stretchr/testify/require"
 )
 
@@ -41,6 +45,122 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
+func TestClientIPv6(t *testing.T) {
+	pc, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skip("ipv6 not available:", err)
+	}
+	defer pc.Close()
+
+	c, err := NewClient("sqp", pc.LocalAddr().String(), WithNetwork("udp6"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 10)
+	n, from, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf[:n]))
+
+	_, err = pc.WriteTo([]byte("pong"), from)
+	require.NoError(t, err)
+
+	n, err = c.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "pong", string(buf[:n]))
+
+	_, err = NewClient("sqp", pc.LocalAddr().String(), WithNetwork("udp4"))
+	require.Error(t, err)
+}
+
+func TestClientWithDialer(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	var network, address string
+	c, err := NewClient("sqp", "192.0.2.1:12345", WithDialer(func(n, a string) (net.Conn, error) {
+		network, address = n, a
+		return local, nil
+	}))
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, DefaultNetwork, network)
+	require.Equal(t, "192.0.2.1:12345", address)
+
+	go func() {
+		buf := make([]byte, 10)
+		n, err := remote.Read(buf)
+		if err != nil {
+			return
+		}
+		_, _ = remote.Write(buf[:n])
+	}()
+
+	_, err = c.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 10)
+	n, err := c.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf[:n]))
+}
+
+func TestClientConnectionless(t *testing.T) {
+	c, err := NewClient("exec", "unresolvable.invalid:1",
+		WithArg("command", "adapter"),
+		WithDialer(func(n, a string) (net.Conn, error) {
+			t.Fatal("dialed", n, a)
+			return nil, nil
+		}),
+	)
+	require.NoError(t, err)
+
+	_, err = c.Write([]byte("ping"))
+	require.Equal(t, ErrNotConnected, err)
+
+	_, err = c.Read(make([]byte, 10))
+	require.Equal(t, ErrNotConnected, err)
+
+	require.NoError(t, c.Close())
+}
+
+func TestClientWithPacketConn(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+
+	other, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer other.Close()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	c, err := NewClient("sqp", server.LocalAddr().String(), WithPacketConn(pc))
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 10)
+	n, from, err := server.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf[:n]))
+
+	// Packets from unexpected sources must be ignored.
+	_, err = other.WriteTo([]byte("spoof"), from)
+	require.NoError(t, err)
+	_, err = server.WriteTo([]byte("pong"), from)
+	require.NoError(t, err)
+
+	n, err = c.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "pong", string(buf[:n]))
+}
+
 func TestQuery(t *testing.T) {
 	addr := os.Getenv("TEST_QUERY_ADDR")
 	if addr == "" {
@@ -60,3 +180,98 @@ func TestQuery(t *testing.T) {
 		fmt.Printf("%#v\n", r)
 	}
 }
+
+func TestClientQueryStats(t *testing.T) {
+	s, err := server.New(
+		server.WithAddress("127.0.0.1:0"),
+		server.WithProtocol("sqp"),
+		server.WithState(common.QueryState{ServerName: "stats"}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	c, err := NewClient("sqp", s.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Query()
+	require.NoError(t, err)
+
+	// Challenge request and query of 5 and 8 bytes, challenge response and
+	// query response with an 11 byte header and a 19 byte server info chunk.
+	require.Equal(t, QueryStats{
+		BytesSent:       5 + 8,
+		BytesReceived:   5 + 11 + 19,
+		PacketsSent:     2,
+		PacketsReceived: 2,
+	}, c.QueryStats())
+
+	// Reset for each query.
+	_, err = c.Query()
+	require.NoError(t, err)
+	require.Equal(t, 2, c.QueryStats().PacketsSent)
+}
+
+func TestClientQueryContext(t *testing.T) {
+	// A server which never responds.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	c, err := NewClient("tf2e", pc.LocalAddr().String(), WithTimeout(time.Second*5))
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*50, cancel)
+	start := time.Now()
+	_, err = c.QueryContext(ctx)
+	require.Equal(t, context.Canceled, err)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	// The context deadline is used if earlier than the timeout.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	start = time.Now()
+	_, err = c.QueryContext(ctx)
+	require.Error(t, err)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	c, err = NewClient("tf2e", pc.LocalAddr().String(), WithMaxPacketSize(512))
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Query()
+	require.Error(t, err)
+}
+
+func TestClientPacing(t *testing.T) {
+	_, err := NewClient("sqp", "192.0.2.1:12345", WithPacing(-time.Millisecond))
+	require.Error(t, err)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	gap := time.Millisecond * 20
+	c, err := NewClient("sqp", pc.LocalAddr().String(), WithPacing(gap))
+	require.NoError(t, err)
+	defer c.Close()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err = c.Write([]byte("request"))
+		require.NoError(t, err)
+	}
+	require.True(t, time.Since(start) >= gap*2, "writes not paced")
+
+	// Each packet is received.
+	buf := make([]byte, 16)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, pc.SetReadDeadline(time.Now().Add(time.Second)))
+		n, _, err := pc.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, "request", string(buf[:n]))
+	}
+}
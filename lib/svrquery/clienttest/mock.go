@@ -46,13 +46,13 @@ func (mc *MockClient) Key() string {
 	return args.String(0)
 }
 
-func LoadData(t *testing.T, fileParts ...string) []byte {
+func LoadData(t testing.TB, fileParts ...string) []byte {
 	d, err := ioutil.ReadFile(filepath.Join(fileParts...))
 	require.NoError(t, err)
 	return d
 }
 
-func LoadMultiData(t *testing.T, files int, fileParts ...string) [][]byte {
+func LoadMultiData(t testing.TB, files int, fileParts ...string) [][]byte {
 	pkts := make([][]byte, files)
 	file := fileParts[len(fileParts)-1]
 	for i := range pkts {
@@ -0,0 +1,43 @@
+package clienttest
+
+// ReplayClient is a protocol.Client which returns Packets in order from
+// successive reads, wrapping around when all have been read. Writes are
+// discarded. It's intended for benchmarks where MockClient overhead would
+// dominate.
+type ReplayClient struct {
+	Packets [][]byte
+	Addr    string
+	next    int
+}
+
+// Address implements protocol.Client.
+func (rc *ReplayClient) Address() string {
+	return rc.Addr
+}
+
+// Key implements protocol.Client.
+func (rc *ReplayClient) Key() string {
+	return ""
+}
+
+// Args implements protocol.Arguer.
+func (rc *ReplayClient) Args() map[string]interface{} {
+	return nil
+}
+
+// Write implements io.Writer.
+func (rc *ReplayClient) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// Read implements io.Reader.
+func (rc *ReplayClient) Read(b []byte) (int, error) {
+	p := rc.Packets[rc.next]
+	rc.next = (rc.next + 1) % len(rc.Packets)
+	return copy(b, p), nil
+}
+
+// Close implements io.Closer.
+func (rc *ReplayClient) Close() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// Challenge performs the challenge handshakes of protocols which require a
+// cookie from the server to be attached to queries, so the retry and timeout
+// behaviour is consistent across protocols.
+type Challenge struct {
+	probe   func() error
+	receive func() (uint32, error)
+	retries int
+	cookie  uint32
+	valid   bool
+}
+
+// NewChallenge returns a Challenge which sends probes using probe and reads
+// the cookie of the response using receive. If reading the response times out
+// the probe is resent up to retries times.
+func NewChallenge(probe func() error, receive func() (uint32, error), retries int) *Challenge {
+	return &Challenge{probe: probe, receive: receive, retries: retries}
+}
+
+// Do performs a handshake and returns the cookie received. The cookie is
+// forgotten if the handshake fails.
+func (c *Challenge) Do() (uint32, error) {
+	var err error
+	for i := 0; i <= c.retries; i++ {
+		if err = c.probe(); err != nil {
+			break
+		}
+
+		var cookie uint32
+		if cookie, err = c.receive(); err == nil {
+			c.Set(cookie)
+			return cookie, nil
+		} else if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			break
+		}
+	}
+
+	c.Reset()
+	return 0, err
+}
+
+// Cookie returns the cookie of the last handshake and true, or false if there
+// hasn't been a successful handshake since the last Reset.
+func (c *Challenge) Cookie() (uint32, bool) {
+	return c.cookie, c.valid
+}
+
+// Set sets the cookie, for protocols where servers can issue a new cookie in
+// response to a query.
+func (c *Challenge) Set(cookie uint32) {
+	c.cookie = cookie
+	c.valid = true
+}
+
+// Reset forgets the cookie, for example because it may have expired, so a
+// new handshake is required.
+func (c *Challenge) Reset() {
+	c.cookie = 0
+	c.valid = false
+}
+
+// Attach appends the cookie to b using order, a zero cookie is appended if
+// there is none.
+func (c *Challenge) Attach(b []byte, order binary.ByteOrder) []byte {
+	var cookie [4]byte
+	order.PutUint32(cookie[:], c.cookie)
+	return append(b, cookie[:]...)
+}
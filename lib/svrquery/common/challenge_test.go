@@ -0,0 +1,73 @@
+package common
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// timeoutError is a net.Error which has timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestChallenge(t *testing.T) {
+	cases := []struct {
+		name    string
+		retries int
+		errs    []error
+		probes  int
+		err     bool
+	}{
+		{name: "success", probes: 1},
+		{name: "timeout", errs: []error{timeoutError{}}, probes: 1, err: true},
+		{name: "retried", retries: 2, errs: []error{timeoutError{}, timeoutError{}}, probes: 3},
+		{name: "retries-exhausted", retries: 1, errs: []error{timeoutError{}, timeoutError{}}, probes: 2, err: true},
+		{name: "not-retried", retries: 2, errs: []error{errors.New("malformed")}, probes: 1, err: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var probes int
+			c := NewChallenge(
+				func() error {
+					probes++
+					return nil
+				},
+				func() (uint32, error) {
+					if probes <= len(tc.errs) {
+						return 0, tc.errs[probes-1]
+					}
+					return 0x01020304, nil
+				},
+				tc.retries,
+			)
+
+			c.Set(1)
+			cookie, err := c.Do()
+			require.Equal(t, tc.probes, probes)
+			if tc.err {
+				require.Error(t, err)
+				_, ok := c.Cookie()
+				require.False(t, ok)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, uint32(0x01020304), cookie)
+			cookie, ok := c.Cookie()
+			require.True(t, ok)
+			require.Equal(t, uint32(0x01020304), cookie)
+			require.Equal(t, []byte{'Q', 1, 2, 3, 4}, c.Attach([]byte{'Q'}, binary.BigEndian))
+			require.Equal(t, []byte{4, 3, 2, 1}, c.Attach(nil, binary.LittleEndian))
+
+			c.Reset()
+			_, ok = c.Cookie()
+			require.False(t, ok)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package svrquery
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+// ErrNotDetected is returned by Detect if no protocol returned a valid response.
+var ErrNotDetected = errors.New("no protocol detected")
+
+// undetectable are the protocols which Detect doesn't probe, as they don't
+// query the server themselves.
+var undetectable = map[string]bool{"exec": true}
+
+// Detection is the result of a successful Detect.
+type Detection struct {
+	// Protocol is the name of the protocol which responded.
+	Protocol string
+
+	// Response is the response to the query.
+	Response protocol.Responser
+
+	// Latency is the time taken to query using Protocol, including any
+	// handshake performed by the query.
+	Latency time.Duration
+
+	// Stats are the network statistics of the query, including any handshake.
+	Stats QueryStats
+}
+
+// Detect probes the server at addr with every registered protocol concurrently
+// and returns the first valid response. Protocols which require a handshake
+// perform it as part of their query so invalid protocols fail fast. The exec
+// protocol isn't probed as it runs an external adapter.
+//
+// options are applied to the client of every protocol so must not share state,
+// for example WithPacketConn must not be used.
+func Detect(addr string, options ...Option) (*Detection, error) {
+	protos := make([]string, 0, len(protocol.Names()))
+	for _, p := range protocol.Names() {
+		if !undetectable[p] {
+			protos = append(protos, p)
+		}
+	}
+	return detect(addr, protos, options...)
+}
+
+// detect probes addr with each of protos and returns the first valid response.
+func detect(addr string, protos []string, options ...Option) (*Detection, error) {
+	type result struct {
+		proto string
+		d     *Detection
+		err   error
+	}
+
+	var mtx sync.Mutex
+	var clients []*Client
+	var closed bool
+	results := make(chan result, len(protos))
+	var wg sync.WaitGroup
+	wg.Add(len(protos))
+	for _, p := range protos {
+		go func(proto string) {
+			defer wg.Done()
+			c, err := NewClient(proto, addr, options...)
+			if err != nil {
+				results <- result{proto: proto, err: err}
+				return
+			}
+
+			mtx.Lock()
+			if closed {
+				mtx.Unlock()
+				_ = c.Close()
+				results <- result{proto: proto, err: ErrNotDetected}
+				return
+			}
+			clients = append(clients, c)
+			mtx.Unlock()
+
+			d, err := probe(c)
+			results <- result{proto: proto, d: d, err: err}
+		}(p)
+	}
+
+	// Closing all clients unblocks any outstanding probes.
+	defer func() {
+		mtx.Lock()
+		closed = true
+		for _, c := range clients {
+			_ = c.Close()
+		}
+		mtx.Unlock()
+		wg.Wait()
+	}()
+
+	errs := make([]string, 0, len(protos))
+	for range protos {
+		r := <-results
+		if r.err == nil {
+			return r.d, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", r.proto, r.err))
+	}
+
+	return nil, fmt.Errorf("%w (%s)", ErrNotDetected, strings.Join(errs, ", "))
+}
+
+// probe queries c. The query performs its own handshake, if any, so it's
+// included in the stats and isn't repeated.
+func probe(c *Client) (*Detection, error) {
+	start := time.Now()
+	r, err := c.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Detection{
+		Protocol: c.Protocol(),
+		Response: r,
+		Latency:  time.Since(start),
+		Stats:    c.QueryStats(),
+	}, nil
+}
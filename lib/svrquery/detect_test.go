@@ -0,0 +1,81 @@
+package svrquery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	s, err := server.New(
+		server.WithAddress("127.0.0.1:0"),
+		server.WithProtocol("sqp"),
+		server.WithState(common.QueryState{CurrentPlayers: 1, MaxPlayers: 2}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	start := time.Now()
+	d, err := Detect(s.Addr().String())
+	require.NoError(t, err)
+	require.Equal(t, "sqp", d.Protocol)
+	require.Equal(t, int64(1), d.Response.NumClients())
+	require.True(t, d.Latency > 0)
+
+	// Probes of other protocols must be aborted rather than timing out.
+	require.True(t, time.Since(start) < DefaultTimeout)
+}
+
+func TestDetectHandshake(t *testing.T) {
+	s, err := server.New(
+		server.WithAddress("127.0.0.1:0"),
+		server.WithProtocol("sqp"),
+		server.WithState(common.QueryState{CurrentPlayers: 1, MaxPlayers: 2}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	var conn *countingConn
+	dialer := func(network, address string) (net.Conn, error) {
+		c, err := net.Dial(network, address)
+		conn = &countingConn{Conn: c}
+		return conn, err
+	}
+
+	// A single challenge and query, both included in the stats.
+	d, err := detect(s.Addr().String(), []string{"sqp"}, WithArg("handshake", "always"), WithDialer(dialer))
+	require.NoError(t, err)
+	require.Equal(t, 2, conn.writes)
+	require.Equal(t, 2, d.Stats.PacketsSent)
+	require.Equal(t, 2, d.Stats.PacketsReceived)
+}
+
+// countingConn is a net.Conn which counts writes.
+type countingConn struct {
+	net.Conn
+	writes int
+}
+
+// Write implements io.Writer.
+func (c *countingConn) Write(b []byte) (int, error) {
+	c.writes++
+	return c.Conn.Write(b)
+}
+
+func TestDetectNotDetected(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	_, err = Detect(pc.LocalAddr().String(), WithTimeout(time.Millisecond*50))
+	require.True(t, errors.Is(err, ErrNotDetected))
+	require.Contains(t, err.Error(), "sqp: ")
+}
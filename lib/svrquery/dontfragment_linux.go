@@ -0,0 +1,28 @@
+package svrquery
+
+import (
+	"syscall"
+)
+
+// setDontFragment sets or clears the don't fragment flag of packets sent on
+// rc by setting its path MTU discovery mode.
+func setDontFragment(rc syscall.RawConn, ipv6, enabled bool) error {
+	level, opt, mode := syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DONT
+	if enabled {
+		mode = syscall.IP_PMTUDISC_DO
+	}
+	if ipv6 {
+		level, opt, mode = syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_DONT
+		if enabled {
+			mode = syscall.IPV6_PMTUDISC_DO
+		}
+	}
+
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), level, opt, mode)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
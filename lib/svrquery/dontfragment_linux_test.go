@@ -0,0 +1,63 @@
+package svrquery
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mtuDiscover returns the path MTU discovery mode of the socket of conn.
+func mtuDiscover(t *testing.T, conn syscall.Conn, level, opt int) int {
+	t.Helper()
+
+	rc, err := conn.SyscallConn()
+	require.NoError(t, err)
+
+	var mode int
+	var serr error
+	require.NoError(t, rc.Control(func(fd uintptr) {
+		mode, serr = syscall.GetsockoptInt(int(fd), level, opt)
+	}))
+	require.NoError(t, serr)
+	return mode
+}
+
+func TestClientDontFragment(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		mode    int
+	}{
+		{name: "enabled", enabled: true, mode: syscall.IP_PMTUDISC_DO},
+		{name: "disabled", enabled: false, mode: syscall.IP_PMTUDISC_DONT},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewClient("sqp", "127.0.0.1:12121", WithDontFragment(tc.enabled))
+			require.NoError(t, err)
+			defer c.Close()
+			require.Equal(t, tc.mode, mtuDiscover(t, c.c.(syscall.Conn), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER))
+
+			pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+			require.NoError(t, err)
+			c, err = NewClient("sqp", "127.0.0.1:12121", WithPacketConn(pc), WithDontFragment(tc.enabled))
+			require.NoError(t, err)
+			defer c.Close()
+			require.Equal(t, tc.mode, mtuDiscover(t, pc.(syscall.Conn), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER))
+		})
+	}
+
+	pc, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skip("ipv6 not available:", err)
+	}
+	defer pc.Close()
+
+	c, err := NewClient("sqp", pc.LocalAddr().String(), WithDontFragment(true))
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, syscall.IPV6_PMTUDISC_DO, mtuDiscover(t, c.c.(syscall.Conn), syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER))
+}
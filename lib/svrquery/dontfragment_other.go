@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package svrquery
+
+import (
+	"syscall"
+)
+
+// setDontFragment returns ErrDontFragmentUnsupported as setting the don't
+// fragment flag is only supported on Linux.
+func setDontFragment(rc syscall.RawConn, ipv6, enabled bool) error {
+	return ErrDontFragmentUnsupported
+}
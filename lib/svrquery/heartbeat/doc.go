@@ -0,0 +1,4 @@
+// Package heartbeat provides a registry of servers which announce themselves
+// over UDP or HTTP, so elastic fleets are polled without maintaining an
+// inventory of their addresses. Servers which stop announcing are expired.
+package heartbeat
@@ -0,0 +1,113 @@
+package heartbeat
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+)
+
+// maxAnnouncementSize is the max size of an encoded announcement.
+const maxAnnouncementSize = 1024
+
+// ErrorHandler is called with announcements which fail to register, it may be
+// nil.
+type ErrorHandler func(addr net.Addr, err error)
+
+// ServePacket registers the servers of announcements received on conn, one per
+// datagram, until ctx is done when conn is closed and ctx.Err() returned.
+// Announcements are fire and forget, no response is sent so the listener
+// can't be used to amplify traffic.
+func (r *Registry) ServePacket(ctx context.Context, conn net.PacketConn, errh ErrorHandler) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	b := make([]byte, maxAnnouncementSize)
+	for {
+		n, addr, err := conn.ReadFrom(b)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Temporary() {
+				continue
+			}
+			return err
+		}
+
+		a, err := decode(b[:n])
+		if err == nil {
+			err = r.Register(a, ip(addr))
+		}
+		if err != nil && errh != nil {
+			errh(addr, err)
+		}
+	}
+}
+
+// Handler returns an http.Handler which registers the server of an
+// announcement posted as the request body. It responds with 204 on success.
+func (r *Registry) Handler(errh ErrorHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var addr net.Addr
+		if a, err := net.ResolveTCPAddr("tcp", req.RemoteAddr); err == nil {
+			addr = a
+		}
+
+		b, err := ioutil.ReadAll(io.LimitReader(req.Body, maxAnnouncementSize+1))
+		if err == nil && len(b) > maxAnnouncementSize {
+			err = errors.New("announcement too large")
+		}
+		var a Announcement
+		if err == nil {
+			a, err = decode(b)
+		}
+		if err == nil {
+			err = r.Register(a, ip(addr))
+		}
+
+		if err != nil {
+			if errh != nil {
+				errh(addr, err)
+			}
+			code := http.StatusBadRequest
+			switch {
+			case errors.Is(err, ErrInvalidToken):
+				code = http.StatusForbidden
+			case errors.Is(err, ErrTooManyTargets):
+				code = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// ip returns the IP of addr, or nil if it has none.
+func ip(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package heartbeat
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServePacket(t *testing.T) {
+	r, err := New(WithTokens("secret"))
+	require.NoError(t, err)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- r.ServePacket(ctx, conn, func(addr net.Addr, err error) { errs <- err })
+	}()
+
+	c, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Write([]byte(`{"name":"bad","address":":12121","protocol":"sqp","token":"wrong"}`))
+	require.NoError(t, err)
+	_, err = c.Write([]byte(`not json`))
+	require.NoError(t, err)
+	_, err = c.Write([]byte(`{"name":"eu-1","address":":12121","protocol":"sqp","token":"secret"}`))
+	require.NoError(t, err)
+
+	require.Equal(t, ErrInvalidToken, <-errs)
+	require.Error(t, <-errs)
+	require.Eventually(t, func() bool { return r.Len() == 1 }, time.Second, time.Millisecond*10)
+
+	targets := r.Targets()
+	require.Equal(t, "eu-1", targets[0].Name)
+	require.Equal(t, "127.0.0.1:12121", targets[0].Address)
+
+	cancel()
+	require.Equal(t, context.Canceled, <-done)
+}
+
+func TestHandler(t *testing.T) {
+	r, err := New(WithTokens("secret"), WithMaxTargets(1))
+	require.NoError(t, err)
+	s := httptest.NewServer(r.Handler(nil))
+	defer s.Close()
+
+	post := func(body string) int {
+		resp, err := http.Post(s.URL, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusNoContent, post(`{"name":"eu-1","address":":12121","protocol":"sqp","token":"secret"}`))
+	require.Equal(t, http.StatusForbidden, post(`{"name":"eu-2","address":":12121","protocol":"sqp","token":"wrong"}`))
+	require.Equal(t, http.StatusBadRequest, post(`{"name":"eu-2","address":":12121","protocol":"unknown","token":"secret"}`))
+	require.Equal(t, http.StatusBadRequest, post(strings.Repeat(" ", maxAnnouncementSize+1)))
+	require.Equal(t, http.StatusServiceUnavailable, post(`{"name":"eu-2","address":":12122","protocol":"sqp","token":"secret"}`))
+
+	resp, err := http.Get(s.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	targets := r.Targets()
+	require.Len(t, targets, 1)
+	require.Equal(t, "127.0.0.1:12121", targets[0].Address)
+}
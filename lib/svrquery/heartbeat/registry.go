@@ -0,0 +1,365 @@
+package heartbeat
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+const (
+	// DefaultTTL is the default time a server remains registered after its
+	// last announcement.
+	DefaultTTL = time.Second * 90
+
+	// DefaultMaxTargets is the default max number of registered servers.
+	DefaultMaxTargets = 500
+)
+
+var (
+	// ErrNoTokens is returned by New if no tokens are configured.
+	ErrNoTokens = errors.New("no tokens")
+
+	// ErrInvalidToken is returned by Register if the announcement token isn't
+	// one of the configured tokens.
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrTooManyTargets is returned by Register if the max number of servers
+	// are already registered.
+	ErrTooManyTargets = errors.New("too many targets")
+
+	// ErrHostNotAllowed is returned by Register if the host of the address
+	// isn't the sender's IP or one of the allowed hosts.
+	ErrHostNotAllowed = errors.New("host not allowed")
+
+	// ErrConnectionless is returned by Register if the protocol doesn't query
+	// the server's address, such as exec, so must not be chosen by servers.
+	ErrConnectionless = errors.New("connectionless protocol")
+
+	// errProbe is returned by the dialer used to check if a protocol is
+	// connectionless.
+	errProbe = errors.New("probe")
+)
+
+// Announcement is the heartbeat a server sends to register itself. It's
+// encoded as JSON in both UDP datagrams and HTTP request bodies.
+type Announcement struct {
+	// Name is the display name of the server, defaults to the address.
+	// Servers are identified by their protocol and address, so a server
+	// can't replace another by announcing its name.
+	Name string `json:"name,omitempty"`
+
+	// Address is the query address of the server. If its host is empty or
+	// unspecified, such as ":12121", the address the announcement was sent
+	// from is used. Otherwise it must be that address or allowed by
+	// WithAllowedHosts.
+	Address string `json:"address"`
+
+	// Protocol is the query protocol of the server.
+	Protocol string `json:"protocol"`
+
+	// Token authenticates the server.
+	Token string `json:"token"`
+}
+
+// entryKey identifies a registered server.
+type entryKey struct {
+	protocol string
+	address  string
+}
+
+// entry is a registered server.
+type entry struct {
+	target poller.Target
+	seen   time.Time
+}
+
+// Option represents a Registry option.
+type Option func(*Registry) error
+
+// WithTokens adds tokens which servers may announce with, at least one is
+// required.
+func WithTokens(tokens ...string) Option {
+	return func(r *Registry) error {
+		for _, t := range tokens {
+			if t == "" {
+				return errors.New("token must not be empty")
+			}
+		}
+		r.tokens = append(r.tokens, tokens...)
+		return nil
+	}
+}
+
+// WithTTL sets the time a server remains registered after its last
+// announcement, defaulting to DefaultTTL. Servers should announce several
+// times within it so lost datagrams don't expire them.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Registry) error {
+		if ttl <= 0 {
+			return errors.New("ttl must be positive")
+		}
+		r.ttl = ttl
+		return nil
+	}
+}
+
+// WithMaxTargets sets the max number of registered servers, defaulting to
+// DefaultMaxTargets. It bounds the memory used and the number of queries made
+// by the poller.
+func WithMaxTargets(n int) Option {
+	return func(r *Registry) error {
+		if n < 1 {
+			return errors.New("max targets must be at least 1")
+		}
+		r.maxTargets = n
+		return nil
+	}
+}
+
+// WithAllowedHosts allows servers to announce addresses with hosts other than
+// the IP they announce from. Each host is an IP or CIDR network, host names
+// aren't allowed.
+func WithAllowedHosts(hosts ...string) Option {
+	return func(r *Registry) error {
+		for _, h := range hosts {
+			_, n, err := net.ParseCIDR(h)
+			if err != nil {
+				ip := net.ParseIP(h)
+				if ip == nil {
+					return fmt.Errorf("invalid allowed host %q", h)
+				}
+				n = &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+			}
+			r.allowed = append(r.allowed, n)
+		}
+		return nil
+	}
+}
+
+// WithTargetOptions sets the client options used to query registered servers.
+func WithTargetOptions(options ...svrquery.Option) Option {
+	return func(r *Registry) error {
+		r.options = options
+		return nil
+	}
+}
+
+// Registry is a poller.Source of the servers which have announced themselves
+// within the ttl. It's safe for concurrent use.
+type Registry struct {
+	tokens     []string
+	ttl        time.Duration
+	maxTargets int
+	options    []svrquery.Option
+	allowed    []*net.IPNet
+
+	mtx            sync.Mutex
+	entries        map[entryKey]*entry
+	connectionless map[string]bool
+
+	// now returns the current time, it's replaced in tests.
+	now func() time.Time
+}
+
+// New returns a new Registry configured with options.
+func New(options ...Option) (*Registry, error) {
+	r := &Registry{
+		ttl:            DefaultTTL,
+		maxTargets:     DefaultMaxTargets,
+		entries:        make(map[entryKey]*entry),
+		connectionless: make(map[string]bool),
+		now:            time.Now,
+	}
+	for _, o := range options {
+		if err := o(r); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(r.tokens) == 0 {
+		return nil, ErrNoTokens
+	}
+
+	return r, nil
+}
+
+// Register registers or refreshes the server of a, which was sent from ip.
+func (r *Registry) Register(a Announcement, ip net.IP) error {
+	if !r.validToken(a.Token) {
+		return ErrInvalidToken
+	} else if !protocol.Supported(a.Protocol) {
+		return fmt.Errorf("unsupported protocol %q", a.Protocol)
+	}
+
+	host, port, err := net.SplitHostPort(a.Address)
+	if err != nil {
+		return fmt.Errorf("invalid address: %w", err)
+	} else if port == "" || port == "0" {
+		return fmt.Errorf("invalid address %q: missing port", a.Address)
+	}
+
+	if host == "" || net.ParseIP(host).IsUnspecified() {
+		if ip == nil {
+			return fmt.Errorf("invalid address %q: missing host", a.Address)
+		}
+		host = ip.String()
+	} else if !r.allowedHost(host, ip) {
+		return fmt.Errorf("%w: %q", ErrHostNotAllowed, host)
+	}
+
+	if err := r.checkConnection(a.Protocol, net.JoinHostPort(host, port)); err != nil {
+		return err
+	}
+
+	t := poller.Target{
+		Name:     a.Name,
+		Protocol: a.Protocol,
+		Address:  net.JoinHostPort(host, port),
+		Options:  r.options,
+	}
+	if t.Name == "" {
+		t.Name = t.Address
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	now := r.now()
+	k := entryKey{protocol: t.Protocol, address: t.Address}
+	if e, ok := r.entries[k]; ok {
+		e.target = t
+		e.seen = now
+		return nil
+	}
+
+	if len(r.entries) >= r.maxTargets {
+		// Make room by expiring before refusing.
+		r.expire(now)
+		if len(r.entries) >= r.maxTargets {
+			return ErrTooManyTargets
+		}
+	}
+
+	r.entries[k] = &entry{target: t, seen: now}
+	return nil
+}
+
+// validToken returns true if token is one of the configured tokens.
+func (r *Registry) validToken(token string) bool {
+	var ok bool
+	for _, t := range r.tokens {
+		// Compare with every token in constant time so the time taken
+		// doesn't reveal valid tokens.
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
+
+// allowedHost returns true if host is ip or one of the allowed hosts.
+func (r *Registry) allowedHost(host string, ip net.IP) bool {
+	h := net.ParseIP(host)
+	if h == nil {
+		// Names could resolve to anything.
+		return false
+	} else if h.Equal(ip) {
+		return true
+	}
+
+	for _, n := range r.allowed {
+		if n.Contains(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConnection returns ErrConnectionless if proto doesn't query addr, as
+// its queries could run anything configured by the target options, or an
+// error if a client of proto can't be created with the target options.
+func (r *Registry) checkConnection(proto, addr string) error {
+	r.mtx.Lock()
+	cl, ok := r.connectionless[proto]
+	r.mtx.Unlock()
+
+	if !ok {
+		options := append(r.options[:len(r.options):len(r.options)], svrquery.WithDialer(func(string, string) (net.Conn, error) {
+			return nil, errProbe
+		}))
+		c, err := svrquery.NewClient(proto, addr, options...)
+		switch {
+		case err == nil:
+			c.Close()
+			cl = true
+		case !errors.Is(err, errProbe):
+			return err
+		}
+
+		r.mtx.Lock()
+		r.connectionless[proto] = cl
+		r.mtx.Unlock()
+	}
+
+	if cl {
+		return fmt.Errorf("%w %q", ErrConnectionless, proto)
+	}
+	return nil
+}
+
+// Targets implements poller.Source, returning the registered servers sorted
+// by name and address. Expired servers are removed.
+func (r *Registry) Targets() []poller.Target {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.expire(r.now())
+	targets := make([]poller.Target, 0, len(r.entries))
+	for _, e := range r.entries {
+		targets = append(targets, e.target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Name != targets[j].Name {
+			return targets[i].Name < targets[j].Name
+		}
+		return targets[i].Address < targets[j].Address
+	})
+
+	return targets
+}
+
+// Len returns the number of registered servers, including any which have
+// expired but not yet been removed.
+func (r *Registry) Len() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return len(r.entries)
+}
+
+// expire removes the servers which haven't announced within the ttl.
+func (r *Registry) expire(now time.Time) {
+	for k, e := range r.entries {
+		if now.Sub(e.seen) > r.ttl {
+			delete(r.entries, k)
+		}
+	}
+}
+
+// decode decodes an announcement from b.
+func decode(b []byte) (Announcement, error) {
+	var a Announcement
+	if err := json.Unmarshal(b, &a); err != nil {
+		return a, fmt.Errorf("invalid announcement: %w", err)
+	}
+	return a, nil
+}
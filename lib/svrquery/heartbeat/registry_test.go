@@ -0,0 +1,212 @@
+package heartbeat
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/exec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	_, err := New()
+	require.Equal(t, ErrNoTokens, err)
+
+	_, err = New(WithTokens(""))
+	require.Error(t, err)
+
+	_, err = New(WithTokens("secret"), WithTTL(0))
+	require.Error(t, err)
+
+	_, err = New(WithTokens("secret"), WithMaxTargets(0))
+	require.Error(t, err)
+
+	_, err = New(WithTokens("secret"), WithAllowedHosts("example.com"))
+	require.Error(t, err)
+
+	r, err := New(WithTokens("secret"))
+	require.NoError(t, err)
+	require.Equal(t, DefaultTTL, r.ttl)
+	require.Equal(t, DefaultMaxTargets, r.maxTargets)
+}
+
+func TestRegister(t *testing.T) {
+	r, err := New(
+		WithTokens("old", "secret"),
+		WithAllowedHosts("192.0.2.0/25", "2001:db8::1"),
+		WithTargetOptions(svrquery.WithArg(exec.CommandArg, os.Args[0])),
+	)
+	require.NoError(t, err)
+
+	from := net.ParseIP("198.51.100.10")
+	tests := []struct {
+		name    string
+		a       Announcement
+		address string
+		err     error
+	}{
+		{
+			name:    "address",
+			a:       Announcement{Address: "192.0.2.1:12121", Protocol: "sqp", Token: "secret"},
+			address: "192.0.2.1:12121",
+		},
+		{
+			name:    "any-token",
+			a:       Announcement{Name: "eu-1", Address: "192.0.2.2:12121", Protocol: "sqp", Token: "old"},
+			address: "192.0.2.2:12121",
+		},
+		{
+			name:    "sender-host",
+			a:       Announcement{Name: "eu-2", Address: ":12121", Protocol: "sqp", Token: "secret"},
+			address: "198.51.100.10:12121",
+		},
+		{
+			name:    "unspecified-host",
+			a:       Announcement{Name: "eu-3", Address: "0.0.0.0:12122", Protocol: "sqp", Token: "secret"},
+			address: "198.51.100.10:12122",
+		},
+		{
+			name:    "sender-ip",
+			a:       Announcement{Name: "eu-4", Address: "198.51.100.10:12123", Protocol: "sqp", Token: "secret"},
+			address: "198.51.100.10:12123",
+		},
+		{
+			name:    "allowed-ip",
+			a:       Announcement{Name: "eu-5", Address: "[2001:db8::1]:12121", Protocol: "sqp", Token: "secret"},
+			address: "[2001:db8::1]:12121",
+		},
+		{
+			name: "not-allowed",
+			a:    Announcement{Address: "192.0.2.200:12121", Protocol: "sqp", Token: "secret"},
+			err:  ErrHostNotAllowed,
+		},
+		{
+			name: "host-name",
+			a:    Announcement{Address: "example.com:12121", Protocol: "sqp", Token: "secret"},
+			err:  ErrHostNotAllowed,
+		},
+		{
+			name: "connectionless",
+			a:    Announcement{Address: ":12121", Protocol: "exec", Token: "secret"},
+			err:  ErrConnectionless,
+		},
+		{
+			name: "invalid-token",
+			a:    Announcement{Address: "192.0.2.1:12121", Protocol: "sqp", Token: "secre"},
+			err:  ErrInvalidToken,
+		},
+		{
+			name: "unsupported-protocol",
+			a:    Announcement{Address: "192.0.2.1:12121", Protocol: "unknown", Token: "secret"},
+		},
+		{
+			name: "missing-port",
+			a:    Announcement{Address: "192.0.2.1", Protocol: "sqp", Token: "secret"},
+		},
+		{
+			name: "zero-port",
+			a:    Announcement{Address: "192.0.2.1:0", Protocol: "sqp", Token: "secret"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := r.Register(tc.a, from)
+			if tc.address == "" {
+				require.Error(t, err)
+				if tc.err != nil {
+					require.True(t, errors.Is(err, tc.err), err)
+				}
+				return
+			}
+			require.NoError(t, err)
+
+			name := tc.a.Name
+			if name == "" {
+				name = tc.address
+			}
+			var found bool
+			for _, tg := range r.Targets() {
+				if tg.Name == name {
+					require.Equal(t, tc.address, tg.Address)
+					require.Equal(t, tc.a.Protocol, tg.Protocol)
+					found = true
+				}
+			}
+			require.True(t, found)
+		})
+	}
+
+	// Without the sender the host is required, and must be allowed.
+	require.Error(t, r.Register(Announcement{Address: ":12121", Protocol: "sqp", Token: "secret"}, nil))
+	err = r.Register(Announcement{Address: "198.51.100.10:12121", Protocol: "sqp", Token: "secret"}, nil)
+	require.True(t, errors.Is(err, ErrHostNotAllowed), err)
+
+	// Protocols which can't be created with the target options are invalid.
+	r, err = New(WithTokens("secret"))
+	require.NoError(t, err)
+	require.Error(t, r.Register(Announcement{Address: ":12121", Protocol: "exec", Token: "secret"}, from))
+}
+
+func TestRegisterKey(t *testing.T) {
+	r, err := New(WithTokens("secret"))
+	require.NoError(t, err)
+
+	announce := func(name, addr string, from string) error {
+		return r.Register(Announcement{Name: name, Address: addr, Protocol: "sqp", Token: "secret"}, net.ParseIP(from))
+	}
+
+	// Another server announcing the same name doesn't replace the first.
+	require.NoError(t, announce("eu-1", ":12121", "192.0.2.1"))
+	require.NoError(t, announce("eu-1", ":12121", "192.0.2.2"))
+	targets := r.Targets()
+	require.Len(t, targets, 2)
+	require.Equal(t, "192.0.2.1:12121", targets[0].Address)
+	require.Equal(t, "192.0.2.2:12121", targets[1].Address)
+
+	// Servers can rename themselves.
+	require.NoError(t, announce("eu-2", ":12121", "192.0.2.2"))
+	targets = r.Targets()
+	require.Len(t, targets, 2)
+	require.Equal(t, "eu-2", targets[1].Name)
+}
+
+func TestExpire(t *testing.T) {
+	now := time.Now()
+	r, err := New(WithTokens("secret"), WithTTL(time.Minute), WithMaxTargets(2))
+	require.NoError(t, err)
+	r.now = func() time.Time { return now }
+
+	ports := map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}
+	announce := func(name string) error {
+		return r.Register(Announcement{Name: name, Address: ":" + ports[name], Protocol: "sqp", Token: "secret"}, net.ParseIP("192.0.2.1"))
+	}
+
+	require.NoError(t, announce("b"))
+	require.NoError(t, announce("a"))
+	require.Equal(t, ErrTooManyTargets, announce("c"))
+
+	targets := r.Targets()
+	require.Len(t, targets, 2)
+	require.Equal(t, "a", targets[0].Name)
+	require.Equal(t, "b", targets[1].Name)
+
+	// Announcing refreshes a server, the other expires.
+	now = now.Add(time.Second * 40)
+	require.NoError(t, announce("a"))
+	now = now.Add(time.Second * 40)
+	targets = r.Targets()
+	require.Len(t, targets, 1)
+	require.Equal(t, "a", targets[0].Name)
+
+	// Expired servers make room for new ones.
+	require.NoError(t, announce("b"))
+	now = now.Add(time.Minute * 2)
+	require.NoError(t, announce("c"))
+	require.NoError(t, announce("d"))
+	require.Equal(t, 2, r.Len())
+}
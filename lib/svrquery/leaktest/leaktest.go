@@ -0,0 +1,105 @@
+// Package leaktest provides helpers for tests to detect goroutine and memory
+// leaks in long-running components such as pollers and servers.
+package leaktest
+
+import (
+	"bytes"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// DefaultTimeout is the time Check waits for goroutines to exit.
+var DefaultTimeout = time.Second * 5
+
+// ignored are functions which, if in the stack of a goroutine, identify it as
+// a goroutine of the runtime or test framework rather than a leak.
+var ignored = []string{
+	"testing.tRunner",
+	"testing.(*T).Run",
+	"testing.runTests",
+	"os/signal.signal_recv",
+	"runtime.ensureSigM",
+}
+
+// Check records the running goroutines and returns a function which fails t
+// if any goroutines started since are still running after DefaultTimeout.
+// It's intended to be deferred at the start of a test, so runs after any
+// other deferred shutdown:
+//
+//	defer leaktest.Check(t)()
+func Check(t testing.TB) func() {
+	before := goroutines()
+	return func() {
+		t.Helper()
+
+		deadline := time.Now().Add(DefaultTimeout)
+		for {
+			leaked := leaked(before)
+			if len(leaked) == 0 {
+				return
+			} else if time.Now().After(deadline) {
+				t.Errorf("%d leaked goroutine(s):\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+				return
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+	}
+}
+
+// HeapAlloc returns the bytes of allocated heap objects after a garbage
+// collection, so tests can assert memory use is bounded.
+func HeapAlloc() uint64 {
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+// leaked returns the stacks of the goroutines which aren't in before or ignored.
+func leaked(before map[string]string) []string {
+	var stacks []string
+	for id, stack := range goroutines() {
+		if _, ok := before[id]; !ok && !isIgnored(stack) {
+			stacks = append(stacks, stack)
+		}
+	}
+	sort.Strings(stacks)
+	return stacks
+}
+
+// isIgnored returns true if stack is of a goroutine which isn't a leak.
+func isIgnored(stack string) bool {
+	for _, f := range ignored {
+		if strings.Contains(stack, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// goroutines returns the stacks of all goroutines keyed by their ID.
+func goroutines() map[string]string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+
+	stacks := make(map[string]string)
+	for _, g := range bytes.Split(buf, []byte("\n\n")) {
+		// Each stack starts with "goroutine <id> [<state>]:".
+		fields := bytes.Fields(g)
+		if len(fields) < 2 {
+			continue
+		}
+		stacks[string(fields[1])] = string(g)
+	}
+	return stacks
+}
@@ -0,0 +1,53 @@
+package leaktest
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a testing.TB which records errors.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestCheck(t *testing.T) {
+	DefaultTimeout = time.Millisecond * 100
+	defer func() { DefaultTimeout = time.Second * 5 }()
+
+	// A goroutine which exits isn't a leak.
+	r := &recorder{TB: t}
+	check := Check(r)
+	done := make(chan struct{})
+	go func() { <-done }()
+	close(done)
+	check()
+	require.Empty(t, r.errors)
+
+	// A goroutine which doesn't exit is.
+	check = Check(r)
+	block := make(chan struct{})
+	defer close(block)
+	go func() { <-block }()
+	check()
+	require.Len(t, r.errors, 1)
+	require.Contains(t, r.errors[0], "1 leaked goroutine(s)")
+	require.Contains(t, r.errors[0], "leaktest.TestCheck")
+}
+
+func TestHeapAlloc(t *testing.T) {
+	before := HeapAlloc()
+	b := make([]byte, 8<<20)
+	require.True(t, HeapAlloc() > before+4<<20)
+	runtime.KeepAlive(b)
+}
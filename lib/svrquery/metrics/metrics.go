@@ -0,0 +1,145 @@
+// Package metrics defines the Prometheus metrics exported for query results
+// and writes them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+// Labels which identify the server of each metric.
+const (
+	LabelServer   = "server"
+	LabelProtocol = "protocol"
+	LabelAddress  = "address"
+	LabelMap      = "map"
+)
+
+// Metric describes an exported metric.
+type Metric struct {
+	Name string
+	Help string
+	Type string
+	// Labels are the labels in addition to the server labels.
+	Labels []string
+}
+
+var (
+	// Up is 1 if the last query of a server succeeded, otherwise 0.
+	Up = Metric{Name: "svrquery_up", Help: "Whether the last query of the server succeeded.", Type: "gauge"}
+
+	// Players is the number of players on a server.
+	Players = Metric{Name: "svrquery_players", Help: "Number of players on the server.", Type: "gauge"}
+
+	// MaxPlayers is the maximum number of players of a server.
+	MaxPlayers = Metric{Name: "svrquery_max_players", Help: "Maximum number of players of the server.", Type: "gauge"}
+
+	// QueryDuration is the time taken by the last query of a server.
+	QueryDuration = Metric{Name: "svrquery_query_duration_seconds", Help: "Time taken by the last query of the server.", Type: "gauge"}
+
+	// BytesSent is the number of bytes sent by the last query of a server.
+	BytesSent = Metric{Name: "svrquery_query_sent_bytes", Help: "Bytes sent by the last query of the server.", Type: "gauge"}
+
+	// BytesReceived is the number of bytes received by the last query of a
+	// server.
+	BytesReceived = Metric{Name: "svrquery_query_received_bytes", Help: "Bytes received by the last query of the server.", Type: "gauge"}
+
+	// MapInfo is 1 with the current map of a server as a label.
+	MapInfo = Metric{Name: "svrquery_map_info", Help: "Current map of the server.", Type: "gauge", Labels: []string{LabelMap}}
+)
+
+// All returns all the exported metrics.
+func All() []Metric {
+	return []Metric{Up, Players, MaxPlayers, QueryDuration, BytesSent, BytesReceived, MapInfo}
+}
+
+// sample is a value of a metric for a result.
+type sample struct {
+	labels []string
+	value  float64
+}
+
+// samples returns the samples of m for r, or nil if it has none.
+func samples(m Metric, r poller.Result) []sample {
+	// Stale results still report the last good response.
+	hasResponse := r.Response != nil && (r.Err == nil || r.Stale)
+	switch m.Name {
+	case Up.Name:
+		if r.Err == nil {
+			return []sample{{value: 1}}
+		}
+		return []sample{{value: 0}}
+	case QueryDuration.Name:
+		if r.Err == nil {
+			return []sample{{value: r.Latency.Seconds()}}
+		}
+	case BytesSent.Name:
+		return []sample{{value: float64(r.Stats.BytesSent)}}
+	case BytesReceived.Name:
+		return []sample{{value: float64(r.Stats.BytesReceived)}}
+	case Players.Name:
+		if hasResponse {
+			return []sample{{value: float64(r.Response.NumClients())}}
+		}
+	case MaxPlayers.Name:
+		if hasResponse {
+			return []sample{{value: float64(r.Response.MaxClients())}}
+		}
+	case MapInfo.Name:
+		if _, ok := r.Response.(protocol.Mapper); (ok || r.Fields != nil) && hasResponse {
+			return []sample{{labels: []string{r.MapName()}, value: 1}}
+		}
+	}
+	return nil
+}
+
+// Write writes the metrics for results to w in the Prometheus text
+// exposition format.
+func Write(w io.Writer, results []poller.Result) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range All() {
+		fmt.Fprintf(bw, "# HELP %s %s\n", m.Name, m.Help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", m.Name, m.Type)
+		for _, r := range results {
+			name := r.Target.Name
+			if name == "" {
+				name = r.Target.Address
+			}
+
+			for _, s := range samples(m, r) {
+				bw.WriteString(m.Name)
+				bw.WriteByte('{')
+				writeLabel(bw, LabelServer, name)
+				bw.WriteByte(',')
+				writeLabel(bw, LabelProtocol, r.Target.Protocol)
+				bw.WriteByte(',')
+				writeLabel(bw, LabelAddress, r.Target.Address)
+				for i, l := range m.Labels {
+					bw.WriteByte(',')
+					writeLabel(bw, l, s.labels[i])
+				}
+				bw.WriteString("} ")
+				bw.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+				bw.WriteByte('\n')
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// labelEscaper escapes label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// writeLabel writes the label name with value to w.
+func writeLabel(w *bufio.Writer, name, value string) {
+	w.WriteString(name)
+	w.WriteString(`="`)
+	_, _ = labelEscaper.WriteString(w, value)
+	w.WriteByte('"')
+}
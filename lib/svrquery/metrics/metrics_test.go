@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	results := []poller.Result{
+		{
+			Target:  poller.Target{Name: "eu-1", Protocol: "sqp", Address: "10.0.0.1:12121"},
+			Latency: time.Millisecond * 5,
+			Response: &sqp.QueryResponse{ServerInfo: &sqp.ServerInfoChunk{
+				CurrentPlayers: 3,
+				MaxPlayers:     8,
+				Map:            `Old "Town"`,
+			}},
+			Stats: svrquery.QueryStats{BytesSent: 13, BytesReceived: 40},
+		},
+		{
+			Target: poller.Target{Protocol: "sqp", Address: "10.0.0.2:12121"},
+			Err:    errors.New("timeout"),
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, results))
+	require.Equal(t, `# HELP svrquery_up Whether the last query of the server succeeded.
+# TYPE svrquery_up gauge
+svrquery_up{server="eu-1",protocol="sqp",address="10.0.0.1:12121"} 1
+svrquery_up{server="10.0.0.2:12121",protocol="sqp",address="10.0.0.2:12121"} 0
+# HELP svrquery_players Number of players on the server.
+# TYPE svrquery_players gauge
+svrquery_players{server="eu-1",protocol="sqp",address="10.0.0.1:12121"} 3
+# HELP svrquery_max_players Maximum number of players of the server.
+# TYPE svrquery_max_players gauge
+svrquery_max_players{server="eu-1",protocol="sqp",address="10.0.0.1:12121"} 8
+# HELP svrquery_query_duration_seconds Time taken by the last query of the server.
+# TYPE svrquery_query_duration_seconds gauge
+svrquery_query_duration_seconds{server="eu-1",protocol="sqp",address="10.0.0.1:12121"} 0.005
+# HELP svrquery_query_sent_bytes Bytes sent by the last query of the server.
+# TYPE svrquery_query_sent_bytes gauge
+svrquery_query_sent_bytes{server="eu-1",protocol="sqp",address="10.0.0.1:12121"} 13
+svrquery_query_sent_bytes{server="10.0.0.2:12121",protocol="sqp",address="10.0.0.2:12121"} 0
+# HELP svrquery_query_received_bytes Bytes received by the last query of the server.
+# TYPE svrquery_query_received_bytes gauge
+svrquery_query_received_bytes{server="eu-1",protocol="sqp",address="10.0.0.1:12121"} 40
+svrquery_query_received_bytes{server="10.0.0.2:12121",protocol="sqp",address="10.0.0.2:12121"} 0
+# HELP svrquery_map_info Current map of the server.
+# TYPE svrquery_map_info gauge
+svrquery_map_info{server="eu-1",protocol="sqp",address="10.0.0.1:12121",map="Old \"Town\""} 1
+`, buf.String())
+}
@@ -0,0 +1,107 @@
+package svrquery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+const (
+	// MinMTU is the minimum MTU accepted by WithMTU, the minimum datagram
+	// size all IPv4 hosts must accept.
+	MinMTU = 576
+
+	// ipv4Overhead and ipv6Overhead are the sizes of the IP and UDP headers
+	// of packets without options or extension headers.
+	ipv4Overhead = 20 + 8
+	ipv6Overhead = 40 + 8
+)
+
+// ErrDontFragmentUnsupported is returned by NewClient if WithDontFragment is
+// used on a platform or connection which doesn't support it.
+var ErrDontFragmentUnsupported = errors.New("don't fragment not supported")
+
+// dontFragment is the don't fragment setting of a client.
+type dontFragment int
+
+const (
+	// dfDefault leaves the setting of the operating system unchanged.
+	dfDefault dontFragment = iota
+	dfOn
+	dfOff
+)
+
+// WithMTU sets the MTU of the path to the server, for example when querying
+// through a WireGuard or GRE tunnel with a reduced MTU. The max packet size
+// is set to the MTU less the IP and UDP headers for the address family of the
+// server, overriding WithMaxPacketSize, so protocols which limit packet sizes
+// don't send packets which would be fragmented.
+func WithMTU(mtu int) Option {
+	return func(c *Client) error {
+		if mtu < MinMTU || mtu > 65535 {
+			return fmt.Errorf("mtu %d must be between %d and 65535", mtu, MinMTU)
+		}
+		c.mtu = mtu
+		return nil
+	}
+}
+
+// WithDontFragment sets or clears the don't fragment flag of the packets sent
+// by the client, overriding the path MTU discovery setting of the operating
+// system. When set, packets larger than the MTU of the path, as learned by
+// the operating system, fail to send with an error instead of being
+// fragmented and silently lost by paths which drop fragments. It's only
+// supported on Linux with the default dialer or WithPacketConn.
+func WithDontFragment(enabled bool) Option {
+	return func(c *Client) error {
+		c.df = dfOff
+		if enabled {
+			c.df = dfOn
+		}
+		return nil
+	}
+}
+
+// applyMTU applies the MTU and don't fragment settings of c to its connection.
+func (c *Client) applyMTU() error {
+	if c.mtu == 0 && c.df == dfDefault {
+		return nil
+	}
+
+	var ipv6 bool
+	if ua, ok := c.c.RemoteAddr().(*net.UDPAddr); ok {
+		ipv6 = ua.IP.To4() == nil
+	}
+
+	if c.mtu > 0 {
+		c.maxPkt = c.mtu - ipv4Overhead
+		if ipv6 {
+			c.maxPkt = c.mtu - ipv6Overhead
+		}
+	}
+
+	if c.df == dfDefault {
+		return nil
+	}
+
+	var sc syscall.Conn
+	switch conn := c.c.(type) {
+	case syscall.Conn:
+		sc = conn
+	case *packetConn:
+		if s, ok := conn.PacketConn.(syscall.Conn); ok {
+			sc = s
+		}
+	}
+	if sc == nil {
+		return ErrDontFragmentUnsupported
+	}
+
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	return setDontFragment(rc, ipv6, c.df == dfOn)
+}
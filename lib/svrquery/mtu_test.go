@@ -0,0 +1,40 @@
+package svrquery
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientMTU(t *testing.T) {
+	for _, mtu := range []int{MinMTU - 1, 65536} {
+		_, err := NewClient("sqp", "127.0.0.1:12121", WithMTU(mtu))
+		require.Error(t, err, mtu)
+	}
+
+	c, err := NewClient("sqp", "127.0.0.1:12121", WithMaxPacketSize(4096), WithMTU(1420))
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, 1420-28, c.maxPkt)
+
+	pc, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skip("ipv6 not available:", err)
+	}
+	defer pc.Close()
+
+	c6, err := NewClient("sqp", pc.LocalAddr().String(), WithMTU(1420))
+	require.NoError(t, err)
+	defer c6.Close()
+	require.Equal(t, 1420-48, c6.maxPkt)
+}
+
+func TestClientDontFragmentUnsupportedConn(t *testing.T) {
+	// Connections which don't expose their socket can't be configured.
+	_, err := NewClient("sqp", "127.0.0.1:12121", WithDontFragment(true), WithDialer(func(network, address string) (net.Conn, error) {
+		c, _ := net.Pipe()
+		return c, nil
+	}))
+	require.Equal(t, ErrDontFragmentUnsupported, err)
+}
@@ -0,0 +1,202 @@
+package notify
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+)
+
+// targetKey identifies a target across results.
+type targetKey struct {
+	name     string
+	protocol string
+	address  string
+}
+
+// key returns the key of the target of r.
+func key(r poller.Result) targetKey {
+	return targetKey{name: r.Target.Name, protocol: r.Target.Protocol, address: r.Target.Address}
+}
+
+// state is the last known state of a target.
+type state struct {
+	known   bool
+	down    bool
+	full    bool
+	mp      string
+	pending int
+}
+
+// DetectorOption represents a Detector option.
+type DetectorOption func(*Detector) error
+
+// WithConfirmations sets the number of consecutive results which must agree
+// before a target is considered down or up, defaulting to 1. Higher values
+// suppress events caused by transient packet loss.
+func WithConfirmations(n int) DetectorOption {
+	return func(d *Detector) error {
+		if n < 1 {
+			return errors.New("confirmations must be at least 1")
+		}
+		d.confirmations = n
+		return nil
+	}
+}
+
+// WithTargetConfirmations sets the number of confirmations for the target
+// named name, overriding WithConfirmations.
+func WithTargetConfirmations(name string, n int) DetectorOption {
+	return func(d *Detector) error {
+		if n < 1 {
+			return errors.New("confirmations must be at least 1")
+		}
+		d.targetConfirmations[name] = n
+		return nil
+	}
+}
+
+// Detector detects state transitions between successive poll results.
+type Detector struct {
+	mtx                 sync.Mutex
+	states              map[targetKey]*state
+	confirmations       int
+	targetConfirmations map[string]int
+}
+
+// NewDetector returns a new Detector configured with options.
+func NewDetector(options ...DetectorOption) (*Detector, error) {
+	d := &Detector{
+		states:              make(map[targetKey]*state),
+		confirmations:       1,
+		targetConfirmations: make(map[string]int),
+	}
+	for _, o := range options {
+		if err := o(d); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Detect returns the events caused by r given the previous results for the
+// same target, identified by its name, protocol and address.
+//
+// A target is only considered down or up once the configured number of
+// consecutive results agree. The first result for a target which is up
+// establishes its state without generating events. Stale results don't
+// change the state.
+func (d *Detector) Detect(r poller.Result) []Event {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if r.Stale {
+		// The last good response is recent enough for the state to be
+		// considered unchanged.
+		return nil
+	}
+
+	k := key(r)
+	prev, ok := d.states[k]
+	if !ok {
+		prev = &state{}
+		d.states[k] = prev
+	}
+
+	down := r.Err != nil
+	switch {
+	case prev.known && down == prev.down, !prev.known && !down:
+		// Result agrees with the current state or is the initial up.
+		prev.pending = 0
+	default:
+		prev.pending++
+		if prev.pending < d.confirmationsFor(r.Target.Name) {
+			return nil
+		}
+		prev.pending = 0
+	}
+
+	e := Event{
+		Name:     r.Target.Name,
+		Protocol: r.Target.Protocol,
+		Address:  r.Target.Address,
+		Time:     r.Time,
+	}
+
+	if down {
+		if prev.known && prev.down {
+			return nil
+		}
+		prev.known = true
+		prev.down = true
+		e.Type = EventDown
+		e.Error = r.Err.Error()
+		return []Event{e}
+	}
+
+	e.NumClients = r.Response.NumClients()
+	e.MaxClients = r.Response.MaxClients()
+	full := e.MaxClients > 0 && e.NumClients >= e.MaxClients
+	e.Map = r.MapName()
+
+	var events []Event
+	add := func(t EventType) {
+		ev := e
+		ev.Type = t
+		events = append(events, ev)
+	}
+
+	if prev.known {
+		if prev.down {
+			add(EventUp)
+		}
+		if full != prev.full {
+			if full {
+				add(EventFull)
+			} else {
+				add(EventNotFull)
+			}
+		}
+		if prev.mp != "" && e.Map != "" && prev.mp != e.Map {
+			e.PreviousMap = prev.mp
+			add(EventMapChange)
+		}
+	}
+
+	prev.known = true
+	prev.down = false
+	prev.full = full
+	if e.Map != "" {
+		prev.mp = e.Map
+	}
+
+	return events
+}
+
+// Prune releases the state of targets which aren't in results, the results
+// of the latest poll, so targets no longer polled don't use memory. It's a
+// poller.PollHandler. A target which is polled again is treated as new.
+func (d *Detector) Prune(results []poller.Result) {
+	current := make(map[targetKey]bool, len(results))
+	for _, r := range results {
+		current[key(r)] = true
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	for k := range d.states {
+		if !current[k] {
+			delete(d.states, k)
+		}
+	}
+}
+
+// confirmationsFor returns the number of confirmations required for
+// the target named name.
+func (d *Detector) confirmationsFor(name string) int {
+	if n, ok := d.targetConfirmations[name]; ok {
+		return n
+	}
+	return d.confirmations
+}
@@ -0,0 +1,181 @@
+package notify
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/stretchr/testify/require"
+)
+
+func testResult(players, max uint16, mp string) poller.Result {
+	return poller.Result{
+		Target: poller.Target{Name: "test", Protocol: "sqp", Address: "127.0.0.1:12121"},
+		Response: &sqp.QueryResponse{ServerInfo: &sqp.ServerInfoChunk{
+			CurrentPlayers: players,
+			MaxPlayers:     max,
+			Map:            mp,
+		}},
+	}
+}
+
+func testDown() poller.Result {
+	r := testResult(0, 0, "")
+	r.Response = nil
+	r.Err = errors.New("i/o timeout")
+	return r
+}
+
+func TestDetector(t *testing.T) {
+	cases := []struct {
+		name    string
+		results []poller.Result
+		events  [][]EventType
+	}{
+		{
+			name:    "initial-up",
+			results: []poller.Result{testResult(1, 2, "a")},
+			events:  [][]EventType{nil},
+		},
+		{
+			name:    "initial-down",
+			results: []poller.Result{testDown(), testDown()},
+			events:  [][]EventType{{EventDown}, nil},
+		},
+		{
+			name:    "down-up",
+			results: []poller.Result{testResult(1, 2, "a"), testDown(), testResult(1, 2, "a")},
+			events:  [][]EventType{nil, {EventDown}, {EventUp}},
+		},
+		{
+			name:    "stale",
+			results: []poller.Result{testResult(1, 2, "a"), testStale(), testDown(), testResult(1, 2, "a")},
+			events:  [][]EventType{nil, nil, {EventDown}, {EventUp}},
+		},
+		{
+			name:    "full",
+			results: []poller.Result{testResult(1, 2, "a"), testResult(2, 2, "a"), testResult(2, 2, "a"), testResult(1, 2, "a")},
+			events:  [][]EventType{nil, {EventFull}, nil, {EventNotFull}},
+		},
+		{
+			name:    "map-change",
+			results: []poller.Result{testResult(1, 2, "a"), testResult(1, 2, "b"), testResult(1, 2, "")},
+			events:  [][]EventType{nil, {EventMapChange}, nil},
+		},
+		{
+			name:    "up-full-map-change",
+			results: []poller.Result{testResult(1, 2, "a"), testDown(), testResult(2, 2, "b")},
+			events:  [][]EventType{nil, {EventDown}, {EventUp, EventFull, EventMapChange}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewDetector()
+			require.NoError(t, err)
+			for i, r := range tc.results {
+				var types []EventType
+				for _, e := range d.Detect(r) {
+					types = append(types, e.Type)
+					require.Equal(t, "test", e.Name)
+					require.NotEmpty(t, e.Message())
+				}
+				require.Equal(t, tc.events[i], types, "result %d", i)
+			}
+		})
+	}
+}
+
+func testStale() poller.Result {
+	r := testResult(1, 2, "a")
+	r.Err = errors.New("i/o timeout")
+	r.Stale = true
+	return r
+}
+
+func TestDetectorMapChange(t *testing.T) {
+	d, err := NewDetector()
+	require.NoError(t, err)
+	require.Empty(t, d.Detect(testResult(1, 2, "a")))
+	events := d.Detect(testResult(1, 2, "b"))
+	require.Len(t, events, 1)
+	require.Equal(t, "a", events[0].PreviousMap)
+	require.Equal(t, "b", events[0].Map)
+	require.Equal(t, "test changed map from a to b", events[0].Message())
+}
+
+func TestDetectorPrune(t *testing.T) {
+	d, err := NewDetector()
+	require.NoError(t, err)
+
+	other := testResult(1, 2, "a")
+	other.Target.Address = "127.0.0.1:12122"
+	require.Empty(t, d.Detect(testResult(1, 2, "a")))
+	require.Len(t, d.Detect(testDown()), 1)
+	require.Empty(t, d.Detect(other))
+	require.Len(t, d.states, 2)
+
+	// Targets with the same name are tracked separately.
+	d.Prune([]poller.Result{other})
+	require.Len(t, d.states, 1)
+
+	// A target polled again after being pruned is new, so isn't back up.
+	require.Empty(t, d.Detect(testResult(1, 2, "a")))
+	d.Prune(nil)
+	require.Empty(t, d.states)
+}
+
+func TestDetectorConfirmations(t *testing.T) {
+	up, down := testResult(1, 2, "a"), testDown()
+	cases := []struct {
+		name    string
+		options []DetectorOption
+		results []poller.Result
+		events  [][]EventType
+	}{
+		{
+			name:    "single-loss",
+			options: []DetectorOption{WithConfirmations(2)},
+			results: []poller.Result{up, down, up, down},
+			events:  [][]EventType{nil, nil, nil, nil},
+		},
+		{
+			name:    "confirmed",
+			options: []DetectorOption{WithConfirmations(2)},
+			results: []poller.Result{up, down, down, down, up, up},
+			events:  [][]EventType{nil, nil, {EventDown}, nil, nil, {EventUp}},
+		},
+		{
+			name:    "initial-down",
+			options: []DetectorOption{WithConfirmations(2)},
+			results: []poller.Result{down, up, down, down},
+			events:  [][]EventType{nil, nil, nil, {EventDown}},
+		},
+		{
+			name:    "target-override",
+			options: []DetectorOption{WithConfirmations(3), WithTargetConfirmations("test", 1)},
+			results: []poller.Result{up, down},
+			events:  [][]EventType{nil, {EventDown}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewDetector(tc.options...)
+			require.NoError(t, err)
+			for i, r := range tc.results {
+				var types []EventType
+				for _, e := range d.Detect(r) {
+					types = append(types, e.Type)
+				}
+				require.Equal(t, tc.events[i], types, "result %d", i)
+			}
+		})
+	}
+
+	_, err := NewDetector(WithConfirmations(0))
+	require.Error(t, err)
+	_, err = NewDetector(WithTargetConfirmations("test", 0))
+	require.Error(t, err)
+}
@@ -0,0 +1,3 @@
+// Package notify detects state transitions in poll results, such as a server
+// going down, becoming full or changing map, and sends notifications of them.
+package notify
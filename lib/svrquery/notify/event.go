@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType is the type of a state transition.
+type EventType string
+
+const (
+	// EventDown is sent when a server stops responding to queries.
+	EventDown EventType = "down"
+
+	// EventUp is sent when a server which was down responds.
+	EventUp EventType = "up"
+
+	// EventFull is sent when a server becomes full.
+	EventFull EventType = "full"
+
+	// EventNotFull is sent when a full server has free slots again.
+	EventNotFull EventType = "not_full"
+
+	// EventMapChange is sent when a server changes map.
+	EventMapChange EventType = "map_change"
+
+	// EventDrift is sent when a canary starts differing from its baseline,
+	// see poller.WithCanaries.
+	EventDrift EventType = "drift"
+
+	// EventDriftResolved is sent when a canary no longer differs from its
+	// baseline.
+	EventDriftResolved EventType = "drift_resolved"
+)
+
+// Event is a state transition of a server.
+type Event struct {
+	Type        EventType `json:"type"`
+	Name        string    `json:"name"`
+	Protocol    string    `json:"protocol"`
+	Address     string    `json:"address"`
+	Time        time.Time `json:"time"`
+	NumClients  int64     `json:"num_clients"`
+	MaxClients  int64     `json:"max_clients"`
+	Map         string    `json:"map,omitempty"`
+	PreviousMap string    `json:"previous_map,omitempty"`
+	Error       string    `json:"error,omitempty"`
+
+	// Drift is the kind of drift of a canary and Value and Baseline the
+	// values of the canary and its baseline which were compared.
+	Drift    string `json:"drift,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Baseline string `json:"baseline,omitempty"`
+}
+
+// Message returns a human readable description of the event.
+func (e Event) Message() string {
+	switch e.Type {
+	case EventDown:
+		return fmt.Sprintf("%s is down: %s", e.Name, e.Error)
+	case EventUp:
+		return fmt.Sprintf("%s is up with %d/%d players", e.Name, e.NumClients, e.MaxClients)
+	case EventFull:
+		return fmt.Sprintf("%s is full with %d/%d players", e.Name, e.NumClients, e.MaxClients)
+	case EventNotFull:
+		return fmt.Sprintf("%s has free slots with %d/%d players", e.Name, e.NumClients, e.MaxClients)
+	case EventMapChange:
+		return fmt.Sprintf("%s changed map from %s to %s", e.Name, e.PreviousMap, e.Map)
+	case EventDrift:
+		return fmt.Sprintf("%s %s drifted to %s from baseline %s", e.Name, e.Drift, e.Value, e.Baseline)
+	case EventDriftResolved:
+		return fmt.Sprintf("%s %s matches baseline with %s", e.Name, e.Drift, e.Value)
+	}
+	return fmt.Sprintf("%s %s", e.Name, e.Type)
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/leaktest"
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchLeaks(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	var posts int32
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+	}))
+	defer hs.Close()
+
+	// Alternate between full and free slots so every poll generates an event.
+	var queries int32
+	s, err := server.New(
+		server.WithAddress("127.0.0.1:0"),
+		server.WithProtocol("sqp"),
+		server.WithStateFunc(func() common.QueryState {
+			n := atomic.AddInt32(&queries, 1)
+			return common.QueryState{CurrentPlayers: n % 2, MaxPlayers: 1}
+		}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	wh, err := NewWebhook(hs.URL, WithHTTPClient(&http.Client{Transport: tr, Timeout: time.Second}))
+	require.NoError(t, err)
+
+	d, err := NewDetector()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	p, err := poller.New(
+		poller.WithInterval(time.Millisecond*5),
+		poller.WithTargets(poller.Target{Name: "test", Protocol: "sqp", Address: s.Addr().String()}),
+		poller.WithHandler(Handler(ctx, d, nil, wh)),
+	)
+	require.NoError(t, err)
+	require.Equal(t, context.DeadlineExceeded, p.Run(ctx))
+	require.True(t, atomic.LoadInt32(&posts) > 5)
+
+	// The detector only holds the state of each target.
+	require.Len(t, d.states, 1)
+}
@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+)
+
+// ErrorHandler is called with errors returned by a Notifier.
+type ErrorHandler func(e Event, err error)
+
+// Handler returns a poller.Handler which detects events in results using d and
+// sends them to each of notifiers. Errors are passed to errh if not nil.
+func Handler(ctx context.Context, d *Detector, errh ErrorHandler, notifiers ...Notifier) poller.Handler {
+	return func(r poller.Result) {
+		for _, e := range d.Detect(r) {
+			for _, n := range notifiers {
+				if err := n.Notify(ctx, e); err != nil && errh != nil {
+					errh(e, err)
+				}
+			}
+		}
+	}
+}
+
+// DriftHandler returns a poller.DriftHandler which sends drift of canaries as
+// events to each of notifiers. Errors are passed to errh if not nil.
+func DriftHandler(ctx context.Context, errh ErrorHandler, notifiers ...Notifier) poller.DriftHandler {
+	return func(d poller.Drift) {
+		e := DriftEvent(d)
+		for _, n := range notifiers {
+			if err := n.Notify(ctx, e); err != nil && errh != nil {
+				errh(e, err)
+			}
+		}
+	}
+}
+
+// DriftEvent returns the event for the drift d of a canary.
+func DriftEvent(d poller.Drift) Event {
+	typ := EventDrift
+	if d.Resolved {
+		typ = EventDriftResolved
+	}
+
+	return Event{
+		Type:     typ,
+		Name:     d.Canary.Name,
+		Protocol: d.Canary.Protocol,
+		Address:  d.Canary.Address,
+		Time:     d.Time,
+		Drift:    string(d.Kind),
+		Value:    d.Value,
+		Baseline: d.Baseline,
+	}
+}
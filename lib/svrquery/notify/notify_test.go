@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/poller"
+	"github.com/stretchr/testify/require"
+)
+
+type testNotifier struct {
+	events []Event
+	err    error
+}
+
+func (n *testNotifier) Notify(ctx context.Context, e Event) error {
+	n.events = append(n.events, e)
+	return n.err
+}
+
+func TestHandler(t *testing.T) {
+	ok := &testNotifier{}
+	failing := &testNotifier{err: errors.New("failed")}
+	d, err := NewDetector()
+	require.NoError(t, err)
+
+	var errs []error
+	h := Handler(context.Background(), d, func(e Event, err error) {
+		errs = append(errs, err)
+	}, ok, failing)
+
+	h(testResult(1, 2, "a"))
+	h(testDown())
+	h(testResult(1, 2, "a"))
+
+	require.Len(t, ok.events, 2)
+	require.Equal(t, EventDown, ok.events[0].Type)
+	require.Equal(t, EventUp, ok.events[1].Type)
+	require.Len(t, failing.events, 2)
+	require.Len(t, errs, 2)
+}
+
+func TestDriftHandler(t *testing.T) {
+	n := &testNotifier{err: errors.New("failed")}
+	var errs []error
+	h := DriftHandler(context.Background(), func(e Event, err error) {
+		errs = append(errs, err)
+	}, n)
+
+	canary := poller.Target{Name: "eu-9", Protocol: "sqp", Address: "192.0.2.1:12121"}
+	h(poller.Drift{Kind: poller.DriftBuild, Canary: canary, Value: "1.2.4", Baseline: "1.2.3"})
+	h(poller.Drift{Kind: poller.DriftBuild, Canary: canary, Value: "1.2.3", Baseline: "1.2.3", Resolved: true})
+
+	require.Len(t, n.events, 2)
+	require.Len(t, errs, 2)
+	require.Equal(t, EventDrift, n.events[0].Type)
+	require.Equal(t, "eu-9", n.events[0].Name)
+	require.Equal(t, "192.0.2.1:12121", n.events[0].Address)
+	require.Equal(t, "build", n.events[0].Drift)
+	require.Equal(t, "eu-9 build drifted to 1.2.4 from baseline 1.2.3", n.events[0].Message())
+	require.Equal(t, EventDriftResolved, n.events[1].Type)
+	require.Equal(t, "eu-9 build matches baseline with 1.2.3", n.events[1].Message())
+}
@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"errors"
+)
+
+// DefaultQueueSize is the default number of events a Queue buffers.
+const DefaultQueueSize = 100
+
+// ErrQueueFull is returned by Queue.Notify if the event was dropped because
+// the queue is full.
+var ErrQueueFull = errors.New("notification queue full")
+
+// Queue is a Notifier which buffers events and sends them to its notifiers
+// from Run, so slow notifiers such as webhooks don't delay the caller e.g.
+// the handler of a poller. Events are dropped if the queue is full.
+type Queue struct {
+	events    chan Event
+	errh      ErrorHandler
+	notifiers []Notifier
+}
+
+// NewQueue returns a Queue which buffers up to size events for notifiers.
+// Errors returned by notifiers are passed to errh if not nil.
+func NewQueue(size int, errh ErrorHandler, notifiers ...Notifier) (*Queue, error) {
+	if size < 1 {
+		return nil, errors.New("queue size must be at least 1")
+	}
+
+	return &Queue{
+		events:    make(chan Event, size),
+		errh:      errh,
+		notifiers: notifiers,
+	}, nil
+}
+
+// Notify implements Notifier, queuing e without waiting for it to be sent.
+func (q *Queue) Notify(ctx context.Context, e Event) error {
+	select {
+	case q.events <- e:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run sends queued events to the notifiers until ctx is done, returning
+// ctx.Err(). Events still queued are dropped.
+func (q *Queue) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e := <-q.events:
+			for _, n := range q.notifiers {
+				if err := n.Notify(ctx, e); err != nil && q.errh != nil {
+					q.errh(e, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// blockingNotifier is a Notifier which blocks until released.
+type blockingNotifier struct {
+	release chan struct{}
+	events  chan Event
+}
+
+func (n *blockingNotifier) Notify(ctx context.Context, e Event) error {
+	<-n.release
+	n.events <- e
+	return errors.New("failed")
+}
+
+func TestQueue(t *testing.T) {
+	_, err := NewQueue(0, nil)
+	require.Error(t, err)
+
+	n := &blockingNotifier{release: make(chan struct{}), events: make(chan Event, 10)}
+	errs := make(chan error, 10)
+	q, err := NewQueue(2, func(e Event, err error) {
+		errs <- err
+	}, n)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- q.Run(ctx) }()
+
+	// The first event is taken by Run, which blocks in the notifier, the
+	// next two are buffered and the last is dropped without blocking.
+	require.NoError(t, q.Notify(ctx, Event{Name: "a"}))
+	require.Eventually(t, func() bool { return len(q.events) == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, q.Notify(ctx, Event{Name: "b"}))
+	require.NoError(t, q.Notify(ctx, Event{Name: "c"}))
+	require.Equal(t, ErrQueueFull, q.Notify(ctx, Event{Name: "d"}))
+
+	close(n.release)
+	for _, name := range []string{"a", "b", "c"} {
+		require.Equal(t, name, (<-n.events).Name)
+		require.EqualError(t, <-errs, "failed")
+	}
+
+	cancel()
+	require.Equal(t, context.Canceled, <-done)
+}
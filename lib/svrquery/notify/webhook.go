@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// DefaultTemplate is the default template used to generate the message
+	// of Slack and Discord payloads.
+	DefaultTemplate = "{{.Message}}"
+
+	// DefaultWebhookTimeout is the default timeout for webhook requests.
+	DefaultWebhookTimeout = time.Second * 10
+)
+
+// Format is the payload format of a webhook.
+type Format string
+
+const (
+	// FormatJSON posts the Event as JSON.
+	FormatJSON Format = "json"
+
+	// FormatSlack posts a Slack incoming webhook message.
+	FormatSlack Format = "slack"
+
+	// FormatDiscord posts a Discord webhook message.
+	FormatDiscord Format = "discord"
+)
+
+// ParseFormat returns the Format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatJSON, FormatSlack, FormatDiscord:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown webhook format %q", s)
+}
+
+// Notifier is an interface which is implemented by types which deliver events.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// WebhookOption represents a Webhook option.
+type WebhookOption func(*Webhook) error
+
+// WithFormat sets the payload format, defaulting to FormatJSON.
+func WithFormat(f Format) WebhookOption {
+	return func(w *Webhook) error {
+		if _, err := ParseFormat(string(f)); err != nil {
+			return err
+		}
+		w.format = f
+		return nil
+	}
+}
+
+// WithTemplate sets the text/template used to generate the message of Slack
+// and Discord payloads. The template is executed with the Event.
+func WithTemplate(text string) WebhookOption {
+	return func(w *Webhook) (err error) {
+		w.tmpl, err = template.New("message").Parse(text)
+		return err
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to post events.
+func WithHTTPClient(c *http.Client) WebhookOption {
+	return func(w *Webhook) error {
+		w.client = c
+		return nil
+	}
+}
+
+// Webhook is a Notifier which posts events to a URL.
+type Webhook struct {
+	url    string
+	format Format
+	tmpl   *template.Template
+	client *http.Client
+}
+
+// NewWebhook returns a Webhook which posts events to url.
+func NewWebhook(url string, options ...WebhookOption) (*Webhook, error) {
+	w := &Webhook{
+		url:    url,
+		format: FormatJSON,
+		tmpl:   template.Must(template.New("message").Parse(DefaultTemplate)),
+		client: &http.Client{Timeout: DefaultWebhookTimeout},
+	}
+	for _, o := range options {
+		if err := o(w); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Notify implements Notifier.
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	body, err := w.payload(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned status %d", w.url, resp.StatusCode)
+	}
+	return nil
+}
+
+// payload returns the request body for e.
+func (w *Webhook) payload(e Event) ([]byte, error) {
+	if w.format == FormatJSON {
+		return json.Marshal(e)
+	}
+
+	var msg strings.Builder
+	if err := w.tmpl.Execute(&msg, e); err != nil {
+		return nil, err
+	}
+
+	if w.format == FormatSlack {
+		return json.Marshal(struct {
+			Text string `json:"text"`
+		}{Text: msg.String()})
+	}
+
+	return json.Marshal(struct {
+		Content string `json:"content"`
+	}{Content: msg.String()})
+}
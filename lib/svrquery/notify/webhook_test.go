@@ -0,0 +1,81 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	e := Event{Type: EventFull, Name: "eu-1", NumClients: 2, MaxClients: 2}
+
+	cases := []struct {
+		name    string
+		options []WebhookOption
+		expect  map[string]interface{}
+	}{
+		{
+			name: "json",
+			expect: map[string]interface{}{
+				"type":        "full",
+				"name":        "eu-1",
+				"protocol":    "",
+				"address":     "",
+				"time":        "0001-01-01T00:00:00Z",
+				"num_clients": float64(2),
+				"max_clients": float64(2),
+			},
+		},
+		{
+			name:    "slack",
+			options: []WebhookOption{WithFormat(FormatSlack)},
+			expect:  map[string]interface{}{"text": "eu-1 is full with 2/2 players"},
+		},
+		{
+			name:    "discord-template",
+			options: []WebhookOption{WithFormat(FormatDiscord), WithTemplate("{{.Name}}: {{.Type}}")},
+			expect:  map[string]interface{}{"content": "eu-1: full"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got map[string]interface{}
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodPost, r.Method)
+				require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				b, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.NoError(t, json.Unmarshal(b, &got))
+			}))
+			defer s.Close()
+
+			w, err := NewWebhook(s.URL, tc.options...)
+			require.NoError(t, err)
+			require.NoError(t, w.Notify(context.Background(), e))
+			require.Equal(t, tc.expect, got)
+		})
+	}
+}
+
+func TestWebhookErrors(t *testing.T) {
+	_, err := NewWebhook("http://localhost", WithFormat("xml"))
+	require.Error(t, err)
+
+	_, err = NewWebhook("http://localhost", WithTemplate("{{"))
+	require.Error(t, err)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	w, err := NewWebhook(s.URL)
+	require.NoError(t, err)
+	require.Error(t, w.Notify(context.Background(), Event{Type: EventDown}))
+}
@@ -0,0 +1,247 @@
+package poller
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+const (
+	// DefaultLatencyDrift is the default factor by which the latency of a
+	// canary must exceed the median latency of its baseline to drift.
+	DefaultLatencyDrift = 3
+
+	// DefaultLatencyFloor is the default latency below which a canary is
+	// never considered to have drifted, so noise in small latencies is
+	// ignored.
+	DefaultLatencyFloor = time.Millisecond * 10
+)
+
+// FieldBuild is the normalized field of the build, which falls back to the
+// build reported by protocol.BuildIDer.
+const FieldBuild = "build"
+
+// BuildID returns the normalized build of the response, or the build reported
+// by the response if it has no normalized build.
+func (r Result) BuildID() string {
+	if v := r.Fields[FieldBuild]; v != "" {
+		return v
+	}
+	if b, ok := r.Response.(protocol.BuildIDer); ok {
+		return b.BuildID()
+	}
+	return ""
+}
+
+// Canary is a target whose results are compared to those of its baseline
+// targets after each poll, supporting staged rollouts to a fleet.
+type Canary struct {
+	// Name is the name of the canary target.
+	Name string
+
+	// Baseline are the names of the targets the canary is compared to.
+	Baseline []string
+}
+
+// DriftKind is the kind of difference between a canary and its baseline.
+type DriftKind string
+
+const (
+	// DriftBuild is a canary reporting a different build to the most common
+	// build of its baseline.
+	DriftBuild DriftKind = "build"
+
+	// DriftLatency is a canary whose latency is abnormally high compared to
+	// the median latency of its baseline.
+	DriftLatency DriftKind = "latency"
+
+	// DriftDown is a canary which is down while most of its baseline is up.
+	DriftDown DriftKind = "down"
+)
+
+// Drift is a change in whether a canary differs from its baseline.
+type Drift struct {
+	Kind   DriftKind
+	Canary Target
+	Time   time.Time
+
+	// Value is the value of the canary and Baseline the value of its
+	// baseline which were compared e.g. their builds or latencies.
+	Value    string
+	Baseline string
+
+	// Resolved is true if the canary no longer differs from its baseline.
+	Resolved bool
+}
+
+// DriftHandler is called when a canary starts or stops differing from its
+// baseline.
+type DriftHandler func(d Drift)
+
+// driftKey identifies a kind of drift of a canary.
+type driftKey struct {
+	canary string
+	kind   DriftKind
+}
+
+// WithCanaries adds canaries whose results are compared to their baselines
+// after each poll, calling h when a canary starts or stops differing from its
+// baseline. Targets are matched by name and comparisons skipped if they
+// aren't polled.
+func WithCanaries(h DriftHandler, canaries ...Canary) Option {
+	return func(p *Poller) error {
+		if h == nil {
+			return errors.New("no drift handler")
+		}
+
+		for _, c := range canaries {
+			if c.Name == "" {
+				return errors.New("canary has no name")
+			} else if len(c.Baseline) == 0 {
+				return fmt.Errorf("canary %q has no baseline", c.Name)
+			}
+			for _, b := range c.Baseline {
+				if b == c.Name {
+					return fmt.Errorf("canary %q is in its own baseline", c.Name)
+				}
+			}
+		}
+
+		p.driftHandler = h
+		p.canaries = append(p.canaries, canaries...)
+		return nil
+	}
+}
+
+// WithLatencyDrift sets the factor by which the latency of a canary must
+// exceed the median latency of its baseline, and the floor it must exceed,
+// for its latency to drift. It defaults to DefaultLatencyDrift and
+// DefaultLatencyFloor.
+func WithLatencyDrift(factor float64, floor time.Duration) Option {
+	return func(p *Poller) error {
+		if factor < 1 {
+			return errors.New("latency drift factor must be at least 1")
+		} else if floor < 0 {
+			return errors.New("latency floor must not be negative")
+		}
+		p.latencyDrift = factor
+		p.latencyFloor = floor
+		return nil
+	}
+}
+
+// checkCanaries compares the result of each canary to those of its baseline.
+func (p *Poller) checkCanaries(results []Result) {
+	if len(p.canaries) == 0 {
+		return
+	}
+
+	byName := make(map[string]Result, len(results))
+	for _, r := range results {
+		byName[r.Target.Name] = r
+	}
+
+	for _, c := range p.canaries {
+		cr, ok := byName[c.Name]
+		if !ok {
+			continue
+		}
+
+		var up []Result
+		var polled int
+		for _, name := range c.Baseline {
+			if r, ok := byName[name]; ok {
+				polled++
+				if r.Err == nil {
+					up = append(up, r)
+				}
+			}
+		}
+		if polled == 0 {
+			continue
+		}
+
+		p.drift(cr, DriftDown, cr.Err != nil && len(up)*2 > polled, state(cr), fmt.Sprintf("%d/%d up", len(up), polled))
+		if cr.Err != nil || len(up) == 0 {
+			// Nothing to compare.
+			continue
+		}
+
+		if build, baseline := cr.BuildID(), commonBuild(up); build != "" && baseline != "" {
+			p.drift(cr, DriftBuild, build != baseline, build, baseline)
+		}
+
+		median := medianLatency(up)
+		drifted := cr.Latency > p.latencyFloor && float64(cr.Latency) > float64(median)*p.latencyDrift
+		p.drift(cr, DriftLatency, drifted, cr.Latency.Round(time.Microsecond).String(), median.Round(time.Microsecond).String())
+	}
+}
+
+// drift calls the drift handler if whether the canary of r differs from its
+// baseline by kind has changed.
+func (p *Poller) drift(r Result, kind DriftKind, drifted bool, value, baseline string) {
+	k := driftKey{canary: r.Target.Name, kind: kind}
+	if p.drifting[k] == drifted {
+		return
+	}
+
+	if drifted {
+		p.drifting[k] = true
+	} else {
+		delete(p.drifting, k)
+	}
+
+	p.driftHandler(Drift{
+		Kind:     kind,
+		Canary:   r.Target,
+		Time:     r.Time,
+		Value:    value,
+		Baseline: baseline,
+		Resolved: !drifted,
+	})
+}
+
+// state returns the up or down state of r.
+func state(r Result) string {
+	if r.Err != nil {
+		return "down"
+	}
+	return "up"
+}
+
+// commonBuild returns the most common build of results, ties are broken by
+// the lowest build so the result is deterministic.
+func commonBuild(results []Result) string {
+	counts := make(map[string]int)
+	for _, r := range results {
+		if b := r.BuildID(); b != "" {
+			counts[b]++
+		}
+	}
+
+	var build string
+	for b, n := range counts {
+		if n > counts[build] || (n == counts[build] && b < build) {
+			build = b
+		}
+	}
+	return build
+}
+
+// medianLatency returns the median latency of results.
+func medianLatency(results []Result) time.Duration {
+	latencies := make([]time.Duration, len(results))
+	for i, r := range results {
+		latencies[i] = r.Latency
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	n := len(latencies)
+	if n%2 == 1 {
+		return latencies[n/2]
+	}
+	return (latencies[n/2-1] + latencies[n/2]) / 2
+}
@@ -0,0 +1,160 @@
+package poller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCanaries(t *testing.T) {
+	h := func(Drift) {}
+	target := WithTargets(Target{Name: "a", Address: "127.0.0.1:1"})
+	tests := []struct {
+		name     string
+		canaries []Canary
+		handler  DriftHandler
+		err      bool
+	}{
+		{name: "valid", canaries: []Canary{{Name: "a", Baseline: []string{"b"}}}, handler: h},
+		{name: "no-handler", canaries: []Canary{{Name: "a", Baseline: []string{"b"}}}, err: true},
+		{name: "no-name", canaries: []Canary{{Baseline: []string{"b"}}}, handler: h, err: true},
+		{name: "no-baseline", canaries: []Canary{{Name: "a"}}, handler: h, err: true},
+		{name: "own-baseline", canaries: []Canary{{Name: "a", Baseline: []string{"b", "a"}}}, handler: h, err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(target, WithHandler(func(Result) {}), WithCanaries(tc.handler, tc.canaries...))
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCanaries(t *testing.T) {
+	var drifts []Drift
+	p, err := New(
+		WithTargets(
+			Target{Name: "canary", Address: "192.0.2.1:1"},
+			Target{Name: "b1", Address: "192.0.2.2:1"},
+			Target{Name: "b2", Address: "192.0.2.3:1"},
+			Target{Name: "b3", Address: "192.0.2.4:1"},
+		),
+		WithHandler(func(Result) {}),
+		WithCanaries(func(d Drift) {
+			drifts = append(drifts, d)
+		}, Canary{Name: "canary", Baseline: []string{"b1", "b2", "b3"}}),
+	)
+	require.NoError(t, err)
+
+	build := "1.0"
+	latency := time.Millisecond * 20
+	var canaryErr error
+	p.query = func(t Target) Result {
+		r := Result{Target: t, Time: time.Now(), Latency: time.Millisecond * 20}
+		b := "1.0"
+		if t.Name == "canary" {
+			b = build
+			r.Latency = latency
+			r.Err = canaryErr
+		}
+		if r.Err == nil {
+			r.Response = &sqp.QueryResponse{ServerInfo: &sqp.ServerInfoChunk{BuildID: b}}
+		}
+		return r
+	}
+
+	// No drift.
+	p.Poll()
+	require.Empty(t, drifts)
+
+	// Build drift is emitted once.
+	build = "1.1"
+	p.Poll()
+	p.Poll()
+	require.Len(t, drifts, 1)
+	require.Equal(t, DriftBuild, drifts[0].Kind)
+	require.Equal(t, "canary", drifts[0].Canary.Name)
+	require.Equal(t, "1.1", drifts[0].Value)
+	require.Equal(t, "1.0", drifts[0].Baseline)
+	require.False(t, drifts[0].Resolved)
+
+	// Latency drift.
+	drifts = nil
+	latency = time.Millisecond * 100
+	p.Poll()
+	require.Len(t, drifts, 1)
+	require.Equal(t, DriftLatency, drifts[0].Kind)
+	require.Equal(t, "100ms", drifts[0].Value)
+	require.Equal(t, "20ms", drifts[0].Baseline)
+
+	// Both resolve.
+	drifts = nil
+	build = "1.0"
+	latency = time.Millisecond * 20
+	p.Poll()
+	require.Len(t, drifts, 2)
+	require.Equal(t, DriftBuild, drifts[0].Kind)
+	require.True(t, drifts[0].Resolved)
+	require.Equal(t, DriftLatency, drifts[1].Kind)
+	require.True(t, drifts[1].Resolved)
+
+	// Down while the baseline is up.
+	drifts = nil
+	canaryErr = errors.New("timeout")
+	p.Poll()
+	require.Len(t, drifts, 1)
+	require.Equal(t, DriftDown, drifts[0].Kind)
+	require.Equal(t, "down", drifts[0].Value)
+	require.Equal(t, "3/3 up", drifts[0].Baseline)
+
+	drifts = nil
+	canaryErr = nil
+	p.Poll()
+	require.Len(t, drifts, 1)
+	require.Equal(t, DriftDown, drifts[0].Kind)
+	require.True(t, drifts[0].Resolved)
+}
+
+func TestCanariesLatencyFloor(t *testing.T) {
+	var drifts []Drift
+	p, err := New(
+		WithTargets(
+			Target{Name: "canary", Address: "192.0.2.1:1"},
+			Target{Name: "b1", Address: "192.0.2.2:1"},
+		),
+		WithHandler(func(Result) {}),
+		WithCanaries(func(d Drift) {
+			drifts = append(drifts, d)
+		}, Canary{Name: "canary", Baseline: []string{"b1"}}),
+		WithLatencyDrift(2, time.Millisecond*5),
+	)
+	require.NoError(t, err)
+
+	latency := time.Millisecond * 4
+	p.query = func(t Target) Result {
+		r := Result{Target: t, Time: time.Now(), Latency: time.Millisecond, Response: &sqp.QueryResponse{}}
+		if t.Name == "canary" {
+			r.Latency = latency
+		}
+		return r
+	}
+
+	// Below the floor.
+	p.Poll()
+	require.Empty(t, drifts)
+
+	latency = time.Millisecond * 6
+	p.Poll()
+	require.Len(t, drifts, 1)
+	require.Equal(t, DriftLatency, drifts[0].Kind)
+
+	_, err = New(WithTargets(Target{Address: "127.0.0.1:1"}), WithHandler(func(Result) {}), WithLatencyDrift(0.5, 0))
+	require.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package poller
+
+import "time"
+
+// Clock is the source of time used by a Poller to schedule polls and time
+// results. It allows scheduling to be simulated in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTicker returns a Ticker which ticks every d.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks at intervals, dropping ticks for slow receivers, as
+// time.Ticker does.
+type Ticker interface {
+	// C returns the channel on which ticks are delivered.
+	C() <-chan time.Time
+
+	// Stop stops the ticker.
+	Stop()
+}
+
+// realClock is a Clock using the time package.
+type realClock struct{}
+
+// Now implements Clock.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTicker implements Clock.
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTicker is a Ticker using a time.Ticker.
+type realTicker struct {
+	*time.Ticker
+}
+
+// C implements Ticker.
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
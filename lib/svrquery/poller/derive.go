@@ -0,0 +1,89 @@
+package poller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+// DeriveData is the data derived field templates are executed with.
+type DeriveData struct {
+	Name       string
+	Protocol   string
+	Address    string
+	NumClients int64
+	MaxClients int64
+	Map        string
+
+	// Response is the protocol specific response, allowing any of its fields
+	// to be used e.g. {{(index .Response.ServerRules.Rules "mode").Value}}.
+	Response protocol.Responser
+}
+
+// deriveFuncs are the functions available to derived field templates.
+var deriveFuncs = template.FuncMap{
+	// percent returns n as a percentage of max, or 0 if max is 0.
+	"percent": func(n, max int64) float64 {
+		if max == 0 {
+			return 0
+		}
+		return float64(n) * 100 / float64(max)
+	},
+}
+
+// Deriver derives fields from successful results using text/template, so
+// simple customisations such as computing occupancy or renaming rules don't
+// require code changes.
+type Deriver struct {
+	names []string
+	tmpls map[string]*template.Template
+}
+
+// NewDeriver returns a Deriver which derives each field of fields by
+// executing its template with the DeriveData of a result.
+func NewDeriver(fields map[string]string) (*Deriver, error) {
+	d := &Deriver{tmpls: make(map[string]*template.Template, len(fields))}
+	for name, text := range fields {
+		tmpl, err := template.New(name).Funcs(deriveFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("derived field %q: %w", name, err)
+		}
+		d.names = append(d.names, name)
+		d.tmpls[name] = tmpl
+	}
+	sort.Strings(d.names)
+
+	return d, nil
+}
+
+// Derive returns the derived fields of r, which is nil if r failed.
+func (d *Deriver) Derive(r Result) (map[string]string, error) {
+	if r.Err != nil || r.Response == nil {
+		return nil, nil
+	}
+
+	data := DeriveData{
+		Name:       r.Target.Name,
+		Protocol:   r.Target.Protocol,
+		Address:    r.Target.Address,
+		NumClients: r.Response.NumClients(),
+		MaxClients: r.Response.MaxClients(),
+		Map:        r.MapName(),
+		Response:   r.Response,
+	}
+
+	fields := make(map[string]string, len(d.names))
+	var sb strings.Builder
+	for _, name := range d.names {
+		sb.Reset()
+		if err := d.tmpls[name].Execute(&sb, data); err != nil {
+			return nil, fmt.Errorf("derived field %q: %w", name, err)
+		}
+		fields[name] = sb.String()
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,46 @@
+package poller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeriver(t *testing.T) {
+	d, err := NewDeriver(map[string]string{
+		"occupancy": `{{printf "%.0f" (percent .NumClients .MaxClients)}}%`,
+		"mode":      `{{(index .Response.ServerRules.Rules "mode").Value}}`,
+		"where":     `{{.Name}} on {{.Map}}`,
+	})
+	require.NoError(t, err)
+
+	r := Result{
+		Target: Target{Name: "eu-1", Protocol: "sqp", Address: "10.0.0.1:12121"},
+		Response: &sqp.QueryResponse{
+			ServerInfo:  &sqp.ServerInfoChunk{CurrentPlayers: 3, MaxPlayers: 4, Map: "harbour"},
+			ServerRules: &sqp.ServerRulesChunk{Rules: map[string]*sqp.DynamicValue{"mode": {Type: sqp.String, Value: "ctf"}}},
+		},
+	}
+	fields, err := d.Derive(r)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"occupancy": "75%",
+		"mode":      "ctf",
+		"where":     "eu-1 on harbour",
+	}, fields)
+
+	// Failed results have no derived fields.
+	fields, err = d.Derive(Result{Err: errors.New("timeout")})
+	require.NoError(t, err)
+	require.Nil(t, fields)
+
+	// Missing rules chunk.
+	r.Response.(*sqp.QueryResponse).ServerRules = nil
+	_, err = d.Derive(r)
+	require.Error(t, err)
+
+	_, err = NewDeriver(map[string]string{"bad": "{{.Name"})
+	require.Error(t, err)
+}
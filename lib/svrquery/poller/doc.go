@@ -0,0 +1,2 @@
+// Package poller provides periodic querying of a set of servers.
+package poller
@@ -0,0 +1,52 @@
+package poller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/leaktest"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollerLeaks(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	s, err := server.New(
+		server.WithAddress("127.0.0.1:0"),
+		server.WithProtocol("sqp"),
+		server.WithState(common.QueryState{CurrentPlayers: 1, MaxPlayers: 2}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	var polls int
+	p, err := New(
+		WithInterval(time.Millisecond),
+		WithTargets(
+			Target{Name: "up", Protocol: "sqp", Address: s.Addr().String()},
+			Target{Name: "down", Protocol: "sqp", Address: "127.0.0.1:1"},
+		),
+		WithHandler(func(r Result) {
+			polls++
+		}),
+		WithKeepLastGood(time.Minute),
+	)
+	require.NoError(t, err)
+
+	// Warm up so one-off allocations aren't counted.
+	p.Poll()
+	before := leaktest.HeapAlloc()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, p.Run(ctx))
+	require.True(t, polls > 20)
+
+	// Polling retains no more than the last good result of each target.
+	after := leaktest.HeapAlloc()
+	require.True(t, after < before+256<<10, "heap grew from %d to %d bytes", before, after)
+}
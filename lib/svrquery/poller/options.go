@@ -0,0 +1,90 @@
+package poller
+
+import (
+	"errors"
+	"time"
+)
+
+// Option represents a Poller option.
+type Option func(*Poller) error
+
+// WithInterval sets the interval between polls, defaulting to DefaultInterval.
+func WithInterval(interval time.Duration) Option {
+	return func(p *Poller) error {
+		if interval <= 0 {
+			return errors.New("interval must be positive")
+		}
+		p.interval = interval
+		return nil
+	}
+}
+
+// WithConcurrency sets the max number of targets queried at once, defaulting
+// to DefaultConcurrency. Polls of more targets take longer, as the rest wait
+// for earlier queries to complete.
+func WithConcurrency(n int) Option {
+	return func(p *Poller) error {
+		if n < 1 {
+			return errors.New("concurrency must be at least 1")
+		}
+		p.concurrency = n
+		return nil
+	}
+}
+
+// WithTargets adds targets to be polled.
+func WithTargets(targets ...Target) Option {
+	return func(p *Poller) error {
+		p.targets = append(p.targets, targets...)
+		return nil
+	}
+}
+
+// WithSource sets a source of targets which are polled in addition to those
+// added by WithTargets. Targets are identified across polls by their name,
+// protocol and address.
+func WithSource(s Source) Option {
+	return func(p *Poller) error {
+		p.source = s
+		return nil
+	}
+}
+
+// WithHandler sets the handler which is called with the result of each query.
+func WithHandler(h Handler) Option {
+	return func(p *Poller) error {
+		p.handler = h
+		return nil
+	}
+}
+
+// WithPollHandler sets a handler which is called with the results of each
+// poll, such as to release the state of targets which are no longer polled.
+func WithPollHandler(h PollHandler) Option {
+	return func(p *Poller) error {
+		p.pollHandler = h
+		return nil
+	}
+}
+
+// WithKeepLastGood enables surfacing the last good response of a target when
+// a query fails, as long as it's no older than maxAge. Such results have Stale
+// set and Err reporting the failure.
+func WithKeepLastGood(maxAge time.Duration) Option {
+	return func(p *Poller) error {
+		if maxAge <= 0 {
+			return errors.New("max age must be positive")
+		}
+		p.maxStaleAge = maxAge
+		return nil
+	}
+}
+
+// WithClock sets the clock used to schedule polls and time results, which
+// allows scheduling to be simulated in tests. It defaults to the system clock.
+func WithClock(c Clock) Option {
+	return func(p *Poller) error {
+		p.clock = c
+		return nil
+	}
+}
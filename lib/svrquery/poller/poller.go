@@ -0,0 +1,265 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+const (
+	// DefaultInterval is the default interval between polls.
+	DefaultInterval = time.Second * 30
+
+	// DefaultConcurrency is the default max number of concurrent queries.
+	DefaultConcurrency = 64
+)
+
+var (
+	// ErrNoTargets is returned by New if neither targets nor a source are
+	// configured.
+	ErrNoTargets = errors.New("no targets")
+
+	// ErrNoHandler is returned by New if no handler is configured.
+	ErrNoHandler = errors.New("no handler")
+)
+
+// Target is a server to poll.
+type Target struct {
+	// Name identifies the target, defaults to Address.
+	Name     string
+	Protocol string
+	Address  string
+	Options  []svrquery.Option
+
+	// Schema, if set, is used to set the normalized Fields of results.
+	Schema Schema
+}
+
+// Result is the result of querying a Target.
+type Result struct {
+	Target   Target
+	Time     time.Time
+	Latency  time.Duration
+	Response protocol.Responser
+	Err      error
+
+	// Stats are the network statistics of the query.
+	Stats svrquery.QueryStats
+
+	// Stale is true if the query failed and Response is the last good
+	// response, see WithKeepLastGood.
+	Stale bool
+
+	// Age is the time since Response was received.
+	Age time.Duration
+
+	// Fields are the normalized fields of Response, see Target.Schema.
+	Fields map[string]string
+}
+
+// MapName returns the normalized map of the response, or the map reported by
+// the response if it has no normalized fields.
+func (r Result) MapName() string {
+	if r.Fields != nil {
+		return r.Fields[FieldMap]
+	}
+	if m, ok := r.Response.(protocol.Mapper); ok {
+		return m.MapName()
+	}
+	return ""
+}
+
+// Handler is called with each Result.
+type Handler func(r Result)
+
+// PollHandler is called with all the results of a poll, once the Handler has
+// been called with each of them.
+type PollHandler func(results []Result)
+
+// Source is a dynamic source of targets, such as servers which register
+// themselves, which are polled in addition to the configured targets.
+type Source interface {
+	// Targets returns the current targets, it's called before each poll.
+	Targets() []Target
+}
+
+// targetKey identifies a target across polls.
+type targetKey struct {
+	name     string
+	protocol string
+	address  string
+}
+
+// Poller periodically queries a set of targets.
+type Poller struct {
+	targets     []Target
+	source      Source
+	interval    time.Duration
+	concurrency int
+	handler     Handler
+	pollHandler PollHandler
+	maxStaleAge time.Duration
+	lastGood    map[targetKey]Result
+	clock       Clock
+
+	canaries     []Canary
+	driftHandler DriftHandler
+	drifting     map[driftKey]bool
+	latencyDrift float64
+	latencyFloor time.Duration
+
+	// query queries a target, it's replaced in tests to simulate targets.
+	query func(t Target) Result
+}
+
+// New returns a new Poller configured with options.
+func New(options ...Option) (*Poller, error) {
+	p := &Poller{
+		interval:     DefaultInterval,
+		concurrency:  DefaultConcurrency,
+		clock:        realClock{},
+		drifting:     make(map[driftKey]bool),
+		latencyDrift: DefaultLatencyDrift,
+		latencyFloor: DefaultLatencyFloor,
+	}
+	p.query = p.queryTarget
+	for _, o := range options {
+		if err := o(p); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case len(p.targets) == 0 && p.source == nil:
+		return nil, ErrNoTargets
+	case p.handler == nil:
+		return nil, ErrNoHandler
+	}
+
+	setNames(p.targets)
+	p.lastGood = make(map[targetKey]Result)
+
+	return p, nil
+}
+
+// Run polls all targets immediately and then at every interval until ctx is
+// done. The handler is called serially in target order once all targets of a
+// poll have been queried.
+func (p *Poller) Run(ctx context.Context) error {
+	t := p.clock.NewTicker(p.interval)
+	defer t.Stop()
+
+	for {
+		p.Poll()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C():
+		}
+	}
+}
+
+// setNames defaults the name of each of targets to its address.
+func setNames(targets []Target) {
+	for i, t := range targets {
+		if t.Name == "" {
+			targets[i].Name = t.Address
+		}
+	}
+}
+
+// Poll queries all targets concurrently once, at most the configured
+// concurrency at a time, and calls the handler with the results.
+func (p *Poller) Poll() {
+	targets := p.targets
+	if p.source != nil {
+		dynamic := p.source.Targets()
+		setNames(dynamic)
+		targets = append(targets[:len(targets):len(targets)], dynamic...)
+	}
+
+	results := make([]Result, len(targets))
+	sem := make(chan struct{}, p.concurrency)
+	var wg sync.WaitGroup
+	wg.Add(len(targets))
+	for i, t := range targets {
+		sem <- struct{}{}
+		go func(i int, t Target) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = p.query(t)
+		}(i, t)
+	}
+	wg.Wait()
+
+	// Only the last good results of current targets are kept, so those of
+	// targets removed by the source are released.
+	lastGood := make(map[targetKey]Result, len(targets))
+	for i, r := range results {
+		k := targetKey{name: r.Target.Name, protocol: r.Target.Protocol, address: r.Target.Address}
+		results[i] = p.keepLastGood(k, r)
+		if last, ok := p.lastGood[k]; ok {
+			lastGood[k] = last
+		}
+		p.handler(results[i])
+	}
+	p.lastGood = lastGood
+
+	if p.pollHandler != nil {
+		p.pollHandler(results)
+	}
+
+	p.checkCanaries(results)
+}
+
+// keepLastGood records r as the last good result of the target k if it
+// succeeded, otherwise if enabled it returns r with the last good response
+// if any.
+func (p *Poller) keepLastGood(k targetKey, r Result) Result {
+	if r.Err == nil {
+		p.lastGood[k] = r
+		return r
+	}
+
+	last := p.lastGood[k]
+	if p.maxStaleAge == 0 || last.Response == nil {
+		return r
+	}
+
+	age := r.Time.Sub(last.Time)
+	if age > p.maxStaleAge {
+		return r
+	}
+
+	r.Response = last.Response
+	r.Fields = last.Fields
+	r.Latency = last.Latency
+	r.Stale = true
+	r.Age = age
+	return r
+}
+
+// queryTarget queries t once.
+func (p *Poller) queryTarget(t Target) Result {
+	r := Result{Target: t, Time: p.clock.Now()}
+	c, err := svrquery.NewClient(t.Protocol, t.Address, t.Options...)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer c.Close()
+
+	r.Response, r.Err = c.Query()
+	r.Latency = p.clock.Now().Sub(r.Time)
+	r.Stats = c.QueryStats()
+	if r.Err == nil && t.Schema != nil {
+		r.Fields = t.Schema.Fields(r.Response)
+	}
+	return r
+}
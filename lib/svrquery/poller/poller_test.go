@@ -0,0 +1,217 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	h := func(Result) {}
+	_, err := New(WithHandler(h))
+	require.Equal(t, ErrNoTargets, err)
+
+	_, err = New(WithTargets(Target{Address: "127.0.0.1:1"}))
+	require.Equal(t, ErrNoHandler, err)
+
+	// A source alone is enough.
+	_, err = New(WithSource(&targetsSource{}), WithHandler(h))
+	require.NoError(t, err)
+
+	_, err = New(WithTargets(Target{Address: "127.0.0.1:1"}), WithHandler(h), WithInterval(0))
+	require.Error(t, err)
+
+	_, err = New(WithTargets(Target{Address: "127.0.0.1:1"}), WithHandler(h), WithConcurrency(0))
+	require.Error(t, err)
+
+	p, err := New(WithTargets(Target{Address: "127.0.0.1:1"}), WithHandler(h))
+	require.NoError(t, err)
+	require.Equal(t, DefaultInterval, p.interval)
+	require.Equal(t, DefaultConcurrency, p.concurrency)
+	require.Equal(t, "127.0.0.1:1", p.targets[0].Name)
+}
+
+func TestPoller(t *testing.T) {
+	s, err := server.New(
+		server.WithAddress("127.0.0.1:0"),
+		server.WithProtocol("sqp"),
+		server.WithState(common.QueryState{CurrentPlayers: 1, MaxPlayers: 2}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	var results []Result
+	p, err := New(
+		WithInterval(time.Millisecond*10),
+		WithTargets(
+			Target{Name: "up", Protocol: "sqp", Address: s.Addr().String()},
+			Target{Name: "bad", Protocol: "unknown", Address: s.Addr().String()},
+			Target{Name: "invalid-args", Protocol: "sqp", Address: s.Addr().String(), Options: []svrquery.Option{
+				svrquery.WithArg("chunks", "invalid"),
+			}},
+		),
+		WithHandler(func(r Result) {
+			results = append(results, r)
+		}),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*25)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, p.Run(ctx))
+
+	require.True(t, len(results) >= 3)
+	require.Equal(t, 0, len(results)%3)
+	for i := 0; i < len(results); i += 3 {
+		require.Equal(t, "up", results[i].Target.Name)
+		require.NoError(t, results[i].Err)
+		require.Equal(t, int64(1), results[i].Response.NumClients())
+		require.True(t, results[i].Latency > 0)
+		require.Equal(t, 2, results[i].Stats.PacketsSent)
+		require.Equal(t, 2, results[i].Stats.PacketsReceived)
+		require.True(t, results[i].Stats.BytesReceived > results[i].Stats.BytesSent)
+
+		require.Equal(t, "bad", results[i+1].Target.Name)
+		require.Error(t, results[i+1].Err)
+
+		require.Equal(t, "invalid-args", results[i+2].Target.Name)
+		require.Error(t, results[i+2].Err)
+	}
+}
+
+func TestKeepLastGood(t *testing.T) {
+	p, err := New(
+		WithTargets(Target{Name: "test", Protocol: "sqp", Address: "127.0.0.1:1"}),
+		WithHandler(func(r Result) {}),
+		WithKeepLastGood(time.Second*30),
+	)
+	require.NoError(t, err)
+
+	k := targetKey{name: "test", protocol: "sqp", address: "127.0.0.1:1"}
+	now := time.Now()
+	good := Result{Time: now, Latency: time.Millisecond, Response: &sqp.QueryResponse{}}
+	failed := func(age time.Duration) Result {
+		return Result{Time: now.Add(age), Err: errors.New("i/o timeout")}
+	}
+
+	// No last good response.
+	r := p.keepLastGood(k, failed(0))
+	require.False(t, r.Stale)
+	require.Nil(t, r.Response)
+
+	require.False(t, p.keepLastGood(k, good).Stale)
+
+	r = p.keepLastGood(k, failed(time.Second*10))
+	require.True(t, r.Stale)
+	require.Error(t, r.Err)
+	require.Equal(t, good.Response, r.Response)
+	require.Equal(t, time.Second*10, r.Age)
+	require.Equal(t, time.Millisecond, r.Latency)
+
+	// Too old.
+	r = p.keepLastGood(k, failed(time.Second*31))
+	require.False(t, r.Stale)
+	require.Nil(t, r.Response)
+
+	_, err = New(WithKeepLastGood(0))
+	require.Error(t, err)
+}
+
+// targetsSource is a Source of the targets it points to.
+type targetsSource []Target
+
+// Targets implements Source.
+func (s *targetsSource) Targets() []Target {
+	return append([]Target(nil), (*s)...)
+}
+
+func TestPollerSource(t *testing.T) {
+	var src targetsSource
+	var results, polled []Result
+	p, err := New(
+		WithTargets(Target{Name: "static", Protocol: "sqp", Address: "192.0.2.1:12121"}),
+		WithSource(&src),
+		WithHandler(func(r Result) {
+			results = append(results, r)
+		}),
+		WithPollHandler(func(r []Result) {
+			polled = r
+		}),
+		WithKeepLastGood(time.Minute),
+	)
+	require.NoError(t, err)
+	p.query = func(t Target) Result {
+		return Result{Target: t, Time: time.Now(), Response: &sqp.QueryResponse{}}
+	}
+
+	p.Poll()
+	require.Len(t, results, 1)
+
+	src = targetsSource{{Protocol: "sqp", Address: "192.0.2.2:12121"}}
+	results = nil
+	p.Poll()
+	require.Len(t, results, 2)
+	require.Equal(t, "static", results[0].Target.Name)
+	require.Equal(t, "192.0.2.2:12121", results[1].Target.Name)
+	require.Equal(t, results, polled)
+	require.Len(t, p.lastGood, 2)
+
+	// The last good results of removed targets are released.
+	src = nil
+	results = nil
+	p.Poll()
+	require.Len(t, results, 1)
+	require.Len(t, p.lastGood, 1)
+}
+
+func TestPollConcurrency(t *testing.T) {
+	src := make(targetsSource, 20)
+	for i := range src {
+		src[i] = Target{Protocol: "sqp", Address: fmt.Sprintf("192.0.2.%d:12121", i+1)}
+	}
+
+	var results []Result
+	p, err := New(
+		WithSource(&src),
+		WithHandler(func(r Result) {
+			results = append(results, r)
+		}),
+		WithConcurrency(3),
+	)
+	require.NoError(t, err)
+
+	var mtx sync.Mutex
+	var active, peak int
+	p.query = func(t Target) Result {
+		mtx.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mtx.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mtx.Lock()
+		active--
+		mtx.Unlock()
+		return Result{Target: t, Time: time.Now(), Response: &sqp.QueryResponse{}}
+	}
+
+	p.Poll()
+	require.Len(t, results, len(src))
+	require.Equal(t, 3, peak)
+	for i, r := range results {
+		require.Equal(t, src[i].Address, r.Target.Address)
+	}
+}
@@ -0,0 +1,41 @@
+package poller
+
+import (
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+// FieldMap is the normalized field of the map, which falls back to the map
+// reported by protocol.Mapper.
+const FieldMap = "map"
+
+// Schema normalizes responses across titles by mapping each normalized field
+// to the protocol specific rule keys it's read from, in order of preference
+// e.g. {"map": ["mapname", "level"], "mode": ["gametype"]}.
+type Schema map[string][]string
+
+// Fields returns the normalized fields of resp. Fields without a value are
+// omitted.
+func (s Schema) Fields(resp protocol.Responser) map[string]string {
+	var rules map[string]string
+	if r, ok := resp.(protocol.Ruler); ok {
+		rules = r.Rules()
+	}
+
+	fields := make(map[string]string, len(s))
+	for field, keys := range s {
+		for _, k := range keys {
+			if v := rules[k]; v != "" {
+				fields[field] = v
+				break
+			}
+		}
+	}
+
+	if _, ok := fields[FieldMap]; !ok {
+		if m, ok := resp.(protocol.Mapper); ok && m.MapName() != "" {
+			fields[FieldMap] = m.MapName()
+		}
+	}
+
+	return fields
+}
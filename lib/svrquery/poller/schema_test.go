@@ -0,0 +1,37 @@
+package poller
+
+import (
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/titanfall"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	s := Schema{
+		"map":  {"level"},
+		"mode": {"gametype", "playlist"},
+	}
+
+	qr := &sqp.QueryResponse{
+		ServerInfo: &sqp.ServerInfoChunk{Map: "Map"},
+		ServerRules: &sqp.ServerRulesChunk{Rules: map[string]*sqp.DynamicValue{
+			"level":    {Type: sqp.String, Value: "harbour"},
+			"gametype": {Type: sqp.String, Value: "ctf"},
+		}},
+	}
+	require.Equal(t, map[string]string{"map": "harbour", "mode": "ctf"}, s.Fields(qr))
+
+	// Falls back to the map reported by the response.
+	qr.ServerRules = nil
+	require.Equal(t, map[string]string{"map": "Map"}, s.Fields(qr))
+
+	info := &titanfall.Info{BasicInfo: titanfall.BasicInfo{Map: "mp_glitch", PlaylistName: "attrition"}}
+	require.Equal(t, map[string]string{"map": "mp_glitch", "mode": "attrition"}, s.Fields(info))
+
+	r := Result{Response: qr, Fields: map[string]string{"map": "harbour"}}
+	require.Equal(t, "harbour", r.MapName())
+	r.Fields = nil
+	require.Equal(t, "Map", r.MapName())
+}
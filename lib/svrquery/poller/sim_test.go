@@ -0,0 +1,313 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/stretchr/testify/require"
+)
+
+// simClock is a simulated Clock whose time only changes when advanced.
+type simClock struct {
+	mtx     sync.Mutex
+	now     time.Time
+	tickers []*simTicker
+}
+
+// simTicker is a Ticker of a simClock.
+type simTicker struct {
+	clock   *simClock
+	c       chan time.Time
+	d       time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func newSimClock() *simClock {
+	return &simClock{now: time.Date(2021, 5, 4, 0, 0, 0, 0, time.UTC)}
+}
+
+// Now implements Clock.
+func (c *simClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.now
+}
+
+// NewTicker implements Clock.
+func (c *simClock) NewTicker(d time.Duration) Ticker {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	t := &simTicker{clock: c, c: make(chan time.Time, 1), d: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance advances the clock by d, delivering the ticks which are due in
+// order, and returns the number of ticks delivered. As with time.Ticker ticks
+// are dropped if the previous tick hasn't been received.
+func (c *simClock) Advance(d time.Duration) int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	end := c.now.Add(d)
+	var n int
+	for {
+		found, delivered := c.tick(end)
+		if !found {
+			break
+		}
+		if delivered {
+			n++
+		}
+	}
+	c.now = end
+
+	return n
+}
+
+// AdvanceUntil advances the clock to the next tick, delivering it, or to end
+// if it's earlier. It returns true if a tick was delivered.
+func (c *simClock) AdvanceUntil(end time.Time) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for {
+		found, delivered := c.tick(end)
+		if !found {
+			break
+		}
+		if delivered {
+			return true
+		}
+	}
+	c.now = end
+
+	return false
+}
+
+// tick advances the clock to the next tick due no later than end, and
+// delivers it unless the previous tick hasn't been received. It returns
+// whether there was such a tick and whether it was delivered, a dropped tick
+// is still consumed so the clock never goes backwards.
+func (c *simClock) tick(end time.Time) (found, delivered bool) {
+	var next *simTicker
+	for _, t := range c.tickers {
+		if !t.stopped && !t.next.After(end) && (next == nil || t.next.Before(next.next)) {
+			next = t
+		}
+	}
+	if next == nil {
+		return false, false
+	}
+
+	c.now = next.next
+	next.next = next.next.Add(next.d)
+	select {
+	case next.c <- c.now:
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// C implements Ticker.
+func (t *simTicker) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop implements Ticker.
+func (t *simTicker) Stop() {
+	t.clock.mtx.Lock()
+	defer t.clock.mtx.Unlock()
+
+	t.stopped = true
+}
+
+// simulation runs a Poller of a single target using a simulated clock. The
+// poller runs in lockstep with the test, each poll blocks until its result is
+// received from results.
+type simulation struct {
+	clock   *simClock
+	results chan Result
+	cancel  context.CancelFunc
+	done    chan error
+}
+
+// simulate starts a simulation of a poller polling every interval whose
+// target responds using query.
+func simulate(t *testing.T, interval time.Duration, query func(c *simClock, t Target) Result, options ...Option) *simulation {
+	t.Helper()
+
+	s := &simulation{clock: newSimClock(), results: make(chan Result), done: make(chan error, 1)}
+	options = append([]Option{
+		WithInterval(interval),
+		WithTargets(Target{Name: "sim", Protocol: "sqp", Address: "192.0.2.1:12121"}),
+		WithHandler(func(r Result) { s.results <- r }),
+		WithClock(s.clock),
+	}, options...)
+	p, err := New(options...)
+	require.NoError(t, err)
+	p.query = func(t Target) Result { return query(s.clock, t) }
+
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	go func() { s.done <- p.Run(ctx) }()
+
+	return s
+}
+
+// advance advances the clock by d, in steps of at most step, returning the
+// results of the polls which were triggered. The clock is stopped at each
+// tick until its poll completes.
+func (s *simulation) advance(d, step time.Duration) []Result {
+	var results []Result
+	for end := s.clock.Now().Add(d); s.clock.Now().Before(end); {
+		stepEnd := s.clock.Now().Add(step)
+		if stepEnd.After(end) {
+			stepEnd = end
+		}
+		for s.clock.AdvanceUntil(stepEnd) {
+			results = append(results, <-s.results)
+		}
+	}
+	return results
+}
+
+// stop stops the poller.
+func (s *simulation) stop(t *testing.T) {
+	s.cancel()
+	// A tick may be pending, unblock the poll it triggers.
+	s.clock.Advance(time.Hour)
+	for {
+		select {
+		case <-s.results:
+		case err := <-s.done:
+			require.Equal(t, context.Canceled, err)
+			return
+		}
+	}
+}
+
+// upQuery returns a successful result for t at the current time.
+func upQuery(c *simClock, t Target) Result {
+	return Result{Target: t, Time: c.Now(), Response: &sqp.QueryResponse{}}
+}
+
+func TestSimulatedInterval(t *testing.T) {
+	interval := time.Second * 30
+	s := simulate(t, interval, upQuery)
+	defer s.stop(t)
+
+	// The first poll is immediate.
+	first := <-s.results
+	require.Equal(t, newSimClock().now, first.Time)
+
+	// Advance in steps which don't divide the interval, over two days.
+	days := time.Hour * 48
+	results := s.advance(days, time.Second*7)
+	require.Len(t, results, int(days/interval))
+
+	prev := first.Time
+	for _, r := range results {
+		require.Equal(t, interval, r.Time.Sub(prev), "poll at %v", r.Time)
+		prev = r.Time
+	}
+}
+
+func TestSimulatedSlowTarget(t *testing.T) {
+	// A target which takes one and a half intervals to respond.
+	interval := time.Second * 10
+	latency := interval * 3 / 2
+	s := simulate(t, interval, func(c *simClock, t Target) Result {
+		start := c.Now()
+		c.Advance(latency)
+		return Result{Target: t, Time: start, Latency: latency, Response: &sqp.QueryResponse{}}
+	})
+	defer s.stop(t)
+
+	// Polls never overlap or burst to catch up, they start as soon as the
+	// previous poll completes.
+	prev := <-s.results
+	for i := 0; i < 1000; i++ {
+		r := <-s.results
+		require.Equal(t, latency, r.Time.Sub(prev.Time), "poll %d", i)
+		prev = r
+	}
+}
+
+func TestSimClockDroppedTicks(t *testing.T) {
+	c := newSimClock()
+	start := c.Now()
+	ticker := c.NewTicker(time.Second * 10)
+
+	// A slow handler doesn't receive the first tick until the clock has
+	// passed the next two, which are dropped.
+	require.True(t, c.AdvanceUntil(start.Add(time.Second*35)))
+	require.Equal(t, start.Add(time.Second*10), c.Now())
+	require.False(t, c.AdvanceUntil(start.Add(time.Second*35)))
+	require.Equal(t, start.Add(time.Second*35), c.Now())
+	require.Equal(t, start.Add(time.Second*10), <-ticker.C())
+
+	prev := c.Now()
+	for c.AdvanceUntil(start.Add(time.Second * 70)) {
+		require.False(t, c.Now().Before(prev), "time went back from %v to %v", prev, c.Now())
+		prev = c.Now()
+		<-ticker.C()
+	}
+	require.Equal(t, start.Add(time.Second*70), prev)
+
+	// Advance drops all but the first tick.
+	require.Equal(t, 1, c.Advance(time.Minute))
+	require.Equal(t, start.Add(time.Second*130), c.Now())
+	require.Equal(t, start.Add(time.Second*80), <-ticker.C())
+	require.True(t, c.AdvanceUntil(start.Add(time.Hour)))
+	require.Equal(t, start.Add(time.Second*140), c.Now())
+}
+
+func TestSimulatedKeepLastGood(t *testing.T) {
+	// The target is down for the first two hours of the second and third days.
+	interval := time.Minute
+	maxAge := time.Minute * 30
+	s := simulate(t, interval, func(c *simClock, t Target) Result {
+		now := c.Now()
+		if now.Hour() < 2 && (now.Day() == 5 || now.Day() == 6) {
+			return Result{Target: t, Time: now, Err: errors.New("i/o timeout")}
+		}
+		return upQuery(c, t)
+	}, WithKeepLastGood(maxAge))
+	defer s.stop(t)
+
+	<-s.results
+	results := s.advance(time.Hour*72, time.Second*13)
+	require.Len(t, results, 72*60)
+
+	var lastGood time.Time
+	var stale, down int
+	for _, r := range results {
+		switch {
+		case r.Err == nil:
+			require.False(t, r.Stale)
+			lastGood = r.Time
+		case r.Time.Sub(lastGood) <= maxAge:
+			require.True(t, r.Stale, "result at %v", r.Time)
+			require.Equal(t, r.Time.Sub(lastGood), r.Age)
+			require.NotNil(t, r.Response)
+			stale++
+		default:
+			require.False(t, r.Stale, "result at %v", r.Time)
+			require.Nil(t, r.Response)
+			down++
+		}
+	}
+
+	// Each of the two outages is stale for max age then down.
+	require.Equal(t, 2*30, stale)
+	require.Equal(t, 2*(120-30), down)
+}
@@ -0,0 +1,44 @@
+package svrquery
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// RedactedName is the name which RedactNames replaces player names with.
+const RedactedName = "redacted"
+
+// NameFilter returns the replacement for a player name, it's used to hash or
+// redact player names before results leave the process.
+type NameFilter func(name string) string
+
+// RedactNames returns a NameFilter which replaces all player names with
+// RedactedName.
+func RedactNames() NameFilter {
+	return func(name string) string {
+		return RedactedName
+	}
+}
+
+// HashNames returns a NameFilter which replaces player names with the first
+// 16 hex characters of their HMAC-SHA256 keyed with key. The same player can
+// be followed across queries without revealing their name, and the key
+// prevents names being recovered by hashing a list of known names.
+func HashNames(key []byte) NameFilter {
+	return func(name string) string {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(name))
+		return hex.EncodeToString(h.Sum(nil))[:16]
+	}
+}
+
+// WithPlayerNameFilter sets the filter applied to player names of responses
+// which implement protocol.PlayerNamer. Player counts, scores and other
+// fields are unaffected.
+func WithPlayerNameFilter(f NameFilter) Option {
+	return func(c *Client) error {
+		c.names = f
+		return nil
+	}
+}
@@ -0,0 +1,80 @@
+package svrquery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashNames(t *testing.T) {
+	f := HashNames([]byte("key"))
+	require.Len(t, f("alice"), 16)
+	require.Equal(t, f("alice"), f("alice"))
+	require.NotEqual(t, f("alice"), f("bob"))
+	require.NotEqual(t, f("alice"), HashNames([]byte("other"))("alice"))
+}
+
+func TestWithPlayerNameFilter(t *testing.T) {
+	s, err := server.New(
+		server.WithAddress("127.0.0.1:0"),
+		server.WithProtocol("sqp"),
+		server.WithState(common.QueryState{
+			CurrentPlayers: 2,
+			Players: []common.Player{
+				{Name: "alice", Score: 10},
+				{Name: "bob", Score: 20},
+			},
+		}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	cases := []struct {
+		name   string
+		filter NameFilter
+		expect []string
+	}{
+		{
+			name:   "none",
+			expect: []string{"alice", "bob"},
+		},
+		{
+			name:   "redact",
+			filter: RedactNames(),
+			expect: []string{RedactedName, RedactedName},
+		},
+		{
+			name:   "hash",
+			filter: HashNames([]byte("key")),
+			expect: []string{HashNames([]byte("key"))("alice"), HashNames([]byte("key"))("bob")},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewClient("sqp", s.Addr().String(),
+				WithArg(sqp.ChunksArg, "info,players"),
+				WithPlayerNameFilter(tc.filter),
+			)
+			require.NoError(t, err)
+			defer c.Close()
+
+			r, err := c.Query()
+			require.NoError(t, err)
+
+			qr := r.(*sqp.QueryResponse)
+			require.EqualValues(t, 2, qr.NumClients())
+			roster := qr.PlayerInfo.Roster()
+			require.Len(t, roster, len(tc.expect))
+			for i, p := range roster {
+				require.Equal(t, tc.expect[i], p.Name)
+				require.EqualValues(t, (i+1)*10, p.Score)
+			}
+		})
+	}
+}
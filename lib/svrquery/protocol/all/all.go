@@ -3,6 +3,7 @@ package all
 
 import (
 	// Register all known protocols
+	_ "github.com/multiplay/go-svrquery/lib/svrquery/protocol/exec"
 	_ "github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
 	_ "github.com/multiplay/go-svrquery/lib/svrquery/protocol/titanfall"
 )
@@ -0,0 +1,65 @@
+package protocol
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+)
+
+// DefaultMaxPacketSize is the default maximum size of a packet, the MTU of
+// 1500 less the IP and UDP headers.
+const DefaultMaxPacketSize = 1472
+
+// Logger is an interface which is implemented by loggers such as
+// *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// discard is a Logger which discards all messages.
+var discard = log.New(ioutil.Discard, "", 0)
+
+// QueryContext carries the resources of a single query, so protocols don't
+// each allocate their own buffers or define their own limits and logging.
+type QueryContext struct {
+	// Context is the context of the query, which carries its deadline and
+	// any tracing span.
+	context.Context
+
+	// Buf is a scratch buffer of MaxPacketSize bytes. It's reused by other
+	// queries once the query returns so responses must not reference it.
+	Buf []byte
+
+	// MaxPacketSize is the maximum size of a packet sent or received.
+	MaxPacketSize int
+
+	// Logger receives debug messages, it's never nil.
+	Logger Logger
+}
+
+// NewQueryContext returns a QueryContext for ctx with a new scratch buffer
+// of maxPacketSize bytes, or DefaultMaxPacketSize if zero, and logger, which
+// discards messages if nil.
+func NewQueryContext(ctx context.Context, maxPacketSize int, logger Logger) *QueryContext {
+	if maxPacketSize <= 0 {
+		maxPacketSize = DefaultMaxPacketSize
+	}
+	if logger == nil {
+		logger = discard
+	}
+
+	return &QueryContext{
+		Context:       ctx,
+		Buf:           make([]byte, maxPacketSize),
+		MaxPacketSize: maxPacketSize,
+		Logger:        logger,
+	}
+}
+
+// ContextQueryer is an interface which is implemented by Queryers which
+// query using a QueryContext. New protocols should implement it, with Query
+// using a QueryContext from NewQueryContext.
+type ContextQueryer interface {
+	Queryer
+	QueryContext(qc *QueryContext) (Responser, error)
+}
@@ -0,0 +1,19 @@
+package protocol
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewQueryContext(t *testing.T) {
+	qc := NewQueryContext(context.Background(), 0, nil)
+	require.Len(t, qc.Buf, DefaultMaxPacketSize)
+	require.Equal(t, DefaultMaxPacketSize, qc.MaxPacketSize)
+	require.NotNil(t, qc.Logger)
+	qc.Logger.Printf("discarded")
+
+	qc = NewQueryContext(context.Background(), 9000, nil)
+	require.Len(t, qc.Buf, 9000)
+}
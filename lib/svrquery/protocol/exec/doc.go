@@ -0,0 +1,18 @@
+// Package exec provides a protocol which runs an external adapter to query a
+// server, allowing protocols to be implemented in any language.
+//
+// The adapter is run once per query with the command set by CommandArg. It's
+// sent a JSON Request on stdin and must write a JSON Response to stdout:
+//
+//	{"address": "10.0.0.1:12121", "key": "", "args": {"mode": "full"}}
+//	{"num_clients": 1, "max_clients": 8, "map": "harbour", "info": {"mode": "ctf"}}
+//
+// Failures are reported by setting error in the response, or by exiting with
+// a non-zero status.
+//
+// Operators can restrict which adapters are run with SetPolicy: to an allow
+// list of paths, and to those signed by trusted ed25519 keys, see Sign.
+// Adapters can also be run with a clean environment which doesn't inherit
+// that of the caller, but this isn't a sandbox. Responses are limited to
+// MaxResponseSize.
+package exec
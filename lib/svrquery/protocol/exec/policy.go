@@ -0,0 +1,251 @@
+package exec
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"sync"
+)
+
+// SignatureSuffix is appended to the path of an adapter to get the path of
+// its signature, see Sign.
+const SignatureSuffix = ".sig"
+
+var (
+	// ErrNotAllowed is returned when an adapter isn't allowed by the policy.
+	ErrNotAllowed = errors.New("adapter not allowed")
+
+	// ErrInvalidSignature is returned when an adapter isn't signed by any of
+	// the trusted keys of the policy.
+	ErrInvalidSignature = errors.New("adapter signature invalid")
+
+	// errResponseTooLarge is returned when an adapter writes a response
+	// larger than MaxResponseSize.
+	errResponseTooLarge = errors.New("response too large")
+)
+
+// Policy restricts which adapters can be run and the environment they're run
+// in, so operators can use third party adapters with confidence.
+type Policy struct {
+	// Allowed, if not empty, are the only adapters which can be run. Paths
+	// are compared after resolving them with PATH and evaluating symlinks.
+	Allowed []string
+
+	// TrustedKeys, if not empty, are the keys one of which must have signed
+	// an adapter before each time it's run, see Sign.
+	TrustedKeys []ed25519.PublicKey
+
+	// CleanEnv runs adapters in an empty temporary working directory, which
+	// is also their HOME and TMPDIR, with only PATH and Env set in their
+	// environment so they don't inherit secrets. It isn't a sandbox, adapters
+	// run as the same user so can access anything it can.
+	CleanEnv bool
+
+	// Env are additional environment variables, in the form key=value, set
+	// for adapters run with CleanEnv.
+	Env []string
+}
+
+var (
+	policyMtx  sync.Mutex
+	policy     Policy
+	policyFunc func() (Policy, error)
+)
+
+// SetPolicy sets the policy applied to the adapters of clients created after
+// it's set.
+func SetPolicy(p Policy) error {
+	p, err := p.checked()
+	if err != nil {
+		return err
+	}
+
+	policyMtx.Lock()
+	defer policyMtx.Unlock()
+	policy, policyFunc = p, nil
+	return nil
+}
+
+// SetPolicyFunc sets f to return the policy applied to the adapters of
+// clients created after it's set. f is only called when the next client is
+// created, so applications which may not use the exec protocol don't fail due
+// to an invalid policy. Errors from f are returned when creating clients.
+func SetPolicyFunc(f func() (Policy, error)) {
+	policyMtx.Lock()
+	defer policyMtx.Unlock()
+	policy, policyFunc = Policy{}, f
+}
+
+// currentPolicy returns the policy set by SetPolicy or SetPolicyFunc.
+func currentPolicy() (Policy, error) {
+	policyMtx.Lock()
+	defer policyMtx.Unlock()
+	if policyFunc == nil {
+		return policy, nil
+	}
+
+	p, err := policyFunc()
+	if err == nil {
+		p, err = p.checked()
+	}
+	if err != nil {
+		return Policy{}, err
+	}
+	policy, policyFunc = p, nil
+	return policy, nil
+}
+
+// checked returns p with its allowed adapters resolved, or an error if it's
+// invalid.
+func (p Policy) checked() (Policy, error) {
+	for _, k := range p.TrustedKeys {
+		if len(k) != ed25519.PublicKeySize {
+			return p, fmt.Errorf("invalid trusted key size %d", len(k))
+		}
+	}
+
+	allowed := make([]string, len(p.Allowed))
+	for i, a := range p.Allowed {
+		var err error
+		if allowed[i], err = resolve(a); err != nil {
+			return p, fmt.Errorf("allowed adapter: %w", err)
+		}
+	}
+	p.Allowed = allowed
+	return p, nil
+}
+
+// Sign returns the signature of adapter by key, which must be written to the
+// file named after the adapter with SignatureSuffix for it to be verified.
+func Sign(key ed25519.PrivateKey, adapter []byte) []byte {
+	digest := sha256.Sum256(adapter)
+	sig := ed25519.Sign(key, digest[:])
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+}
+
+// resolve returns the absolute path of command, searching PATH if it
+// contains no separators, with symlinks evaluated.
+func resolve(command string) (string, error) {
+	path, err := osexec.LookPath(command)
+	if err != nil {
+		return "", err
+	}
+
+	if path, err = filepath.Abs(path); err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// resolves returns true if the policy requires adapters to be resolved, to
+// check them or as they're run in another working directory.
+func (p Policy) resolves() bool {
+	return len(p.Allowed) > 0 || len(p.TrustedKeys) > 0 || p.CleanEnv
+}
+
+// allows returns nil if the adapter at the resolved path is allowed.
+func (p Policy) allows(path string) error {
+	if len(p.Allowed) == 0 {
+		return nil
+	}
+
+	for _, a := range p.Allowed {
+		if a == path {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrNotAllowed, path)
+}
+
+// open opens the adapter at path and verifies it's signed by a trusted key.
+// The adapter must be run from the returned file, see runFile, so it can't be
+// replaced between being verified and run.
+func (p Policy) open(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	adapter, err := ioutil.ReadAll(f)
+	if err == nil {
+		err = p.verify(path, adapter)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// verify returns nil if adapter, read from path, is signed by a trusted key.
+func (p Policy) verify(path string, adapter []byte) error {
+	if len(p.TrustedKeys) == 0 {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(path + SignatureSuffix)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b)))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	digest := sha256.Sum256(adapter)
+	for _, k := range p.TrustedKeys {
+		if ed25519.Verify(k, digest[:], sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidSignature, path)
+}
+
+// clean configures cmd to run with a clean environment in the temporary
+// directory dir.
+func (p Policy) clean(cmd *osexec.Cmd, dir string) {
+	cmd.Dir = dir
+	cmd.Env = append([]string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + dir,
+		"TMPDIR=" + dir,
+	}, p.Env...)
+}
+
+// limitedBuffer is a buffer which fails writes exceeding max, or if truncate
+// is true discards them.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	max      int
+	truncate bool
+}
+
+// Write implements io.Writer.
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if n := b.max - b.buf.Len(); len(p) > n {
+		if !b.truncate {
+			return 0, errResponseTooLarge
+		}
+		b.buf.Write(p[:n])
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// Bytes returns the buffered bytes.
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// String returns the buffered bytes as a string.
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,274 @@
+package exec
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/clienttest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPolicy(t *testing.T) {
+	defer SetPolicy(Policy{})
+
+	dir, err := ioutil.TempDir("", "exec-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	adapter := filepath.Join(dir, "adapter")
+	require.NoError(t, ioutil.WriteFile(adapter, []byte("#!/bin/sh\n"), 0700))
+	link := filepath.Join(dir, "link")
+	require.NoError(t, os.Symlink(adapter, link))
+
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	require.Error(t, SetPolicy(Policy{TrustedKeys: []ed25519.PublicKey{pub[:4]}}))
+	require.Error(t, SetPolicy(Policy{Allowed: []string{filepath.Join(dir, "missing")}}))
+
+	// Allowed adapters are resolved so symlinks match.
+	require.NoError(t, SetPolicy(Policy{Allowed: []string{link}, TrustedKeys: []ed25519.PublicKey{pub}}))
+	p, err := currentPolicy()
+	require.NoError(t, err)
+	require.True(t, p.resolves())
+
+	path, err := resolve(adapter)
+	require.NoError(t, err)
+	require.NoError(t, p.allows(path))
+	require.True(t, errors.Is(p.allows(filepath.Join(dir, "other")), ErrNotAllowed))
+
+	require.False(t, Policy{}.resolves())
+	require.NoError(t, Policy{}.allows(path))
+}
+
+func TestSetPolicyFunc(t *testing.T) {
+	defer SetPolicy(Policy{})
+
+	var calls int
+	errInvalid := errors.New("invalid")
+	SetPolicyFunc(func() (Policy, error) {
+		calls++
+		return Policy{}, errInvalid
+	})
+	require.Zero(t, calls)
+
+	// Errors are returned until the policy is valid.
+	for i := 0; i < 2; i++ {
+		_, err := queryAdapter(os.Args[0], "")
+		require.True(t, errors.Is(err, errInvalid), err)
+	}
+	require.Equal(t, 2, calls)
+
+	SetPolicyFunc(func() (Policy, error) {
+		calls++
+		return Policy{TrustedKeys: []ed25519.PublicKey{nil}}, nil
+	})
+	_, err := currentPolicy()
+	require.Error(t, err)
+
+	// Valid policies are only loaded once.
+	calls = 0
+	SetPolicyFunc(func() (Policy, error) {
+		calls++
+		return Policy{CleanEnv: true}, nil
+	})
+	for i := 0; i < 2; i++ {
+		p, err := currentPolicy()
+		require.NoError(t, err)
+		require.True(t, p.CleanEnv)
+	}
+	require.Equal(t, 1, calls)
+}
+
+func TestPolicyVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exec-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	adapter := filepath.Join(dir, "adapter")
+	b := []byte("#!/bin/sh\n")
+	require.NoError(t, ioutil.WriteFile(adapter, b, 0700))
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	other, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	p := Policy{TrustedKeys: []ed25519.PublicKey{other, pub}}
+	verify := func(p Policy) error {
+		f, err := p.open(adapter)
+		if err == nil {
+			f.Close()
+		}
+		return err
+	}
+
+	// Unsigned.
+	require.True(t, errors.Is(verify(p), ErrInvalidSignature))
+	require.NoError(t, verify(Policy{}))
+
+	require.NoError(t, ioutil.WriteFile(adapter+SignatureSuffix, Sign(priv, b), 0600))
+	require.NoError(t, verify(p))
+
+	// Modified after signing.
+	require.NoError(t, ioutil.WriteFile(adapter, append(b, 0), 0700))
+	require.True(t, errors.Is(verify(p), ErrInvalidSignature))
+
+	// Signed by an untrusted key.
+	require.NoError(t, ioutil.WriteFile(adapter, b, 0700))
+	p = Policy{TrustedKeys: []ed25519.PublicKey{other}}
+	require.True(t, errors.Is(verify(p), ErrInvalidSignature))
+}
+
+func TestPolicyCleanCmd(t *testing.T) {
+	// Only PATH and Env are set so secrets aren't inherited.
+	cmd := osexec.Command("adapter")
+	Policy{Env: []string{"MODE=test"}}.clean(cmd, "/tmp/adapter")
+	require.Equal(t, "/tmp/adapter", cmd.Dir)
+	require.Equal(t, []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=/tmp/adapter",
+		"TMPDIR=/tmp/adapter",
+		"MODE=test",
+	}, cmd.Env)
+}
+
+// queryAdapter queries using the adapter command with mode.
+func queryAdapter(command, mode string) (*Response, error) {
+	mc := &clienttest.MockClient{}
+	mc.On("Args").Return(map[string]interface{}{CommandArg: command + " -test.run=^TestAdapter$", "mode": mode})
+	mc.On("Address").Return("10.0.0.1:12121")
+	mc.On("Key").Return("")
+
+	q, err := newCreator(mc)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := q.Query()
+	if err != nil {
+		return nil, err
+	}
+	return r.(*Response), nil
+}
+
+// copyAdapter copies the test binary, which acts as the adapter, to dir.
+func copyAdapter(t *testing.T, dir string) string {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(os.Args[0])
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "adapter")
+	require.NoError(t, ioutil.WriteFile(path, b, 0700))
+	return path
+}
+
+func TestPolicyAllowed(t *testing.T) {
+	defer setAdapterEnv()()
+	defer SetPolicy(Policy{})
+
+	dir, err := ioutil.TempDir("", "exec-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	adapter := copyAdapter(t, dir)
+
+	require.NoError(t, SetPolicy(Policy{Allowed: []string{adapter}}))
+	_, err = queryAdapter(adapter, "")
+	require.NoError(t, err)
+
+	// Symlinks are resolved to the allowed adapter.
+	link := filepath.Join(dir, "link")
+	require.NoError(t, os.Symlink(adapter, link))
+	_, err = queryAdapter(link, "")
+	require.NoError(t, err)
+
+	_, err = queryAdapter(os.Args[0], "")
+	require.True(t, errors.Is(err, ErrNotAllowed), err)
+
+	require.Error(t, SetPolicy(Policy{Allowed: []string{filepath.Join(dir, "missing")}}))
+}
+
+func TestPolicySignature(t *testing.T) {
+	defer setAdapterEnv()()
+	defer SetPolicy(Policy{})
+
+	dir, err := ioutil.TempDir("", "exec-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	adapter := copyAdapter(t, dir)
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	other, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	require.Error(t, SetPolicy(Policy{TrustedKeys: []ed25519.PublicKey{pub[:4]}}))
+	require.NoError(t, SetPolicy(Policy{TrustedKeys: []ed25519.PublicKey{other, pub}}))
+
+	// Unsigned.
+	_, err = queryAdapter(adapter, "")
+	require.True(t, errors.Is(err, ErrInvalidSignature), err)
+
+	b, err := ioutil.ReadFile(adapter)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(adapter+SignatureSuffix, Sign(priv, b), 0600))
+	_, err = queryAdapter(adapter, "")
+	require.NoError(t, err)
+
+	// Modified after signing.
+	require.NoError(t, ioutil.WriteFile(adapter, append(b, 0), 0700))
+	_, err = queryAdapter(adapter, "")
+	require.True(t, errors.Is(err, ErrInvalidSignature), err)
+
+	// Signed by an untrusted key.
+	require.NoError(t, SetPolicy(Policy{TrustedKeys: []ed25519.PublicKey{other}}))
+	require.NoError(t, ioutil.WriteFile(adapter, b, 0700))
+	_, err = queryAdapter(adapter, "")
+	require.True(t, errors.Is(err, ErrInvalidSignature), err)
+}
+
+func TestPolicyCleanEnv(t *testing.T) {
+	os.Setenv(secretEnv, "secret")
+	defer os.Unsetenv(secretEnv)
+	defer setAdapterEnv()()
+	defer SetPolicy(Policy{})
+
+	// By default adapters inherit the environment.
+	r, err := queryAdapter(os.Args[0], "env")
+	require.NoError(t, err)
+	require.Equal(t, "secret", r.Info["secret"])
+
+	// Relative adapters are resolved before changing working directory.
+	dir, err := ioutil.TempDir("", "exec-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	copyAdapter(t, dir)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	require.NoError(t, SetPolicy(Policy{CleanEnv: true, Env: adapterEnv}))
+	r, err = queryAdapter("."+string(filepath.Separator)+"adapter", "env")
+	require.NoError(t, err)
+	require.Empty(t, r.Info["secret"])
+	require.NotEmpty(t, r.Info["wd"])
+	require.Equal(t, r.Info["wd"], r.Info["home"])
+
+	// The working directory is removed.
+	_, err = os.Stat(r.Info["wd"].(string))
+	require.True(t, os.IsNotExist(err), err)
+}
+
+func TestResponseTooLarge(t *testing.T) {
+	defer setAdapterEnv()()
+
+	_, err := queryAdapter(os.Args[0], "large")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), errResponseTooLarge.Error())
+}
@@ -0,0 +1,171 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"strings"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+const (
+	// CommandArg is the name of the client argument which sets the adapter
+	// command, arguments are separated by spaces.
+	CommandArg = "command"
+
+	// TimeoutArg is the name of the client argument which sets the time the
+	// adapter has to respond, as a time.Duration or string e.g. "2s".
+	TimeoutArg = "timeout"
+)
+
+var (
+	// DefaultTimeout is the default time the adapter has to respond.
+	DefaultTimeout = time.Second * 5
+
+	// MaxResponseSize is the max size of the response of the adapter.
+	MaxResponseSize = 1 << 20
+
+	// ErrMissingCommand is returned when the CommandArg isn't set.
+	ErrMissingCommand = errors.New("missing " + CommandArg + " arg")
+)
+
+// maxStderr is the max size of the stderr of the adapter included in errors.
+const maxStderr = 4096
+
+type queryer struct {
+	c       protocol.Client
+	command []string
+	timeout time.Duration
+	policy  Policy
+}
+
+func newCreator(c protocol.Client) (protocol.Queryer, error) {
+	q := &queryer{c: c, timeout: DefaultTimeout}
+
+	switch v := protocol.Args(c)[CommandArg].(type) {
+	case nil:
+		return nil, ErrMissingCommand
+	case string:
+		if q.command = strings.Fields(v); len(q.command) == 0 {
+			return nil, ErrMissingCommand
+		}
+	default:
+		return nil, fmt.Errorf("invalid %s arg type %T", CommandArg, v)
+	}
+
+	switch v := protocol.Args(c)[TimeoutArg].(type) {
+	case nil:
+	case time.Duration:
+		q.timeout = v
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s arg: %w", TimeoutArg, err)
+		}
+		q.timeout = d
+	default:
+		return nil, fmt.Errorf("invalid %s arg type %T", TimeoutArg, v)
+	}
+
+	// The adapter is resolved once so the adapter checked is the one run,
+	// and relative paths aren't resolved against a clean working directory.
+	var err error
+	if q.policy, err = currentPolicy(); err != nil {
+		return nil, fmt.Errorf("adapter policy: %w", err)
+	}
+
+	if q.policy.resolves() {
+		path, err := resolve(q.command[0])
+		if err != nil {
+			return nil, fmt.Errorf("adapter %s: %w", q.command[0], err)
+		}
+
+		if err = q.policy.allows(path); err != nil {
+			return nil, err
+		}
+		q.command[0] = path
+	}
+
+	return q, nil
+}
+
+// Connectionless implements protocol.Connectionless, the adapter queries the
+// server itself.
+func (q *queryer) Connectionless() {}
+
+// Query implements protocol.Queryer.
+func (q *queryer) Query() (protocol.Responser, error) {
+	return q.QueryContext(protocol.NewQueryContext(context.Background(), 0, nil))
+}
+
+// QueryContext implements protocol.ContextQueryer, the adapter is killed if
+// the context is done.
+func (q *queryer) QueryContext(qc *protocol.QueryContext) (protocol.Responser, error) {
+	req := Request{
+		Address: q.c.Address(),
+		Key:     q.c.Key(),
+		Args:    make(map[string]interface{}),
+	}
+	for k, v := range protocol.Args(q.c) {
+		if k != CommandArg && k != TimeoutArg {
+			req.Args[k] = v
+		}
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var adapter *os.File
+	if len(q.policy.TrustedKeys) > 0 {
+		if adapter, err = q.policy.open(q.command[0]); err != nil {
+			return nil, err
+		}
+		defer adapter.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(qc, q.timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{max: MaxResponseSize}
+	stderr := &limitedBuffer{max: maxStderr, truncate: true}
+	cmd := osexec.CommandContext(ctx, q.command[0], q.command[1:]...)
+	if adapter != nil {
+		runFile(cmd, adapter)
+	}
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if q.policy.CleanEnv {
+		dir, err := ioutil.TempDir("", "svrquery-exec")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		q.policy.clean(cmd, dir)
+	}
+	qc.Logger.Printf("exec: running %s for %s", q.command[0], req.Address)
+	if err = cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("adapter %s: %w", q.command[0], ctx.Err())
+		}
+		return nil, fmt.Errorf("adapter %s: %w: %s", q.command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	resp := &Response{}
+	if err = json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return nil, fmt.Errorf("adapter %s: invalid response: %w", q.command[0], err)
+	} else if resp.Error != "" {
+		return nil, fmt.Errorf("adapter %s: %s", q.command[0], resp.Error)
+	}
+
+	return resp, nil
+}
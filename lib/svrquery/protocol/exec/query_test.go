@@ -0,0 +1,164 @@
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/clienttest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// helperEnv is set when the test binary is run as an adapter.
+	helperEnv = "EXEC_TEST_ADAPTER"
+
+	// secretEnv is set to check adapters run with CleanEnv don't inherit it.
+	secretEnv = "EXEC_TEST_SECRET"
+)
+
+// adapterEnv is the environment which makes the test binary act as the
+// adapter. The race detector otherwise sleeps for a second as the adapter
+// exits.
+var adapterEnv = []string{helperEnv + "=1", "GORACE=atexit_sleep_ms=0"}
+
+// setAdapterEnv sets adapterEnv, returning a func which unsets it.
+func setAdapterEnv() func() {
+	for _, e := range adapterEnv {
+		kv := strings.SplitN(e, "=", 2)
+		os.Setenv(kv[0], kv[1])
+	}
+
+	return func() {
+		for _, e := range adapterEnv {
+			os.Unsetenv(strings.SplitN(e, "=", 2)[0])
+		}
+	}
+}
+
+// TestAdapter isn't a real test, it's run as the adapter by the other tests.
+func TestAdapter(t *testing.T) {
+	if os.Getenv(helperEnv) == "" {
+		return
+	}
+	defer os.Exit(0)
+
+	var req Request
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	switch req.Args["mode"] {
+	case "error":
+		fmt.Print(`{"error":"server unavailable"}`)
+	case "exit":
+		fmt.Fprint(os.Stderr, "crashed")
+		os.Exit(2)
+	case "slow":
+		time.Sleep(time.Second)
+	case "env":
+		wd, _ := os.Getwd()
+		_ = json.NewEncoder(os.Stdout).Encode(Response{
+			Info: map[string]interface{}{"wd": wd, "home": os.Getenv("HOME"), "secret": os.Getenv(secretEnv)},
+		})
+	case "large":
+		fmt.Print(strings.Repeat(" ", MaxResponseSize+1))
+	default:
+		_ = json.NewEncoder(os.Stdout).Encode(Response{
+			Players:    1,
+			MaxPlayers: 8,
+			Map:        "harbour",
+			Info:       map[string]interface{}{"address": req.Address, "key": req.Key},
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	defer setAdapterEnv()()
+
+	command := os.Args[0] + " -test.run=^TestAdapter$"
+	cases := []struct {
+		name string
+		args map[string]interface{}
+		err  string
+	}{
+		{
+			name: "ok",
+			args: map[string]interface{}{CommandArg: command},
+		},
+		{
+			name: "error",
+			args: map[string]interface{}{CommandArg: command, "mode": "error"},
+			err:  "server unavailable",
+		},
+		{
+			name: "exit",
+			args: map[string]interface{}{CommandArg: command, "mode": "exit"},
+			err:  "crashed",
+		},
+		{
+			name: "timeout",
+			args: map[string]interface{}{CommandArg: command, TimeoutArg: "100ms", "mode": "slow"},
+			err:  "deadline exceeded",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &clienttest.MockClient{}
+			mc.On("Args").Return(tc.args)
+			mc.On("Address").Return("10.0.0.1:12121")
+			mc.On("Key").Return("secret")
+
+			q, err := newCreator(mc)
+			require.NoError(t, err)
+
+			r, err := q.Query()
+			if tc.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, &Response{
+				Players:    1,
+				MaxPlayers: 8,
+				Map:        "harbour",
+				Info:       map[string]interface{}{"address": "10.0.0.1:12121", "key": "secret"},
+			}, r)
+		})
+	}
+}
+
+func TestNewCreator(t *testing.T) {
+	cases := []struct {
+		name string
+		args map[string]interface{}
+		err  bool
+	}{
+		{name: "missing", args: map[string]interface{}{}, err: true},
+		{name: "empty", args: map[string]interface{}{CommandArg: " "}, err: true},
+		{name: "type", args: map[string]interface{}{CommandArg: 1}, err: true},
+		{name: "timeout", args: map[string]interface{}{CommandArg: "adapter", TimeoutArg: "soon"}, err: true},
+		{name: "ok", args: map[string]interface{}{CommandArg: "adapter --full", TimeoutArg: time.Second}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &clienttest.MockClient{}
+			mc.On("Args").Return(tc.args)
+
+			_, err := newCreator(mc)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
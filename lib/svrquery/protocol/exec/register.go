@@ -0,0 +1,9 @@
+package exec
+
+import (
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+func init() {
+	protocol.MustRegisterChecked("exec", newCreator)
+}
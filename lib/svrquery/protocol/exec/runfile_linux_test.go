@@ -0,0 +1,41 @@
+package exec
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunFile(t *testing.T) {
+	defer setAdapterEnv()()
+
+	dir, err := ioutil.TempDir("", "exec-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	adapter := copyAdapter(t, dir)
+
+	f, err := os.Open(adapter)
+	require.NoError(t, err)
+	defer f.Close()
+
+	// Replace the adapter after it's opened.
+	replaced := filepath.Join(dir, "replaced")
+	require.NoError(t, ioutil.WriteFile(replaced, []byte("#!/bin/sh\nexit 3\n"), 0700))
+	require.NoError(t, os.Rename(replaced, adapter))
+
+	cmd := osexec.Command(adapter, "-test.run=^TestAdapter$")
+	runFile(cmd, f)
+	cmd.Stdin = strings.NewReader("{}")
+	out, err := cmd.Output()
+	require.NoError(t, err)
+
+	var r Response
+	require.NoError(t, json.Unmarshal(out, &r))
+	require.Equal(t, "harbour", r.Map)
+}
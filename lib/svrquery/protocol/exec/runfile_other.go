@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package exec
+
+import (
+	"os"
+	osexec "os/exec"
+)
+
+// runFile does nothing as running open files is only supported on Linux. An
+// adapter could be replaced between being verified and run, so it and its
+// directory mustn't be writable by untrusted users.
+func runFile(cmd *osexec.Cmd, f *os.File) {}
@@ -0,0 +1,45 @@
+package exec
+
+import (
+	"fmt"
+)
+
+// Request is the JSON request written to the stdin of the adapter.
+type Request struct {
+	Address string                 `json:"address"`
+	Key     string                 `json:"key"`
+	Args    map[string]interface{} `json:"args"`
+}
+
+// Response is the JSON response read from the stdout of the adapter.
+type Response struct {
+	Players    int64                  `json:"num_clients"`
+	MaxPlayers int64                  `json:"max_clients"`
+	Map        string                 `json:"map,omitempty"`
+	Info       map[string]interface{} `json:"info,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// NumClients implements protocol.Responser.
+func (r *Response) NumClients() int64 {
+	return r.Players
+}
+
+// MaxClients implements protocol.Responser.
+func (r *Response) MaxClients() int64 {
+	return r.MaxPlayers
+}
+
+// MapName implements protocol.Mapper.
+func (r *Response) MapName() string {
+	return r.Map
+}
+
+// Rules implements protocol.Ruler.
+func (r *Response) Rules() map[string]string {
+	rules := make(map[string]string, len(r.Info))
+	for k, v := range r.Info {
+		rules[k] = fmt.Sprint(v)
+	}
+	return rules
+}
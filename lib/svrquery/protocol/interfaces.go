@@ -24,6 +24,58 @@ type Client interface {
 	Address() string
 }
 
+// Arguer is an interface which is implemented by Clients which pass protocol
+// specific arguments to Queryers.
+type Arguer interface {
+	Args() map[string]interface{}
+}
+
+// Args returns the protocol specific arguments of c, or nil if c isn't an
+// Arguer.
+func Args(c Client) map[string]interface{} {
+	if a, ok := c.(Arguer); ok {
+		return a.Args()
+	}
+	return nil
+}
+
+// Connectionless is an interface which is implemented by Queryers which don't
+// read or write their Client, so it doesn't need to connect to the server.
+type Connectionless interface {
+	Connectionless()
+}
+
+// Challenger is an interface which is implemented by Queryers which perform a
+// challenge handshake before querying.
+type Challenger interface {
+	Challenge() error
+}
+
+// Mapper is an interface which is implemented by Responsers that report the
+// current map.
+type Mapper interface {
+	MapName() string
+}
+
+// Ruler is an interface which is implemented by Responsers that report
+// protocol specific rules, such as the game mode, as key value pairs.
+type Ruler interface {
+	Rules() map[string]string
+}
+
+// BuildIDer is an interface which is implemented by Responsers that report
+// the build or version of the server.
+type BuildIDer interface {
+	BuildID() string
+}
+
+// PlayerNamer is an interface which is implemented by Responsers that report
+// player names, allowing them to be hashed or redacted.
+type PlayerNamer interface {
+	// MapPlayerNames replaces each player name with the result of f.
+	MapPlayerNames(f func(name string) string)
+}
+
 // Charter is an interface which is implemented by types which support custom netdata
 // charts.
 type Charter interface {
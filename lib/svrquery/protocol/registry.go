@@ -2,26 +2,57 @@ package protocol
 
 import (
 	"fmt"
+	"sort"
 )
 
 // Creator is a function which returns a Queryer.
 type Creator func(c Client) Queryer
 
+// CheckedCreator is a function which returns a Queryer, or an error if it
+// can't query using c e.g. its arguments are invalid.
+type CheckedCreator func(c Client) (Queryer, error)
+
 var (
-	registry = make(map[string]Creator)
+	registry = make(map[string]CheckedCreator)
 )
 
 // MustRegister registers a protocol.
 // Panics if the name is a duplicate.
 func MustRegister(name string, f Creator) {
+	MustRegisterChecked(name, func(c Client) (Queryer, error) {
+		return f(c), nil
+	})
+}
+
+// MustRegisterChecked registers a protocol whose creator can fail.
+// Panics if the name is a duplicate.
+func MustRegisterChecked(name string, f CheckedCreator) {
 	if _, ok := registry[name]; ok {
 		panic(fmt.Sprintf("%s is already in registry", name))
 	}
 	registry[name] = f
 }
 
-// Get returns the creator a protocol.
+// Get returns the creator a protocol. If the creator of a protocol registered
+// with MustRegisterChecked fails, the error is returned by Query of the
+// returned Queryer, use GetChecked to get it on creation.
 func Get(name string) (Creator, error) {
+	f, err := GetChecked(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c Client) Queryer {
+		q, err := f(c)
+		if err != nil {
+			return errQueryer{err: err}
+		}
+		return q
+	}, nil
+}
+
+// GetChecked returns the creator a protocol.
+func GetChecked(name string) (CheckedCreator, error) {
 	f, ok := registry[name]
 	if !ok {
 		return nil, fmt.Errorf("unknown protocol %q", name)
@@ -29,8 +60,28 @@ func Get(name string) (Creator, error) {
 	return f, nil
 }
 
+// errQueryer is a Queryer which returns the error of a failed creator.
+type errQueryer struct {
+	err error
+}
+
+// Query implements Queryer.
+func (q errQueryer) Query() (Responser, error) {
+	return nil, q.err
+}
+
 // Supported returns true if protocol name is supported.
 func Supported(name string) bool {
 	_, ok := registry[name]
 	return ok
 }
+
+// Names returns the sorted names of all registered protocols.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,67 @@
+package protocol
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testQueryer struct{}
+
+func (testQueryer) Query() (Responser, error) {
+	return nil, nil
+}
+
+type testClient struct {
+	Client
+	args map[string]interface{}
+}
+
+func (c testClient) Args() map[string]interface{} {
+	return c.args
+}
+
+func TestRegistry(t *testing.T) {
+	errInvalid := errors.New("invalid")
+	MustRegister("test-plain", func(c Client) Queryer { return testQueryer{} })
+	MustRegisterChecked("test-checked", func(c Client) (Queryer, error) {
+		if Args(c)["fail"] != nil {
+			return nil, errInvalid
+		}
+		return testQueryer{}, nil
+	})
+	defer delete(registry, "test-plain")
+	defer delete(registry, "test-checked")
+
+	require.Panics(t, func() {
+		MustRegister("test-plain", func(c Client) Queryer { return testQueryer{} })
+	})
+
+	f, err := GetChecked("test-plain")
+	require.NoError(t, err)
+	q, err := f(nil)
+	require.NoError(t, err)
+	require.Equal(t, testQueryer{}, q)
+
+	c := testClient{args: map[string]interface{}{"fail": true}}
+	cf, err := GetChecked("test-checked")
+	require.NoError(t, err)
+	_, err = cf(c)
+	require.Equal(t, errInvalid, err)
+
+	// Errors of checked creators are returned by Query of plain creators.
+	pf, err := Get("test-checked")
+	require.NoError(t, err)
+	_, err = pf(c).Query()
+	require.Equal(t, errInvalid, err)
+	require.Equal(t, testQueryer{}, pf(testClient{}))
+
+	_, err = Get("unknown")
+	require.Error(t, err)
+	_, err = GetChecked("unknown")
+	require.Error(t, err)
+
+	// Clients which aren't Arguers have no args.
+	require.Nil(t, Args(struct{ Client }{}))
+}
@@ -0,0 +1,39 @@
+package sqp
+
+import (
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/clienttest"
+)
+
+func BenchmarkQuery(b *testing.B) {
+	cases := []struct {
+		name   string
+		file   string
+		chunks byte
+	}{
+		{name: "info", file: "info_single_response", chunks: ServerInfo},
+		{name: "rules", file: "rules_response", chunks: ServerRules},
+		{name: "player", file: "player_response", chunks: PlayerInfo},
+		{name: "team", file: "team_response", chunks: TeamInfo},
+	}
+
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			chal := clienttest.LoadData(b, testDir, "challenge_success_response")
+			resp := clienttest.LoadData(b, testDir, tc.file)
+			testSetChallenge(resp, chal)
+			q := newQueryer(tc.chunks, DefaultMaxPacketSize, &clienttest.ReplayClient{
+				Packets: [][]byte{chal, resp},
+				Addr:    "127.0.0.1:8000",
+			})
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := q.Query(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -6,19 +6,20 @@ import (
 
 // Challenge sends a challenge request and validates a response
 func (q *queryer) Challenge() error {
-	if err := q.sendChallenge(); err != nil {
-		return err
-	}
+	_, err := q.challenge.Do()
+	return err
+}
 
+// receiveChallenge reads a challenge response and returns its challenge.
+func (q *queryer) receiveChallenge() (uint32, error) {
 	pktType, err := q.reader.ReadByte()
 	if err != nil {
-		return err
+		return 0, err
 	} else if pktType != ChallengeResponseType {
-		return NewErrMalformedPacketf("was expecting 0x%02x for response type, got 0x%02x", ChallengeResponseType, pktType)
+		return 0, NewErrMalformedPacketf("was expecting 0x%02x for response type, got 0x%02x", ChallengeResponseType, pktType)
 	}
 
-	q.challengeID, err = q.readChallenge()
-	return err
+	return q.readChallenge()
 }
 
 // sendChallenge writes a challenge request
@@ -43,12 +44,16 @@ func (q *queryer) readChallenge() (uint32, error) {
 	return q.reader.ReadUint32()
 }
 
-// validateChallenge reads and validates the challenge of a request against our current challengeID.
+// validateChallenge reads and validates the challenge of a request against our current challenge.
+// Any challenge is accepted when the handshake policy is HandshakeNever.
 func (q *queryer) validateChallenge() error {
-	if id, err := q.readChallenge(); err != nil {
+	id, err := q.readChallenge()
+	if err != nil {
 		return err
-	} else if id != q.challengeID {
-		return NewErrMalformedPacketf("was expecting 0x%04x for challengeID, got 0x%04x", q.challengeID, id)
+	}
+
+	if expected, _ := q.challenge.Cookie(); q.handshake != HandshakeNever && id != expected {
+		return NewErrMalformedPacketf("was expecting 0x%04x for challengeID, got 0x%04x", expected, id)
 	}
 	return nil
 }
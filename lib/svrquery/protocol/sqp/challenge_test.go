@@ -17,7 +17,8 @@ func TestChallenge(t *testing.T) {
 			name: "success",
 			f: func(t *testing.T, c *queryer) {
 				require.NoError(t, c.Challenge(), "challenge request failed")
-				require.Equal(t, uint32(256), c.challengeID)
+				id, _ := c.challenge.Cookie()
+				require.Equal(t, uint32(256), id)
 			},
 		},
 		{
@@ -0,0 +1,77 @@
+package sqp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+// ChunksArg is the name of the client argument which sets the requested chunks.
+// Its value can either be a byte mask of chunks or a comma separated list of
+// chunk names as accepted by ParseChunks.
+const ChunksArg = "chunks"
+
+// AllChunks is the mask of all known chunks.
+const AllChunks = ServerInfo | ServerRules | PlayerInfo | TeamInfo
+
+var chunkNames = []struct {
+	name  string
+	chunk byte
+}{
+	{name: "info", chunk: ServerInfo},
+	{name: "rules", chunk: ServerRules},
+	{name: "players", chunk: PlayerInfo},
+	{name: "teams", chunk: TeamInfo},
+	{name: "all", chunk: AllChunks},
+}
+
+// ParseChunks returns the chunk mask for the comma separated list of chunk
+// names s. Valid names are info, rules, players, teams and all.
+func ParseChunks(s string) (byte, error) {
+	var chunks byte
+	for _, name := range strings.Split(s, ",") {
+		chunk, err := parseChunk(strings.TrimSpace(name))
+		if err != nil {
+			return 0, err
+		}
+		chunks |= chunk
+	}
+	return chunks, nil
+}
+
+// parseChunk returns the chunk mask for name.
+func parseChunk(name string) (byte, error) {
+	for _, cn := range chunkNames {
+		if cn.name == name {
+			return cn.chunk, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown chunk %q", name)
+}
+
+// requestedChunks returns the chunks requested by the args of c.
+func requestedChunks(c protocol.Client) (byte, error) {
+	v, ok := protocol.Args(c)[ChunksArg]
+	if !ok {
+		return ServerInfo, nil
+	}
+
+	var chunks byte
+	switch v := v.(type) {
+	case byte:
+		chunks = v
+	case string:
+		var err error
+		if chunks, err = ParseChunks(v); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("invalid %s arg type %T", ChunksArg, v)
+	}
+
+	if chunks == 0 || chunks&^AllChunks != 0 {
+		return 0, fmt.Errorf("invalid %s arg 0x%02x", ChunksArg, chunks)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,73 @@
+package sqp
+
+import (
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/clienttest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunksArg(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     map[string]interface{}
+		expected byte
+		err      bool
+	}{
+		{
+			name:     "default",
+			args:     map[string]interface{}{},
+			expected: ServerInfo,
+		},
+		{
+			name:     "names",
+			args:     map[string]interface{}{ChunksArg: "info, players"},
+			expected: ServerInfo | PlayerInfo,
+		},
+		{
+			name:     "all",
+			args:     map[string]interface{}{ChunksArg: "all"},
+			expected: AllChunks,
+		},
+		{
+			name:     "mask",
+			args:     map[string]interface{}{ChunksArg: ServerRules | TeamInfo},
+			expected: ServerRules | TeamInfo,
+		},
+		{
+			name: "unknown-name",
+			args: map[string]interface{}{ChunksArg: "info,maps"},
+			err:  true,
+		},
+		{
+			name: "unknown-mask",
+			args: map[string]interface{}{ChunksArg: byte(0x10)},
+			err:  true,
+		},
+		{
+			name: "empty-mask",
+			args: map[string]interface{}{ChunksArg: byte(0)},
+			err:  true,
+		},
+		{
+			name: "invalid-type",
+			args: map[string]interface{}{ChunksArg: 1},
+			err:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &clienttest.MockClient{}
+			m.On("Args").Return(tc.args)
+
+			q, err := newCreator(m)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, q.(*queryer).requestedChunks)
+		})
+	}
+}
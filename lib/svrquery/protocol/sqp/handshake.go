@@ -0,0 +1,98 @@
+package sqp
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+// HandshakeArg is the name of the client argument which sets the HandshakePolicy.
+// Its value can either be a HandshakePolicy or its string representation.
+const HandshakeArg = "handshake"
+
+// RetriesArg is the name of the client argument which sets the number of
+// times a challenge request is resent if the response times out. Its value
+// can either be an int or its string representation.
+const RetriesArg = "challenge_retries"
+
+// HandshakePolicy determines when a challenge handshake is performed before a query.
+type HandshakePolicy byte
+
+const (
+	// HandshakeAlways performs a new challenge handshake before every query.
+	HandshakeAlways HandshakePolicy = iota
+
+	// HandshakeCached performs a challenge handshake before the first query and
+	// reuses the challenge for subsequent queries until a query fails.
+	HandshakeCached
+
+	// HandshakeNever never performs a challenge handshake and accepts any challenge
+	// in responses, for servers which don't implement challenges.
+	HandshakeNever
+)
+
+var handshakePolicies = map[HandshakePolicy]string{
+	HandshakeAlways: "always",
+	HandshakeCached: "cached",
+	HandshakeNever:  "never",
+}
+
+// String implements fmt.Stringer.
+func (hp HandshakePolicy) String() string {
+	if s, ok := handshakePolicies[hp]; ok {
+		return s
+	}
+	return fmt.Sprintf("HandshakePolicy(%d)", hp)
+}
+
+// ParseHandshakePolicy returns the HandshakePolicy represented by s.
+func ParseHandshakePolicy(s string) (HandshakePolicy, error) {
+	for hp, name := range handshakePolicies {
+		if name == s {
+			return hp, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown handshake policy %q", s)
+}
+
+// handshakePolicy returns the HandshakePolicy requested by the args of c.
+func handshakePolicy(c protocol.Client) (HandshakePolicy, error) {
+	v, ok := protocol.Args(c)[HandshakeArg]
+	if !ok {
+		return HandshakeAlways, nil
+	}
+
+	switch v := v.(type) {
+	case HandshakePolicy:
+		if _, ok := handshakePolicies[v]; !ok {
+			return 0, fmt.Errorf("unknown handshake policy %v", v)
+		}
+		return v, nil
+	case string:
+		return ParseHandshakePolicy(v)
+	}
+
+	return 0, fmt.Errorf("invalid %s arg type %T", HandshakeArg, v)
+}
+
+// challengeRetries returns the challenge retries requested by the args of c.
+func challengeRetries(c protocol.Client) (int, error) {
+	switch v := protocol.Args(c)[RetriesArg].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		if v < 0 {
+			return 0, fmt.Errorf("negative %s arg %d", RetriesArg, v)
+		}
+		return v, nil
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid %s arg %q", RetriesArg, v)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("invalid %s arg type %T", RetriesArg, v)
+	}
+}
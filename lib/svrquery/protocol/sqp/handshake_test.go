@@ -0,0 +1,167 @@
+package sqp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/clienttest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandshakePolicyArg(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     map[string]interface{}
+		expected HandshakePolicy
+		err      bool
+	}{
+		{
+			name:     "default",
+			args:     map[string]interface{}{},
+			expected: HandshakeAlways,
+		},
+		{
+			name:     "string",
+			args:     map[string]interface{}{HandshakeArg: "cached"},
+			expected: HandshakeCached,
+		},
+		{
+			name:     "policy",
+			args:     map[string]interface{}{HandshakeArg: HandshakeNever},
+			expected: HandshakeNever,
+		},
+		{
+			name: "unknown-string",
+			args: map[string]interface{}{HandshakeArg: "sometimes"},
+			err:  true,
+		},
+		{
+			name: "unknown-policy",
+			args: map[string]interface{}{HandshakeArg: HandshakePolicy(10)},
+			err:  true,
+		},
+		{
+			name: "invalid-type",
+			args: map[string]interface{}{HandshakeArg: 1},
+			err:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &clienttest.MockClient{}
+			m.On("Args").Return(tc.args)
+
+			q, err := newCreator(m)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, q.(*queryer).handshake)
+		})
+	}
+}
+
+func TestQueryHandshakePolicy(t *testing.T) {
+	cases := []struct {
+		policy     HandshakePolicy
+		challenges int
+	}{
+		{policy: HandshakeAlways, challenges: 2},
+		{policy: HandshakeCached, challenges: 1},
+		{policy: HandshakeNever, challenges: 0},
+	}
+
+	chalReq := clienttest.LoadData(t, testDir, "challenge_success_request")
+	for _, tc := range cases {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			m, c := newClient(ServerInfo)
+			c.handshake = tc.policy
+
+			chalResp := []byte{ChallengeResponseType, 0, 0, 0, 0}
+			if tc.policy != HandshakeNever {
+				chalResp = []byte{ChallengeResponseType, 1, 2, 3, 4}
+			}
+
+			req := clienttest.LoadData(t, testDir, "info_single_request")
+			testSetChallenge(req, chalResp)
+			resp := clienttest.LoadData(t, testDir, "info_single_response")
+			testSetChallenge(resp, chalResp)
+
+			m.On("Write", req).Return(len(req), nil)
+			for i := 0; i < 2; i++ {
+				if i < tc.challenges {
+					m.On("Write", chalReq).Return(len(chalReq), nil).Once()
+					m.On("Read", mock.AnythingOfType("[]uint8")).Return(chalResp, nil).Once()
+				}
+				m.On("Read", mock.AnythingOfType("[]uint8")).Return(resp, nil).Once()
+
+				r, err := c.Query()
+				require.NoError(t, err)
+				require.Equal(t, uint16(5), r.(*QueryResponse).ServerInfo.CurrentPlayers)
+			}
+
+			m.AssertNumberOfCalls(t, "Write", 2+tc.challenges)
+		})
+	}
+}
+
+func TestQueryHandshakeCachedReset(t *testing.T) {
+	m, c := newClient(ServerInfo)
+	c.handshake = HandshakeCached
+	c.challenge.Set(0x01020304)
+
+	// Server has expired our challenge and responds with a new one.
+	req := clienttest.LoadData(t, testDir, "info_single_request")
+	binary.BigEndian.PutUint32(req[1:5], 0x01020304)
+	resp := clienttest.LoadData(t, testDir, "info_single_response")
+	binary.BigEndian.PutUint32(resp[1:5], 0x05060708)
+
+	m.On("Write", req).Return(len(req), nil).Once()
+	m.On("Read", mock.AnythingOfType("[]uint8")).Return(resp, nil).Once()
+
+	_, err := c.Query()
+	require.Error(t, err)
+	_, ok := c.challenge.Cookie()
+	require.False(t, ok)
+
+	// The next query must perform a new handshake.
+	chalReq := clienttest.LoadData(t, testDir, "challenge_success_request")
+	m.On("Write", chalReq).Return(len(chalReq), nil).Once()
+	m.On("Read", mock.AnythingOfType("[]uint8")).Return(bytes.Join([][]byte{{ChallengeResponseType}, resp[1:5]}, nil), nil).Once()
+	req = clienttest.LoadData(t, testDir, "info_single_request")
+	testSetChallenge(req, resp)
+	m.On("Write", req).Return(len(req), nil).Once()
+	m.On("Read", mock.AnythingOfType("[]uint8")).Return(resp, nil).Once()
+
+	_, err = c.Query()
+	require.NoError(t, err)
+	_, ok = c.challenge.Cookie()
+	require.True(t, ok)
+}
+
+func TestChallengeRetries(t *testing.T) {
+	req := clienttest.LoadData(t, testDir, "challenge_success_request")
+	resp := clienttest.LoadData(t, testDir, "challenge_success_response")
+
+	m := &clienttest.MockClient{}
+	m.On("Args").Return(map[string]interface{}{RetriesArg: "1"})
+	m.On("Write", req).Return(len(req), nil)
+	m.On("Read", mock.AnythingOfType("[]uint8")).Return([]byte{}, timeoutErr{}).Once()
+	m.On("Read", mock.AnythingOfType("[]uint8")).Return(resp, nil).Once()
+
+	q, err := newCreator(m)
+	require.NoError(t, err)
+	require.NoError(t, q.(*queryer).Challenge())
+	m.AssertNumberOfCalls(t, "Write", 2)
+
+	for _, v := range []interface{}{-1, "x", 1.5} {
+		m := &clienttest.MockClient{}
+		m.On("Args").Return(map[string]interface{}{RetriesArg: v})
+		_, err = newCreator(m)
+		require.Error(t, err)
+	}
+}
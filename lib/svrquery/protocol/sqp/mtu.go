@@ -0,0 +1,114 @@
+package sqp
+
+import (
+	"errors"
+	"net"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+// mtuProbeChunks are the chunks requested by each step of an MTU probe in
+// order of increasing response size.
+var mtuProbeChunks = []byte{
+	ServerInfo,
+	ServerInfo | ServerRules,
+	ServerInfo | ServerRules | PlayerInfo,
+	AllChunks,
+}
+
+// MTUProbeStep is the result of a single query of an MTU probe.
+type MTUProbeStep struct {
+	// Chunks is the mask of chunks requested.
+	Chunks byte `json:"chunks"`
+
+	// Bytes is the total number of bytes received in response packets.
+	Bytes int `json:"bytes"`
+
+	// Packets is the number of response packets received.
+	Packets int `json:"packets"`
+
+	// MaxPacket is the size of the largest response packet received.
+	MaxPacket int `json:"max_packet"`
+
+	// Err is the error returned by the query if any.
+	Err error `json:"-"`
+}
+
+// MTUProbeResult is the result of an MTU probe.
+type MTUProbeResult struct {
+	Steps []MTUProbeStep `json:"steps"`
+
+	// MaxPacket is the size of the largest response packet successfully received.
+	MaxPacket int `json:"max_packet"`
+
+	// Blackhole is true if small responses were received but larger ones timed out,
+	// which indicates packets are being dropped by the path.
+	Blackhole bool `json:"blackhole"`
+
+	// SuggestedMaxPacketSize is the max response packet size responders should use
+	// to avoid the blackhole, zero if no blackhole was detected.
+	SuggestedMaxPacketSize int `json:"suggested_max_packet_size,omitempty"`
+}
+
+// ProbeMTU queries the server at c with increasingly large sets of chunks to
+// determine if large single or multi-packet responses are being dropped by the
+// path, for example due to an IP fragmentation blackhole. An error is returned
+// only if the smallest query fails.
+func ProbeMTU(c protocol.Client) (*MTUProbeResult, error) {
+	hp, err := handshakePolicy(c)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &MTUProbeResult{}
+	for _, chunks := range mtuProbeChunks {
+		cc := &countingClient{Client: c}
+		q := newQueryer(chunks, DefaultMaxPacketSize, cc)
+		q.handshake = hp
+
+		step := MTUProbeStep{Chunks: chunks}
+		_, step.Err = q.Query()
+		step.Bytes, step.Packets, step.MaxPacket = cc.bytes, cc.packets, cc.maxPacket
+		res.Steps = append(res.Steps, step)
+
+		if step.Err != nil {
+			if len(res.Steps) == 1 {
+				return nil, step.Err
+			}
+
+			var ne net.Error
+			if errors.As(step.Err, &ne) && ne.Timeout() {
+				res.Blackhole = true
+				res.SuggestedMaxPacketSize = res.MaxPacket
+			}
+			break
+		}
+
+		if step.MaxPacket > res.MaxPacket {
+			res.MaxPacket = step.MaxPacket
+		}
+	}
+
+	return res, nil
+}
+
+// countingClient is a protocol.Client which counts the packets read.
+type countingClient struct {
+	protocol.Client
+	bytes     int
+	packets   int
+	maxPacket int
+}
+
+// Read implements io.Reader.
+func (c *countingClient) Read(b []byte) (int, error) {
+	n, err := c.Client.Read(b)
+	if n > 0 {
+		c.bytes += n
+		c.packets++
+		if n > c.maxPacket {
+			c.maxPacket = n
+		}
+	}
+	return n, err
+}
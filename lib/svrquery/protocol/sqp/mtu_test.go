@@ -0,0 +1,83 @@
+package sqp
+
+import (
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/clienttest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestProbeMTU(t *testing.T) {
+	chalResp := []byte{ChallengeResponseType, 0, 0, 0, 1}
+	infoResp := clienttest.LoadData(t, testDir, "info_single_response")
+	testSetChallenge(infoResp, chalResp)
+
+	cases := []struct {
+		name      string
+		reads     [][]byte
+		errs      []error
+		steps     int
+		blackhole bool
+		err       bool
+	}{
+		{
+			name:  "all-succeed",
+			reads: [][]byte{chalResp, infoResp, chalResp, infoResp, chalResp, infoResp, chalResp, infoResp},
+			steps: 4,
+		},
+		{
+			name:      "blackhole",
+			reads:     [][]byte{chalResp, infoResp, chalResp, {}},
+			errs:      []error{nil, nil, nil, timeoutErr{}},
+			steps:     2,
+			blackhole: true,
+		},
+		{
+			name:  "first-fails",
+			reads: [][]byte{{}},
+			errs:  []error{timeoutErr{}},
+			err:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &clienttest.MockClient{}
+			m.On("Args").Return(map[string]interface{}{})
+			m.On("Address").Return("127.0.0.1:8000")
+			m.On("Write", mock.AnythingOfType("[]uint8")).Return(0, nil)
+			for i, r := range tc.reads {
+				var err error
+				if i < len(tc.errs) {
+					err = tc.errs[i]
+				}
+				m.On("Read", mock.AnythingOfType("[]uint8")).Return(r, err).Once()
+			}
+
+			res, err := ProbeMTU(m)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, res.Steps, tc.steps)
+			require.Equal(t, len(infoResp), res.MaxPacket)
+			require.Equal(t, len(chalResp)+len(infoResp), res.Steps[0].Bytes)
+			require.Equal(t, 2, res.Steps[0].Packets)
+			require.Equal(t, tc.blackhole, res.Blackhole)
+			if tc.blackhole {
+				require.Equal(t, len(infoResp), res.SuggestedMaxPacketSize)
+				require.Error(t, res.Steps[len(res.Steps)-1].Err)
+			} else {
+				require.Zero(t, res.SuggestedMaxPacketSize)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"io/ioutil"
 
+	"github.com/multiplay/go-svrquery/lib/svrquery/common"
 	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
 )
 
@@ -14,35 +15,91 @@ type queryer struct {
 	c               protocol.Client
 	maxPktSize      int
 	reader          *packetReader
-	challengeID     uint32
+	challenge       *common.Challenge
+	lengthOrder     lengthOrder
+	littleLengths   bool
+	handshake       HandshakePolicy
 	requestedChunks byte
 }
 
-func newCreator(c protocol.Client) protocol.Queryer {
-	return newQueryer(ServerInfo, DefaultMaxPacketSize, c)
+func newCreator(c protocol.Client) (protocol.Queryer, error) {
+	hp, err := handshakePolicy(c)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := requestedChunks(c)
+	if err != nil {
+		return nil, err
+	}
+
+	retries, err := challengeRetries(c)
+	if err != nil {
+		return nil, err
+	}
+
+	lo, err := requestedLengthOrder(c)
+	if err != nil {
+		return nil, err
+	}
+
+	q := newQueryer(chunks, DefaultMaxPacketSize, c)
+	q.handshake = hp
+	q.challenge = common.NewChallenge(q.sendChallenge, q.receiveChallenge, retries)
+	q.lengthOrder = lo
+	return q, nil
 }
 
 func newQueryer(requestedChunks byte, maxPktSize int, c protocol.Client) *queryer {
-	return &queryer{
+	q := &queryer{
 		c:               c,
 		maxPktSize:      maxPktSize,
 		requestedChunks: requestedChunks,
 		reader:          newPacketReader(bufio.NewReaderSize(c, maxPktSize)),
 	}
+	q.challenge = common.NewChallenge(q.sendChallenge, q.receiveChallenge, 0)
+	return q
 }
 
 // Query implements protocol.Queryer.
 func (q *queryer) Query() (protocol.Responser, error) {
 	if err := q.sendQuery(q.requestedChunks); err != nil {
+		q.reset()
+		return nil, err
+	}
+
+	qr, err := q.readQuery(q.requestedChunks)
+	if err != nil {
+		q.reset()
 		return nil, err
 	}
 
-	return q.readQuery(q.requestedChunks)
+	return qr, nil
+}
+
+// reset discards any partially read packet data and forces a new handshake
+// as the cached challenge may have expired.
+func (q *queryer) reset() {
+	q.challenge.Reset()
+	q.reader = newPacketReader(bufio.NewReaderSize(q.c, q.maxPktSize))
+}
+
+// doHandshake performs a challenge handshake if required by the handshake policy.
+func (q *queryer) doHandshake() error {
+	switch q.handshake {
+	case HandshakeNever:
+		return nil
+	case HandshakeCached:
+		if _, ok := q.challenge.Cookie(); ok {
+			return nil
+		}
+	}
+
+	return q.Challenge()
 }
 
 func (q *queryer) sendQuery(requestedChunks byte) error {
-	// Each query requires a new challenge.
-	if err := q.Challenge(); err != nil {
+	if err := q.doHandshake(); err != nil {
 		return err
 	}
 
@@ -51,7 +108,7 @@ func (q *queryer) sendQuery(requestedChunks byte) error {
 		return err
 	}
 
-	if err := binary.Write(pkt, binary.BigEndian, q.challengeID); err != nil {
+	if _, err := pkt.Write(q.challenge.Attach(nil, binary.BigEndian)); err != nil {
 		return err
 	}
 
@@ -99,6 +156,7 @@ func (q *queryer) readQueryHeader() (uint16, byte, byte, uint16, error) {
 	if err != nil {
 		return 0, 0, 0, 0, err
 	}
+	pktLen = q.packetLength(pktLen)
 
 	if curPkt > lastPkt {
 		return 0, 0, 0, 0, ErrMalformedPacket("current packet id > last packet id")
@@ -108,6 +166,7 @@ func (q *queryer) readQueryHeader() (uint16, byte, byte, uint16, error) {
 }
 
 func (q *queryer) readQuery(requestedChunks byte) (*QueryResponse, error) {
+	q.littleLengths = q.lengthOrder == lengthsLittle
 	version, curPkt, lastPkt, pktLen, err := q.readQueryHeader()
 	if err != nil {
 		return nil, err
@@ -116,145 +175,172 @@ func (q *queryer) readQuery(requestedChunks byte) (*QueryResponse, error) {
 	if lastPkt == 0 && curPkt == 0 {
 		// If the header says the body is empty, we should just return now
 		if pktLen == 0 {
-			return &QueryResponse{Version: version, Address: q.c.Address()}, nil
+			qr := &QueryResponse{Version: version, Address: q.c.Address(), RequestedChunks: requestedChunks}
+			return qr, q.discardPadding()
 		}
 
-		return q.readQuerySinglePacket(q.reader, version, requestedChunks, uint32(pktLen))
+		qr, err := q.readQuerySinglePacket(q.reader, version, requestedChunks, uint32(pktLen))
+		if err != nil {
+			return nil, err
+		}
+		return qr, q.discardPadding()
 	}
 
 	return q.readQueryMultiPacket(version, curPkt, lastPkt, requestedChunks, pktLen)
 }
 
-func (q *queryer) readQuerySinglePacket(r *packetReader, version uint16, requestedChunks byte, pktLen uint32) (*QueryResponse, error) {
-	qr := &QueryResponse{Version: version, Address: q.c.Address()}
-
-	l := pktLen
-	if requestedChunks&ServerInfo > 0 {
-		if err := q.readQueryServerInfo(qr, r); err != nil {
-			return nil, err
-		}
-		l -= qr.ServerInfo.ChunkLength + uint32(Uint32.Size())
+// discardPadding discards any bytes of the current packet following its
+// payload, such as the padding added by servers sending fixed-size responses,
+// so they aren't read as the start of the next packet.
+func (q *queryer) discardPadding() error {
+	br, ok := q.reader.Reader.(*bufio.Reader)
+	if !ok {
+		return nil
 	}
+	_, err := br.Discard(br.Buffered())
+	return err
+}
 
-	if requestedChunks&ServerRules > 0 {
-		if err := q.readQueryServerRules(qr, r); err != nil {
-			return nil, err
-		}
-		l -= qr.ServerRules.ChunkLength + uint32(Uint32.Size())
-	}
+// chunkReader reads a chunk, of at most max bytes, into qr from r and returns
+// its length. A zero length indicates the server omitted the chunk.
+type chunkReader func(qr *QueryResponse, r *packetReader, max int64) (uint32, error)
 
-	if requestedChunks&PlayerInfo > 0 {
-		if err := q.readQueryPlayerInfo(qr, r); err != nil {
-			return nil, err
+func (q *queryer) readQuerySinglePacket(r *packetReader, version uint16, requestedChunks byte, pktLen uint32) (*QueryResponse, error) {
+	qr := &QueryResponse{Version: version, Address: q.c.Address(), RequestedChunks: requestedChunks}
+
+	// Chunks are always sent in this order.
+	readers := []struct {
+		chunk byte
+		read  chunkReader
+	}{
+		{chunk: ServerInfo, read: q.readQueryServerInfo},
+		{chunk: ServerRules, read: q.readQueryServerRules},
+		{chunk: PlayerInfo, read: q.readQueryPlayerInfo},
+		{chunk: TeamInfo, read: q.readQueryTeamInfo},
+	}
+
+	l := int64(pktLen)
+	for _, cr := range readers {
+		if requestedChunks&cr.chunk == 0 {
+			continue
+		} else if l <= 0 {
+			// The server didn't send this or any of the subsequent chunks.
+			break
 		}
-		l -= qr.PlayerInfo.ChunkLength + uint32(Uint32.Size())
-	}
 
-	if requestedChunks&TeamInfo > 0 {
-		if err := q.readQueryTeamInfo(qr, r); err != nil {
+		n, err := cr.read(qr, r, l-int64(Uint32.Size()))
+		if err != nil {
 			return nil, err
+		} else if n > 0 {
+			qr.Chunks |= cr.chunk
 		}
-		l -= qr.TeamInfo.ChunkLength + uint32(Uint32.Size())
+		l -= int64(n) + int64(Uint32.Size())
 	}
 
-	if l > 0 {
+	if l < 0 {
+		return nil, NewErrMalformedPacketf("expected packet length of %v, but chunks exceed it by %v bytes", pktLen, -l)
+	} else if l > 0 {
 		// If we have extra bytes remaining, we assume they are new fields from a future
 		// query version and discard them.
-		if _, err := io.CopyN(ioutil.Discard, r, int64(l)); err != nil {
+		if _, err := io.CopyN(ioutil.Discard, r, l); err != nil {
 			return nil, err
 		}
 	}
 
+	if q.littleLengths {
+		qr.Warnings = append(qr.Warnings, WarningLittleEndianLengths)
+	}
+
 	return qr, nil
 }
 
-func (q *queryer) readQueryServerInfo(qr *QueryResponse, r *packetReader) (err error) {
-	qr.ServerInfo = &ServerInfoChunk{}
-
-	if qr.ServerInfo.ChunkLength, err = r.ReadUint32(); err != nil {
-		return err
+func (q *queryer) readQueryServerInfo(qr *QueryResponse, r *packetReader, max int64) (uint32, error) {
+	chunkLen, err := q.readChunkLength(r, max)
+	if err != nil || chunkLen == 0 {
+		return 0, err
 	}
+	qr.ServerInfo = &ServerInfoChunk{ChunkLength: chunkLen}
 
 	l := int64(qr.ServerInfo.ChunkLength)
 	if qr.ServerInfo.CurrentPlayers, err = r.ReadUint16(); err != nil {
-		return err
+		return 0, err
 	}
 	l -= int64(Uint16.Size())
 
 	if qr.ServerInfo.MaxPlayers, err = r.ReadUint16(); err != nil {
-		return err
+		return 0, err
 	}
 	l -= int64(Uint16.Size())
 
 	var n int64
 	if n, qr.ServerInfo.ServerName, err = r.ReadString(); err != nil {
-		return err
+		return 0, err
 	}
 	l -= n
 
 	if n, qr.ServerInfo.GameType, err = r.ReadString(); err != nil {
-		return err
+		return 0, err
 	}
 	l -= n
 
 	if n, qr.ServerInfo.BuildID, err = r.ReadString(); err != nil {
-		return err
+		return 0, err
 	}
 	l -= n
 
 	if n, qr.ServerInfo.Map, err = r.ReadString(); err != nil {
-		return err
+		return 0, err
 	}
 	l -= n
 
 	if qr.ServerInfo.Port, err = r.ReadUint16(); err != nil {
-		return err
+		return 0, err
 	}
 	l -= int64(Uint16.Size())
 
 	if l < 0 {
 		// If we have read more bytes than expected, the packet is malformed
-		return NewErrMalformedPacketf("expected chunk length of %v, but have %v bytes remaining", qr.ServerInfo.ChunkLength, l)
+		return 0, NewErrMalformedPacketf("expected chunk length of %v, but have %v bytes remaining", qr.ServerInfo.ChunkLength, l)
 	} else if l > 0 {
 		// If we have extra bytes remaining, we assume they are new fields from a future
 		// query version and discard them
 		if _, err := io.CopyN(ioutil.Discard, r, l); err != nil {
-			return err
+			return 0, err
 		}
 	}
 
-	return nil
+	return chunkLen, nil
 }
 
-func (q *queryer) readQueryServerRules(qr *QueryResponse, r *packetReader) (err error) {
-	qr.ServerRules = &ServerRulesChunk{Rules: make(map[string]*DynamicValue)}
-
-	if qr.ServerRules.ChunkLength, err = r.ReadUint32(); err != nil {
-		return err
+func (q *queryer) readQueryServerRules(qr *QueryResponse, r *packetReader, max int64) (uint32, error) {
+	chunkLen, err := q.readChunkLength(r, max)
+	if err != nil || chunkLen == 0 {
+		return 0, err
 	}
+	qr.ServerRules = &ServerRulesChunk{ChunkLength: chunkLen, Rules: make(map[string]*DynamicValue)}
 
 	l := int64(qr.ServerRules.ChunkLength)
 
 	for l > 0 {
 		n, name, err := r.ReadString()
 		if err != nil {
-			return err
+			return 0, err
 		}
 		l -= n
 
 		n, qr.ServerRules.Rules[name], err = NewDynamicValue(r)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		l -= n
 	}
 
 	if l < 0 {
 		// If we have read more bytes than expected, the packet is malformed
-		return NewErrMalformedPacketf("expected chunk length of %v, but have %v bytes remaining", qr.ServerRules.ChunkLength, l)
+		return 0, NewErrMalformedPacketf("expected chunk length of %v, but have %v bytes remaining", qr.ServerRules.ChunkLength, l)
 	}
 
-	return nil
+	return chunkLen, nil
 }
 
 func (q *queryer) readInfoHeader(r *packetReader) (int64, []*infoHeader, error) {
@@ -298,33 +384,33 @@ func (q *queryer) readInfoHeader(r *packetReader) (int64, []*infoHeader, error)
 	return n, header, nil
 }
 
-func (q *queryer) readQueryPlayerInfo(qr *QueryResponse, r *packetReader) (err error) {
-	qr.PlayerInfo = &PlayerInfoChunk{}
-
-	if qr.PlayerInfo.ChunkLength, err = r.ReadUint32(); err != nil {
-		return err
+func (q *queryer) readQueryPlayerInfo(qr *QueryResponse, r *packetReader, max int64) (uint32, error) {
+	chunkLen, err := q.readChunkLength(r, max)
+	if err != nil || chunkLen == 0 {
+		return 0, err
 	}
+	qr.PlayerInfo = &PlayerInfoChunk{ChunkLength: chunkLen}
 
 	l := int64(qr.PlayerInfo.ChunkLength)
 	expectedPlayerCount, err := r.ReadUint16()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	l -= int64(Uint16.Size())
 
 	// If there are no players, just skip the whole chunk
 	if expectedPlayerCount == 0 {
 		if _, err = io.CopyN(ioutil.Discard, r, l); err != nil {
-			return err
+			return 0, err
 		}
 
-		return nil
+		return chunkLen, nil
 	}
 
 	// Read the player fields header
 	n, header, err := q.readInfoHeader(r)
 	if err != nil {
-		return nil
+		return 0, err
 	}
 	l -= n
 
@@ -335,7 +421,7 @@ func (q *queryer) readQueryPlayerInfo(qr *QueryResponse, r *packetReader) (err e
 		for _, ih := range header {
 			n, qr.PlayerInfo.Players[i][ih.Name], err = NewDynamicValueWithType(r, ih.Type)
 			if err != nil {
-				return err
+				return 0, err
 			}
 			l -= n
 		}
@@ -345,47 +431,47 @@ func (q *queryer) readQueryPlayerInfo(qr *QueryResponse, r *packetReader) (err e
 	switch {
 	case l < 0:
 		// If we have read more bytes than expected, the packet is malformed
-		return NewErrMalformedPacketf("expected chunk length of %v, but have %v bytes remaining", qr.PlayerInfo.ChunkLength, l)
+		return 0, NewErrMalformedPacketf("expected chunk length of %v, but have %v bytes remaining", qr.PlayerInfo.ChunkLength, l)
 	case l > 0:
 		// If we have extra bytes remaining, we assume they are new fields from a future
 		// query version and discard them
 		if _, err := io.CopyN(ioutil.Discard, r, l); err != nil {
-			return err
+			return 0, err
 		}
 	case expectedPlayerCount != 0:
-		return NewErrMalformedPacketf("expected %v player records, but got %v", len(qr.PlayerInfo.Players)+int(expectedPlayerCount), len(qr.PlayerInfo.Players))
+		return 0, NewErrMalformedPacketf("expected %v player records, but got %v", len(qr.PlayerInfo.Players)+int(expectedPlayerCount), len(qr.PlayerInfo.Players))
 	}
 
-	return nil
+	return chunkLen, nil
 }
 
-func (q *queryer) readQueryTeamInfo(qr *QueryResponse, r *packetReader) (err error) {
-	qr.TeamInfo = &TeamInfoChunk{}
-
-	if qr.TeamInfo.ChunkLength, err = r.ReadUint32(); err != nil {
-		return err
+func (q *queryer) readQueryTeamInfo(qr *QueryResponse, r *packetReader, max int64) (uint32, error) {
+	chunkLen, err := q.readChunkLength(r, max)
+	if err != nil || chunkLen == 0 {
+		return 0, err
 	}
+	qr.TeamInfo = &TeamInfoChunk{ChunkLength: chunkLen}
 
 	l := int64(qr.TeamInfo.ChunkLength)
 	expectedTeamCount, err := r.ReadUint16()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	l -= int64(Uint16.Size())
 
 	// If there are no teams, just skip the whole chunk
 	if expectedTeamCount == 0 {
 		if _, err = io.CopyN(ioutil.Discard, r, l); err != nil {
-			return err
+			return 0, err
 		}
 
-		return nil
+		return chunkLen, nil
 	}
 
 	// Read the team fields header
 	n, header, err := q.readInfoHeader(r)
 	if err != nil {
-		return nil
+		return 0, err
 	}
 	l -= n
 
@@ -396,7 +482,7 @@ func (q *queryer) readQueryTeamInfo(qr *QueryResponse, r *packetReader) (err err
 		for _, ih := range header {
 			n, qr.TeamInfo.Teams[i][ih.Name], err = NewDynamicValueWithType(r, ih.Type)
 			if err != nil {
-				return err
+				return 0, err
 			}
 			l -= n
 		}
@@ -406,18 +492,18 @@ func (q *queryer) readQueryTeamInfo(qr *QueryResponse, r *packetReader) (err err
 	switch {
 	case l < 0:
 		// If we have read more bytes than expected, the packet is malformed
-		return NewErrMalformedPacketf("expected chunk length of %v, but have %v bytes remaining", qr.TeamInfo.ChunkLength, l)
+		return 0, NewErrMalformedPacketf("expected chunk length of %v, but have %v bytes remaining", qr.TeamInfo.ChunkLength, l)
 	case l > 0:
 		// If we have extra bytes remaining, we assume they are new fields from a future
 		// query version and discard them
 		if _, err := io.CopyN(ioutil.Discard, r, l); err != nil {
-			return err
+			return 0, err
 		}
 	case expectedTeamCount != 0:
-		return NewErrMalformedPacketf("expected %v Team records, but got %v", len(qr.TeamInfo.Teams)+int(expectedTeamCount), len(qr.TeamInfo.Teams))
+		return 0, NewErrMalformedPacketf("expected %v Team records, but got %v", len(qr.TeamInfo.Teams)+int(expectedTeamCount), len(qr.TeamInfo.Teams))
 	}
 
-	return nil
+	return chunkLen, nil
 }
 
 func (q *queryer) readQueryMultiPacket(version uint16, curPkt, lastPkt, requestedChunks byte, pktLen uint16) (*QueryResponse, error) {
@@ -433,11 +519,13 @@ func (q *queryer) readQueryMultiPacket(version uint16, curPkt, lastPkt, requeste
 		return nil, err
 	} else if uint16(n) != pktLen {
 		return nil, NewErrMalformedPacketf("expected packet length of %v, but read %v bytes", pktLen, n)
+	} else if err = q.discardPadding(); err != nil {
+		return nil, err
 	}
 
-	// Remember the challengeID so that we can verify each packet we are reading is
+	// Remember the challenge so that we can verify each packet we are reading is
 	// part of this multi-packet response
-	challengeID := q.challengeID
+	challengeID, _ := q.challenge.Cookie()
 
 	// Handle each subsequent packet until we have all of the ones we need
 	for len(multiPkt) != int(expectedPkts) {
@@ -447,9 +535,11 @@ func (q *queryer) readQueryMultiPacket(version uint16, curPkt, lastPkt, requeste
 		}
 
 		// If this packet isn't part of the multi-packet response we are expecting, discard it
-		if q.challengeID != challengeID {
+		if id, _ := q.challenge.Cookie(); id != challengeID {
 			if _, err := io.CopyN(ioutil.Discard, q.reader, int64(pktLen)); err != nil {
 				return nil, err
+			} else if err = q.discardPadding(); err != nil {
+				return nil, err
 			}
 			continue
 		}
@@ -461,6 +551,8 @@ func (q *queryer) readQueryMultiPacket(version uint16, curPkt, lastPkt, requeste
 			return nil, err
 		} else if uint16(n) != pktLen {
 			return nil, NewErrMalformedPacketf("expected packet length of %v, but read %v bytes", pktLen, n)
+		} else if err = q.discardPadding(); err != nil {
+			return nil, err
 		}
 	}
 
@@ -108,7 +108,8 @@ func testQueryInfoSinglePacket(t *testing.T, challengeID uint32, c *queryer) {
 	require.NoError(t, err, "query request failed")
 
 	qr := r.(*QueryResponse)
-	require.Equal(t, challengeID, c.challengeID, "expected correct challenge id")
+	id, _ := c.challenge.Cookie()
+	require.Equal(t, challengeID, id, "expected correct challenge id")
 
 	require.NotNil(t, qr, "expected query response")
 	require.NotNil(t, qr.ServerInfo, "expected server info")
@@ -120,6 +121,7 @@ func testQueryInfoSinglePacket(t *testing.T, challengeID uint32, c *queryer) {
 	require.Equal(t, "1", qr.ServerInfo.BuildID)
 	require.Equal(t, "map", qr.ServerInfo.Map)
 	require.Equal(t, uint16(1025), qr.ServerInfo.Port)
+	require.Equal(t, "1", qr.BuildID())
 }
 
 func testQueryServerInfoSinglePacketMalformed(t *testing.T, challengeID uint32, c *queryer) {
@@ -135,7 +137,8 @@ func testQueryServerInfoMultiPacket(t *testing.T, challengeID uint32, c *queryer
 	require.NoError(t, err, "query request should not have failed")
 	qr := r.(*QueryResponse)
 
-	require.Equal(t, challengeID, c.challengeID, "expected correct challenge id")
+	id, _ := c.challenge.Cookie()
+	require.Equal(t, challengeID, id, "expected correct challenge id")
 
 	require.NotNil(t, qr, "expected query response")
 	require.NotNil(t, qr.ServerInfo, "expected server info")
@@ -154,7 +157,8 @@ func testQueryServerRulesSinglePacket(t *testing.T, challengeID uint32, c *query
 	require.NoError(t, err, "query request should not have failed")
 	qr := r.(*QueryResponse)
 
-	require.Equal(t, challengeID, c.challengeID, "expected correct challenge id")
+	id, _ := c.challenge.Cookie()
+	require.Equal(t, challengeID, id, "expected correct challenge id")
 
 	require.NotNil(t, qr, "expected query response")
 	require.NotNil(t, qr.ServerRules, "expected server rules")
@@ -172,7 +176,8 @@ func testQueryPlayerInfoSinglePacket(t *testing.T, challengeID uint32, c *querye
 	require.NoError(t, err, "query request should not have failed")
 	qr := r.(*QueryResponse)
 
-	require.Equal(t, challengeID, c.challengeID, "expected correct challenge id")
+	id, _ := c.challenge.Cookie()
+	require.Equal(t, challengeID, id, "expected correct challenge id")
 
 	require.NotNil(t, qr, "expected query response")
 	require.NotNil(t, qr.PlayerInfo, "expected player info")
@@ -198,7 +203,8 @@ func testQueryTeamInfoSinglePacket(t *testing.T, challengeID uint32, c *queryer)
 	require.NoError(t, err, "query request should not have failed")
 	qr := r.(*QueryResponse)
 
-	require.Equal(t, challengeID, c.challengeID, "expected correct challenge id")
+	id, _ := c.challenge.Cookie()
+	require.Equal(t, challengeID, id, "expected correct challenge id")
 
 	require.NotNil(t, qr, "expected query response")
 	require.NotNil(t, qr.TeamInfo, "expected Team info")
@@ -218,3 +224,61 @@ func testQueryTeamInfoSinglePacket(t *testing.T, challengeID uint32, c *queryer)
 	require.Equal(t, uint64(72057594037927938), qr.TeamInfo.Teams[1]["field4"].Uint64())
 	require.Equal(t, "STRING", qr.TeamInfo.Teams[1]["field5"].String())
 }
+
+func TestQueryChunks(t *testing.T) {
+	infoResp := clienttest.LoadData(t, testDir, "info_single_response")
+	cases := []struct {
+		name      string
+		requested byte
+		resp      []byte
+		chunks    byte
+		ignored   byte
+	}{
+		{
+			name:      "honored",
+			requested: ServerInfo,
+			resp:      infoResp,
+			chunks:    ServerInfo,
+		},
+		{
+			name:      "trailing-chunks-ignored",
+			requested: ServerInfo | ServerRules | TeamInfo,
+			resp:      infoResp,
+			chunks:    ServerInfo,
+			ignored:   ServerRules | TeamInfo,
+		},
+		{
+			name:      "empty-chunk",
+			requested: ServerInfo,
+			resp:      []byte{QueryResponseType, 0, 0, 0, 0, 0, 1, 0, 0, 0, 4, 0, 0, 0, 0},
+			ignored:   ServerInfo,
+		},
+		{
+			name:      "empty-payload",
+			requested: ServerInfo,
+			resp:      []byte{QueryResponseType, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0},
+			ignored:   ServerInfo,
+		},
+	}
+
+	chalResp := []byte{ChallengeResponseType, 0, 0, 0, 1}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, c := newClient(tc.requested)
+			m.On("Write", mock.AnythingOfType("[]uint8")).Return(0, nil)
+			m.On("Read", mock.AnythingOfType("[]uint8")).Return(chalResp, nil).Once()
+			resp := append([]byte(nil), tc.resp...)
+			testSetChallenge(resp, chalResp)
+			m.On("Read", mock.AnythingOfType("[]uint8")).Return(resp, nil).Once()
+
+			r, err := c.Query()
+			require.NoError(t, err)
+			qr := r.(*QueryResponse)
+			require.Equal(t, Version, qr.Version)
+			require.Equal(t, tc.requested, qr.RequestedChunks)
+			require.Equal(t, tc.chunks, qr.Chunks)
+			require.Equal(t, tc.ignored, qr.IgnoredChunks())
+			require.Equal(t, tc.chunks&ServerInfo != 0, qr.ServerInfo != nil)
+		})
+	}
+}
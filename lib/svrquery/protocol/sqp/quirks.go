@@ -0,0 +1,82 @@
+package sqp
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+// LengthOrderArg is the name of the client argument which sets the byte order
+// of packet and chunk lengths, one of "auto" (default), "big" or "little".
+// Some engine forks send little-endian lengths, which "auto" detects from
+// big-endian lengths which exceed the data available.
+const LengthOrderArg = "length_order"
+
+// WarningLittleEndianLengths is the warning of responses which were decoded
+// using little-endian lengths.
+const WarningLittleEndianLengths = "server sent little-endian lengths"
+
+// lengthOrder is the byte order of packet and chunk lengths.
+type lengthOrder byte
+
+const (
+	lengthsAuto lengthOrder = iota
+	lengthsBig
+	lengthsLittle
+)
+
+var lengthOrders = map[string]lengthOrder{
+	"auto":   lengthsAuto,
+	"big":    lengthsBig,
+	"little": lengthsLittle,
+}
+
+// requestedLengthOrder returns the lengthOrder requested by the args of c.
+func requestedLengthOrder(c protocol.Client) (lengthOrder, error) {
+	switch v := protocol.Args(c)[LengthOrderArg].(type) {
+	case nil:
+		return lengthsAuto, nil
+	case string:
+		if lo, ok := lengthOrders[v]; ok {
+			return lo, nil
+		}
+		return 0, fmt.Errorf("unknown %s %q", LengthOrderArg, v)
+	default:
+		return 0, fmt.Errorf("invalid %s arg type %T", LengthOrderArg, v)
+	}
+}
+
+// packetLength returns the packet length v, detecting little-endian lengths
+// from lengths which exceed the max packet size.
+func (q *queryer) packetLength(v uint16) uint16 {
+	swapped := bits.ReverseBytes16(v)
+	switch {
+	case q.littleLengths:
+		return swapped
+	case q.lengthOrder == lengthsAuto && int(v) > q.maxPktSize && int(swapped) <= q.maxPktSize:
+		q.littleLengths = true
+		return swapped
+	}
+	return v
+}
+
+// readChunkLength reads a chunk length from r, which has max bytes of the
+// chunk remaining, detecting little-endian lengths from lengths which exceed
+// max.
+func (q *queryer) readChunkLength(r *packetReader, max int64) (uint32, error) {
+	v, err := r.ReadUint32()
+	if err != nil {
+		return 0, err
+	}
+
+	swapped := bits.ReverseBytes32(v)
+	switch {
+	case q.littleLengths:
+		return swapped, nil
+	case q.lengthOrder == lengthsAuto && int64(v) > max && int64(swapped) <= max:
+		q.littleLengths = true
+		return swapped, nil
+	}
+	return v, nil
+}
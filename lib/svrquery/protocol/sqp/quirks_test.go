@@ -0,0 +1,82 @@
+package sqp
+
+import (
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/clienttest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// swap reverses the bytes of b in place.
+func swap(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
+
+func TestQueryLittleEndianLengths(t *testing.T) {
+	infoResp := clienttest.LoadData(t, testDir, "info_single_response")
+	cases := []struct {
+		name     string
+		order    lengthOrder
+		packet   bool
+		chunk    bool
+		warnings []string
+		err      bool
+	}{
+		{name: "big"},
+		{name: "little", packet: true, chunk: true, warnings: []string{WarningLittleEndianLengths}},
+		{name: "little-chunk", chunk: true, warnings: []string{WarningLittleEndianLengths}},
+		{name: "little-forced", order: lengthsLittle, packet: true, chunk: true, warnings: []string{WarningLittleEndianLengths}},
+		{name: "little-disabled", order: lengthsBig, packet: true, chunk: true, err: true},
+	}
+
+	chalResp := []byte{ChallengeResponseType, 0, 0, 0, 1}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := append([]byte(nil), infoResp...)
+			testSetChallenge(resp, chalResp)
+			if tc.packet {
+				swap(resp[9:11])
+			}
+			if tc.chunk {
+				swap(resp[11:15])
+			}
+
+			m, c := newClient(ServerInfo)
+			c.lengthOrder = tc.order
+			m.On("Write", mock.AnythingOfType("[]uint8")).Return(0, nil)
+			m.On("Read", mock.AnythingOfType("[]uint8")).Return(chalResp, nil).Once()
+			m.On("Read", mock.AnythingOfType("[]uint8")).Return(resp, nil).Once()
+			m.On("Read", mock.AnythingOfType("[]uint8")).Return([]byte{}, timeoutErr{})
+
+			r, err := c.Query()
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			qr := r.(*QueryResponse)
+			require.Equal(t, uint16(5), qr.ServerInfo.CurrentPlayers)
+			require.Equal(t, tc.warnings, qr.Warnings)
+		})
+	}
+}
+
+func TestLengthOrderArg(t *testing.T) {
+	for _, v := range []interface{}{"little", "big", "auto"} {
+		m := &clienttest.MockClient{}
+		m.On("Args").Return(map[string]interface{}{LengthOrderArg: v})
+		_, err := newCreator(m)
+		require.NoError(t, err)
+	}
+
+	for _, v := range []interface{}{"middle", 1} {
+		m := &clienttest.MockClient{}
+		m.On("Args").Return(map[string]interface{}{LengthOrderArg: v})
+		_, err := newCreator(m)
+		require.Error(t, err)
+	}
+}
@@ -2,6 +2,16 @@ package sqp
 
 import (
 	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Player record field names used by the svrsample SQP responder.
+const (
+	PlayerName      = "name"
+	PlayerScore     = "score"
+	PlayerTeam      = "team"
+	PlayerConnected = "connected"
 )
 
 // ServerInfoChunk is the response chunk for server info data
@@ -38,6 +48,39 @@ func (pic *PlayerInfoChunk) MarshalJSON() ([]byte, error) {
 	return json.Marshal(pic.Players)
 }
 
+// Player is a player record decoded from the PlayerInfo chunk.
+type Player struct {
+	Name  string `json:"name"`
+	Score int32  `json:"score"`
+	// Team is the index of the team the player is on.
+	Team uint16 `json:"team"`
+	// Connected is how long the player has been connected.
+	Connected time.Duration `json:"connected"`
+}
+
+// Roster returns the players decoded using the PlayerName, PlayerScore,
+// PlayerTeam and PlayerConnected fields. Missing fields, or fields with an
+// unexpected type, are left as the zero value.
+func (pic *PlayerInfoChunk) Roster() []Player {
+	players := make([]Player, len(pic.Players))
+	for i, fields := range pic.Players {
+		p := &players[i]
+		if v := fields[PlayerName]; v != nil && v.Type == String {
+			p.Name = v.String()
+		}
+		if v := fields[PlayerScore]; v != nil && v.Type == Uint32 {
+			p.Score = int32(v.Uint32())
+		}
+		if v := fields[PlayerTeam]; v != nil && v.Type == Uint16 {
+			p.Team = v.Uint16()
+		}
+		if v := fields[PlayerConnected]; v != nil && v.Type == Uint32 {
+			p.Connected = time.Duration(v.Uint32()) * time.Second
+		}
+	}
+	return players
+}
+
 // TeamInfoChunk is the response chunk for team data
 type TeamInfoChunk struct {
 	ChunkLength uint32 `json:"-"`
@@ -51,12 +94,26 @@ func (tic *TeamInfoChunk) MarshalJSON() ([]byte, error) {
 
 // QueryResponse is the combined response to a query request
 type QueryResponse struct {
-	Version     uint16            `json:"version"`
-	Address     string            `json:"address"`
+	// Version is the SQP version the server responded with.
+	Version uint16 `json:"version"`
+	Address string `json:"address"`
+	// RequestedChunks is the mask of chunks which were requested.
+	RequestedChunks byte `json:"requested_chunks"`
+	// Chunks is the mask of chunks which the server returned.
+	Chunks      byte              `json:"chunks"`
 	ServerInfo  *ServerInfoChunk  `json:"server_info,omitempty"`
 	ServerRules *ServerRulesChunk `json:"server_rules,omitempty"`
 	PlayerInfo  *PlayerInfoChunk  `json:"player_info,omitempty"`
 	TeamInfo    *TeamInfoChunk    `json:"team_info,omitempty"`
+	// Warnings are the quirks of the server which were worked around to
+	// decode the response e.g. WarningLittleEndianLengths.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// IgnoredChunks returns the mask of chunks which were requested but not
+// returned by the server.
+func (q *QueryResponse) IgnoredChunks() byte {
+	return q.RequestedChunks &^ q.Chunks
 }
 
 // MaxClients returns the maximum number of clients.
@@ -77,6 +134,49 @@ func (q *QueryResponse) NumClients() int64 {
 	return int64(q.ServerInfo.CurrentPlayers)
 }
 
+// MapName returns the current map.
+func (q *QueryResponse) MapName() string {
+	if q.ServerInfo == nil {
+		return ""
+	}
+	return q.ServerInfo.Map
+}
+
+// BuildID implements protocol.BuildIDer.
+func (q *QueryResponse) BuildID() string {
+	if q.ServerInfo == nil {
+		return ""
+	}
+	return q.ServerInfo.BuildID
+}
+
+// Rules implements protocol.Ruler.
+func (q *QueryResponse) Rules() map[string]string {
+	if q.ServerRules == nil {
+		return nil
+	}
+
+	rules := make(map[string]string, len(q.ServerRules.Rules))
+	for k, v := range q.ServerRules.Rules {
+		rules[k] = fmt.Sprint(v.Value)
+	}
+	return rules
+}
+
+// MapPlayerNames implements protocol.PlayerNamer, replacing the PlayerName
+// field of each player record.
+func (q *QueryResponse) MapPlayerNames(f func(name string) string) {
+	if q.PlayerInfo == nil {
+		return
+	}
+
+	for _, fields := range q.PlayerInfo.Players {
+		if v := fields[PlayerName]; v != nil && v.Type == String {
+			v.Value = f(v.String())
+		}
+	}
+}
+
 type infoHeader struct {
 	Name string
 	Type DataType
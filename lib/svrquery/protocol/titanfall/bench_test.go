@@ -0,0 +1,27 @@
+package titanfall
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/clienttest"
+)
+
+func BenchmarkQuery(b *testing.B) {
+	for _, v := range []byte{3, 7} {
+		b.Run(fmt.Sprintf("v%d", v), func(b *testing.B) {
+			resp := clienttest.LoadData(b, testDir, fmt.Sprintf("response-v%d", v))
+			q := &queryer{
+				c:       &clienttest.ReplayClient{Packets: [][]byte{resp}},
+				version: v,
+			}
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := q.Query(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -12,4 +12,10 @@ const (
 
 	// ServerInfoVersionKeyed is the version of keys info packets.
 	ServerInfoVersionKeyed = byte(5)
+
+	// ServerInfoVersionMax is the highest info packet version supported.
+	ServerInfoVersionMax = byte(8)
+
+	// ServerInfoVersionMin is the lowest info packet version tried during negotiation.
+	ServerInfoVersionMin = byte(3)
 )
@@ -1,6 +1,7 @@
 package titanfall
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 
@@ -15,12 +16,16 @@ var (
 	minLength = 26
 )
 
+// requestSize is the size of a request packet, which is padded with zeros.
+const requestSize = 1200
+
 type queryer struct {
-	c       protocol.Client
-	version byte
+	c         protocol.Client
+	version   byte
+	negotiate bool
 }
 
-func newQueryer(version byte) func(c protocol.Client) protocol.Queryer {
+func newQueryer(version byte) protocol.Creator {
 	return func(c protocol.Client) protocol.Queryer {
 		return &queryer{
 			c:       c,
@@ -29,13 +34,62 @@ func newQueryer(version byte) func(c protocol.Client) protocol.Queryer {
 	}
 }
 
+// newAutoQueryer returns a queryer which negotiates the highest version
+// supported by the server.
+func newAutoQueryer(c protocol.Client) protocol.Queryer {
+	return &queryer{
+		c:         c,
+		version:   ServerInfoVersionMax,
+		negotiate: true,
+	}
+}
+
 // Query implements protocol.Queryer.
 func (q *queryer) Query() (protocol.Responser, error) {
-	b := make([]byte, 1200)
-	copy(b, q.serverInfoPkt())
+	return q.QueryContext(protocol.NewQueryContext(context.Background(), 0, nil))
+}
+
+// QueryContext implements protocol.ContextQueryer.
+func (q *queryer) QueryContext(qc *protocol.QueryContext) (protocol.Responser, error) {
+	if qc.MaxPacketSize < requestSize {
+		return nil, fmt.Errorf("max packet size %d less than request size %d", qc.MaxPacketSize, requestSize)
+	}
+
+	if !q.negotiate {
+		return q.query(qc, q.version)
+	}
+
+	// Servers don't respond to versions they don't support so step down
+	// until we get a valid response.
+	var err error
+	for v := q.version; v >= ServerInfoVersionMin; v-- {
+		if err = qc.Err(); err != nil {
+			return nil, err
+		}
+
+		var i *Info
+		if i, err = q.query(qc, v); err == nil {
+			// Use the version the server responded with from now on.
+			q.version = i.Version
+			q.negotiate = false
+			return i, nil
+		}
+		qc.Logger.Printf("tf2e: version %d failed: %v", v, err)
+	}
+
+	return nil, err
+}
+
+// query sends an info request using version and decodes the response.
+func (q *queryer) query(qc *protocol.QueryContext, version byte) (*Info, error) {
+	b := qc.Buf[:requestSize]
+	for i := range b {
+		b[i] = 0
+	}
+	copy(b, q.serverInfoPkt(version))
 
 	if key := q.c.Key(); key != "" {
-		if q.version < 5 {
+		if version < 5 {
 			// If keyed data asked for bump version sent to supported version level.
 			b[5] = ServerInfoVersionKeyed
 		}
@@ -46,14 +100,14 @@ func (q *queryer) Query() (protocol.Responser, error) {
 		return nil, err
 	}
 
-	n, err := q.c.Read(b)
+	n, err := q.c.Read(qc.Buf)
 	if err != nil {
 		return nil, err
 	} else if n < minLength {
 		return nil, fmt.Errorf("packet too short (len: %d)", n)
 	}
 
-	r := common.NewBinaryReader(b[:n], binary.LittleEndian)
+	r := common.NewBinaryReader(qc.Buf[:n], binary.LittleEndian)
 	i := &Info{}
 
 	// Header.
@@ -258,6 +312,6 @@ func (q *queryer) Charts(serverID int64) module.Charts {
 }
 
 // serverInfoPkt returns a byte array of info request packet data.
-func (q *queryer) serverInfoPkt() []byte {
-	return []byte{0xFF, 0xFF, 0xFF, 0xFF, ServerInfoRequest, q.version}
+func (q *queryer) serverInfoPkt(version byte) []byte {
+	return []byte{0xFF, 0xFF, 0xFF, 0xFF, ServerInfoRequest, version}
 }
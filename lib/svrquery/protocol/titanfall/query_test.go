@@ -1,6 +1,7 @@
 package titanfall
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/multiplay/go-svrquery/lib/svrquery/clienttest"
@@ -136,3 +137,47 @@ func TestQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryAuto(t *testing.T) {
+	reqV7 := clienttest.LoadData(t, testDir, "request-v7")
+	reqV8 := append([]byte(nil), reqV7...)
+	reqV8[5] = ServerInfoVersionMax
+	resp := clienttest.LoadData(t, testDir, "response-v7")
+
+	m := &clienttest.MockClient{}
+	m.On("Key").Return("")
+
+	// Server doesn't respond to v8 so we step down to v7.
+	m.On("Write", reqV8).Return(len(reqV8), nil).Once()
+	m.On("Read", mock.AnythingOfType("[]uint8")).Return([]byte{}, errors.New("i/o timeout")).Once()
+	m.On("Write", reqV7).Return(len(reqV7), nil).Twice()
+	m.On("Read", mock.AnythingOfType("[]uint8")).Return(resp, nil).Twice()
+
+	p := newAutoQueryer(m)
+
+	for j := 0; j < 2; j++ {
+		r, err := p.Query()
+		require.NoError(t, err)
+		i := r.(*Info)
+		require.Equal(t, byte(7), i.Version)
+		require.True(t, i.HasPlatformPlayers())
+		require.False(t, i.HasHealthFlags())
+	}
+	m.AssertExpectations(t)
+}
+
+func TestQueryAutoFailure(t *testing.T) {
+	m := &clienttest.MockClient{}
+	m.On("Key").Return("")
+	m.On("Write", mock.AnythingOfType("[]uint8")).Return(0, nil)
+	m.On("Read", mock.AnythingOfType("[]uint8")).Return([]byte{}, errors.New("i/o timeout"))
+
+	p := newAutoQueryer(m)
+
+	_, err := p.Query()
+	require.Error(t, err)
+	m.AssertNumberOfCalls(t, "Write", int(ServerInfoVersionMax-ServerInfoVersionMin+1))
+
+	// Negotiation is retried by the next query.
+	require.True(t, p.(*queryer).negotiate)
+}
@@ -28,6 +28,21 @@ type Info struct {
 	Clients []Client
 }
 
+// HasPerformanceInfo returns true if the response contains PerformanceInfo.
+func (i Info) HasPerformanceInfo() bool {
+	return i.Version > 4
+}
+
+// HasPlatformPlayers returns true if the response contains BasicInfo.PlatformPlayers.
+func (i Info) HasPlatformPlayers() bool {
+	return i.Version > 6
+}
+
+// HasHealthFlags returns true if the response contains InstanceInfoV8.HealthFlags.
+func (i Info) HasHealthFlags() bool {
+	return i.Version > 7
+}
+
 // NumClients implements protocol.Responser.
 func (i Info) NumClients() int64 {
 	return int64(i.BasicInfo.NumClients)
@@ -38,6 +53,42 @@ func (i Info) MaxClients() int64 {
 	return int64(i.BasicInfo.MaxClients)
 }
 
+// MapName implements protocol.Mapper.
+func (i Info) MapName() string {
+	return i.BasicInfo.Map
+}
+
+// BuildID implements protocol.BuildIDer, the build name is only reported by
+// version 8+.
+func (i Info) BuildID() string {
+	return i.BuildName
+}
+
+// Rules implements protocol.Ruler.
+func (i Info) Rules() map[string]string {
+	rules := make(map[string]string)
+	for k, v := range map[string]string{
+		"build_name": i.BuildName,
+		"datacenter": i.Datacenter,
+		"game_mode":  i.GameMode,
+		"platform":   i.Platform,
+		"playlist":   i.PlaylistName,
+		"map":        i.Map,
+	} {
+		if v != "" {
+			rules[k] = v
+		}
+	}
+	return rules
+}
+
+// MapPlayerNames implements protocol.PlayerNamer.
+func (i *Info) MapPlayerNames(f func(name string) string) {
+	for j := range i.Clients {
+		i.Clients[j].Name = f(i.Clients[j].Name)
+	}
+}
+
 // Header represents the header of a query response.
 type Header struct {
 	Prefix  int32
@@ -2,6 +2,7 @@ package titanfall
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -66,3 +67,9 @@ func TestHealthFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestMapPlayerNames(t *testing.T) {
+	i := &Info{Clients: []Client{{ID: 1, Name: "alice"}, {ID: 2, Name: "bob"}}}
+	i.MapPlayerNames(strings.ToUpper)
+	require.Equal(t, []Client{{ID: 1, Name: "ALICE"}, {ID: 2, Name: "BOB"}}, i.Clients)
+}
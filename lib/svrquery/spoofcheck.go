@@ -0,0 +1,109 @@
+package svrquery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+)
+
+// SpoofCheck is the result of CheckSpoofing.
+type SpoofCheck struct {
+	// LocalAddr is the address the query was sent from.
+	LocalAddr string
+
+	// Response is the response to the query, nil if it failed.
+	Response protocol.Responser
+
+	// Err is the error of the query, if any.
+	Err error
+
+	// Stats are the network statistics of the query, which only include
+	// packets received from the server.
+	Stats QueryStats
+
+	// Strays are the source addresses of packets received which weren't from
+	// the server, such as responses translated or spoofed on their way back.
+	Strays []string
+}
+
+// OK returns true if the query succeeded and every packet received was from
+// the server.
+func (s *SpoofCheck) OK() bool {
+	return s.Err == nil && len(s.Strays) == 0
+}
+
+// CheckSpoofing queries the server at addr using proto from localAddr, which
+// is typically an address of a secondary interface, and reports whether the
+// responses return to localAddr from the address of the server. It's intended
+// to validate query routing and anti-spoofing configuration in lab
+// environments.
+//
+// The socket is bound to localAddr, so only packets routed back to it are
+// received, and unlike a normal client packets from other sources are
+// recorded as strays rather than silently discarded. The host of localAddr
+// must be specified, the port may be 0.
+func CheckSpoofing(proto, addr, localAddr string, options ...Option) (*SpoofCheck, error) {
+	la, err := net.ResolveUDPAddr(DefaultNetwork, localAddr)
+	if err != nil {
+		return nil, err
+	} else if la.IP == nil || la.IP.IsUnspecified() {
+		return nil, errors.New("local address must specify a host")
+	}
+
+	ra, err := net.ResolveUDPAddr(DefaultNetwork, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	pc, err := net.ListenUDP(DefaultNetwork, la)
+	if err != nil {
+		return nil, fmt.Errorf("bind %s: %w", localAddr, err)
+	}
+	rc := &recordingConn{PacketConn: pc, addr: ra.String()}
+
+	c, err := NewClient(proto, addr, append(options, WithPacketConn(rc))...)
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+	defer c.Close()
+
+	s := &SpoofCheck{LocalAddr: pc.LocalAddr().String()}
+	s.Response, s.Err = c.Query()
+	s.Stats = c.QueryStats()
+	s.Strays = rc.strays()
+
+	return s, nil
+}
+
+// recordingConn is a net.PacketConn which records the source address of
+// packets received which weren't from addr.
+type recordingConn struct {
+	net.PacketConn
+	addr string
+
+	mtx    sync.Mutex
+	others []string
+}
+
+// ReadFrom implements net.PacketConn.
+func (c *recordingConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if err == nil && addr.String() != c.addr {
+		c.mtx.Lock()
+		c.others = append(c.others, addr.String())
+		c.mtx.Unlock()
+	}
+	return n, addr, err
+}
+
+// strays returns the source addresses recorded.
+func (c *recordingConn) strays() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return append([]string(nil), c.others...)
+}
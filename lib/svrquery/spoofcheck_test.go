@@ -0,0 +1,69 @@
+package svrquery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSpoofing(t *testing.T) {
+	s, err := server.New(
+		server.WithAddress("127.0.0.1:0"),
+		server.WithProtocol("sqp"),
+		server.WithState(common.QueryState{CurrentPlayers: 1, MaxPlayers: 2}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	sc, err := CheckSpoofing("sqp", s.Addr().String(), "127.0.0.1:0")
+	require.NoError(t, err)
+	require.True(t, sc.OK())
+	require.NoError(t, sc.Err)
+	require.Empty(t, sc.Strays)
+	require.Equal(t, int64(1), sc.Response.NumClients())
+	require.Equal(t, 2, sc.Stats.PacketsReceived)
+
+	host, _, err := net.SplitHostPort(sc.LocalAddr)
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", host)
+
+	for _, local := range []string{":0", "0.0.0.0:0", "invalid"} {
+		_, err = CheckSpoofing("sqp", s.Addr().String(), local)
+		require.Error(t, err, local)
+	}
+}
+
+func TestCheckSpoofingStrays(t *testing.T) {
+	// A server whose responses are sent from another address.
+	srv, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer srv.Close()
+
+	other, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer other.Close()
+
+	go func() {
+		buf := make([]byte, 100)
+		for {
+			_, from, err := srv.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = other.WriteTo([]byte("stray"), from)
+		}
+	}()
+
+	sc, err := CheckSpoofing("sqp", srv.LocalAddr().String(), "127.0.0.1:0", WithTimeout(time.Millisecond*200))
+	require.NoError(t, err)
+	require.False(t, sc.OK())
+	require.Error(t, sc.Err)
+	require.Equal(t, []string{other.LocalAddr().String()}, sc.Strays)
+	require.Equal(t, 0, sc.Stats.PacketsReceived)
+}
@@ -0,0 +1,50 @@
+package svrquery
+
+import (
+	"net"
+)
+
+// dialUDP is the default Dialer which returns a connected UDP socket.
+func dialUDP(network, address string) (net.Conn, error) {
+	ua, err := net.ResolveUDPAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return net.DialUDP(network, nil, ua)
+}
+
+// packetConn adapts a net.PacketConn to a net.Conn which only talks to addr.
+type packetConn struct {
+	net.PacketConn
+	addr net.Addr
+}
+
+// newPacketConn returns a net.Conn which sends and receives packets to and from
+// addr using pc.
+func newPacketConn(pc net.PacketConn, addr net.Addr) *packetConn {
+	return &packetConn{PacketConn: pc, addr: addr}
+}
+
+// Read implements net.Conn.
+func (c *packetConn) Read(b []byte) (int, error) {
+	for {
+		n, addr, err := c.ReadFrom(b)
+		if err != nil {
+			return 0, err
+		} else if addr.String() == c.addr.String() { // We use String as IP's can be different byte but the same value.
+			return n, nil
+		}
+		// Packet from unexpected source just ignore.
+	}
+}
+
+// Write implements net.Conn.
+func (c *packetConn) Write(b []byte) (int, error) {
+	return c.WriteTo(b, c.addr)
+}
+
+// RemoteAddr implements net.Conn.
+func (c *packetConn) RemoteAddr() net.Addr {
+	return c.addr
+}
@@ -0,0 +1,198 @@
+// Package announce periodically registers a server with a heartbeat endpoint,
+// such as that of the watch command, so the discovery path can be tested end
+// to end with the sample server.
+package announce
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/heartbeat"
+)
+
+const (
+	// DefaultInterval is the default interval between announcements, a third
+	// of the default heartbeat ttl so a lost datagram doesn't expire the
+	// server.
+	DefaultInterval = heartbeat.DefaultTTL / 3
+
+	// DefaultTimeout is the default timeout of each announcement.
+	DefaultTimeout = time.Second * 5
+)
+
+// Option represents an Announcer option.
+type Option func(*Announcer) error
+
+// WithInterval sets the interval between announcements, defaulting to
+// DefaultInterval.
+func WithInterval(interval time.Duration) Option {
+	return func(a *Announcer) error {
+		if interval <= 0 {
+			return errors.New("interval must be positive")
+		}
+		a.interval = interval
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of each announcement, defaulting to
+// DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(a *Announcer) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		a.timeout = timeout
+		return nil
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to post announcements.
+func WithHTTPClient(c *http.Client) Option {
+	return func(a *Announcer) error {
+		a.client = c
+		return nil
+	}
+}
+
+// WithLogger sets the logger used to report failed announcements.
+func WithLogger(l *log.Logger) Option {
+	return func(a *Announcer) error {
+		a.logger = l
+		return nil
+	}
+}
+
+// Announcer periodically sends an announcement to a heartbeat endpoint.
+type Announcer struct {
+	endpoint *url.URL
+	payload  []byte
+	interval time.Duration
+	timeout  time.Duration
+	client   *http.Client
+	logger   *log.Logger
+}
+
+// New returns a new Announcer which sends a to endpoint, configured with
+// options. The endpoint is either udp://host:port, which sends each
+// announcement as a datagram, or an http or https URL it's posted to.
+func New(endpoint string, a heartbeat.Announcement, options ...Option) (*Announcer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "udp":
+		if u.Port() == "" {
+			return nil, fmt.Errorf("endpoint %q: missing port", endpoint)
+		}
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("endpoint %q: unsupported scheme %q", endpoint, u.Scheme)
+	}
+
+	payload, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+
+	an := &Announcer{
+		endpoint: u,
+		payload:  payload,
+		interval: DefaultInterval,
+		timeout:  DefaultTimeout,
+		client:   http.DefaultClient,
+		logger:   log.New(ioutil.Discard, "", 0),
+	}
+	for _, o := range options {
+		if err := o(an); err != nil {
+			return nil, err
+		}
+	}
+
+	return an, nil
+}
+
+// Run announces immediately and then at every interval until ctx is done,
+// returning ctx.Err(). Failed announcements are logged and retried at the
+// next interval, so the server registers once the endpoint is available.
+func (a *Announcer) Run(ctx context.Context) error {
+	t := time.NewTicker(a.interval)
+	defer t.Stop()
+
+	for {
+		if err := a.Announce(ctx); err != nil && ctx.Err() == nil {
+			a.logger.Printf("announce to %s: %v", a.endpoint.Host, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Announce sends the announcement once.
+func (a *Announcer) Announce(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	if a.endpoint.Scheme == "udp" {
+		return a.announceUDP(ctx)
+	}
+	return a.announceHTTP(ctx)
+}
+
+// announceUDP sends the announcement as a datagram. The endpoint is resolved
+// for each announcement so changes to its address are followed.
+func (a *Announcer) announceUDP(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", a.endpoint.Host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetWriteDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	_, err = conn.Write(a.payload)
+	return err
+}
+
+// announceHTTP posts the announcement.
+func (a *Announcer) announceHTTP(ctx context.Context) error {
+	req, err := http.NewRequest(http.MethodPost, a.endpoint.String(), bytes.NewReader(a.payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package announce
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/heartbeat"
+	"github.com/stretchr/testify/require"
+)
+
+var testAnnouncement = heartbeat.Announcement{
+	Name:     "eu-1",
+	Address:  ":12121",
+	Protocol: "sqp",
+	Token:    "secret",
+}
+
+func TestNew(t *testing.T) {
+	for _, endpoint := range []string{"tcp://127.0.0.1:9080", "udp://127.0.0.1", "::"} {
+		_, err := New(endpoint, testAnnouncement)
+		require.Error(t, err, endpoint)
+	}
+
+	_, err := New("udp://127.0.0.1:9080", testAnnouncement, WithInterval(0))
+	require.Error(t, err)
+
+	_, err = New("udp://127.0.0.1:9080", testAnnouncement, WithTimeout(0))
+	require.Error(t, err)
+
+	a, err := New("http://127.0.0.1:9080/", testAnnouncement)
+	require.NoError(t, err)
+	require.Equal(t, DefaultInterval, a.interval)
+}
+
+func TestAnnounceUDP(t *testing.T) {
+	r, err := heartbeat.New(heartbeat.WithTokens("secret"))
+	require.NoError(t, err)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- r.ServePacket(ctx, conn, nil) }()
+
+	a, err := New("udp://"+conn.LocalAddr().String(), testAnnouncement, WithInterval(time.Millisecond*10))
+	require.NoError(t, err)
+	go a.Run(ctx)
+
+	require.Eventually(t, func() bool { return r.Len() == 1 }, time.Second, time.Millisecond*10)
+	targets := r.Targets()
+	require.Equal(t, "eu-1", targets[0].Name)
+	require.Equal(t, "127.0.0.1:12121", targets[0].Address)
+
+	cancel()
+	require.Equal(t, context.Canceled, <-done)
+}
+
+func TestAnnounceHTTP(t *testing.T) {
+	r, err := heartbeat.New(heartbeat.WithTokens("secret"))
+	require.NoError(t, err)
+	s := httptest.NewServer(r.Handler(nil))
+	defer s.Close()
+
+	a, err := New(s.URL, testAnnouncement)
+	require.NoError(t, err)
+	require.NoError(t, a.Announce(context.Background()))
+	require.Equal(t, 1, r.Len())
+
+	// Rejected announcements report the reason.
+	bad := testAnnouncement
+	bad.Token = "wrong"
+	a, err = New(s.URL, bad)
+	require.NoError(t, err)
+	err = a.Announce(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid token")
+}
+
+func TestRun(t *testing.T) {
+	// Announcing to a server which isn't listening logs failures until ctx
+	// is done.
+	a, err := New("http://127.0.0.1:1/", testAnnouncement, WithInterval(time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, a.Run(ctx))
+}
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"net"
+	"strings"
+)
+
+// NormalizeAddress returns the canonical form of the host:port address addr, so
+// equivalent representations of the same IPv4 or IPv6 address are equal.
+// IPv4-mapped IPv6 addresses are converted to their IPv4 form.
+// If addr isn't a valid IP host:port address it's returned unmodified.
+func NormalizeAddress(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	var zone string
+	if i := strings.LastIndexByte(host, '%'); i != -1 {
+		host, zone = host[:i], host[i:]
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return addr
+	} else if zone == "" && strings.IndexByte(host, ':') == -1 {
+		// IPv4 addresses only have one representation so avoid allocating.
+		return addr
+	}
+
+	return net.JoinHostPort(ip.String()+zone, port)
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	cases := []struct {
+		addr     string
+		expected string
+	}{
+		{addr: "127.0.0.1:1000", expected: "127.0.0.1:1000"},
+		{addr: "[::ffff:127.0.0.1]:1000", expected: "127.0.0.1:1000"},
+		{addr: "[2001:DB8:0:0::1]:1000", expected: "[2001:db8::1]:1000"},
+		{addr: "[fe80::0001%eth0]:1000", expected: "[fe80::1%eth0]:1000"},
+		{addr: "client-addr:65534", expected: "client-addr:65534"},
+		{addr: "invalid", expected: "invalid"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.addr, func(t *testing.T) {
+			require.Equal(t, tc.expected, NormalizeAddress(tc.addr))
+		})
+	}
+}
+
+func TestNormalizeAddressAllocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		NormalizeAddress("127.0.0.1:1000")
+	})
+	require.Zero(t, allocs)
+}
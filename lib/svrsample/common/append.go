@@ -0,0 +1,44 @@
+package common
+
+import (
+	"math"
+)
+
+// The Append functions encode values in network byte order by appending them
+// to a byte slice, allowing responders to marshal responses into reused
+// buffers without the allocations of WireWrite.
+
+// AppendUint16 appends v to b.
+func AppendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// AppendUint32 appends v to b.
+func AppendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// AppendUint64 appends v to b.
+func AppendUint64(b []byte, v uint64) []byte {
+	return append(b,
+		byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+		byte(v>>24), byte(v>>16), byte(v>>8), byte(v),
+	)
+}
+
+// AppendString appends s to b prefixed by its length.
+// Strings longer than 255 bytes return ErrStringTooLong.
+func AppendString(b []byte, s string) ([]byte, error) {
+	if len(s) > math.MaxUint8 {
+		return b, ErrStringTooLong
+	}
+
+	return append(append(b, byte(len(s))), s...), nil
+}
+
+// PutUint32 writes v to b at offset i, which must already have been reserved
+// e.g. for a length prefix which is only known once the data has been appended.
+func PutUint32(b []byte, i int, v uint32) {
+	_ = b[i+3]
+	b[i], b[i+1], b[i+2], b[i+3] = byte(v>>24), byte(v>>16), byte(v>>8), byte(v)
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppend(t *testing.T) {
+	b := AppendUint16(nil, 0x0102)
+	b = AppendUint32(b, 0x03040506)
+	b = AppendUint64(b, 0x0708090a0b0c0d0e)
+	b, err := AppendString(b, "ab")
+	require.NoError(t, err)
+
+	// Must match the reflection based encoder.
+	expected := &bytes.Buffer{}
+	require.NoError(t, WireWrite(expected, &Encoder{}, struct {
+		A uint16
+		B uint32
+		C uint64
+		D string
+	}{0x0102, 0x03040506, 0x0708090a0b0c0d0e, "ab"}))
+	require.Equal(t, expected.Bytes(), b)
+
+	_, err = AppendString(nil, strings.Repeat("a", 256))
+	require.Equal(t, ErrStringTooLong, err)
+
+	b = make([]byte, 5)
+	PutUint32(b, 1, 0x01020304)
+	require.Equal(t, []byte{0, 1, 2, 3, 4}, b)
+}
+
+func BenchmarkWireWrite(b *testing.B) {
+	v := struct {
+		A uint16
+		B string
+	}{1, "benchmark"}
+	buf := &bytes.Buffer{}
+	enc := &Encoder{}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := WireWrite(buf, enc, v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppend(b *testing.B) {
+	buf := make([]byte, 0, 64)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = AppendUint16(buf[:0], 1)
+		var err error
+		if buf, err = AppendString(buf, "benchmark"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// coalescedCall is an in-flight or completed Coalescer call.
+type coalescedCall struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+// Coalescer coalesces concurrent calls with the same key into a single call
+// whose result is shared by all of the callers. This allows responders to
+// encode a response once for a burst of identical queries. It's safe for
+// concurrent use.
+type Coalescer struct {
+	// shared is first to ensure 64-bit alignment for atomic access.
+	shared uint64
+	mtx    sync.Mutex
+	calls  map[interface{}]*coalescedCall
+}
+
+// Do returns the result of calling fn, unless a call for key is already in
+// flight, in which case it waits for and returns the result of that call
+// instead. The returned data may be shared so must not be modified.
+func (c *Coalescer) Do(key interface{}, fn func() ([]byte, error)) ([]byte, error) {
+	c.mtx.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mtx.Unlock()
+		atomic.AddUint64(&c.shared, 1)
+		call.wg.Wait()
+		return call.data, call.err
+	}
+
+	if c.calls == nil {
+		c.calls = make(map[interface{}]*coalescedCall)
+	}
+	call := &coalescedCall{}
+	call.wg.Add(1)
+	c.calls[key] = call
+	c.mtx.Unlock()
+
+	defer func() {
+		c.mtx.Lock()
+		delete(c.calls, key)
+		c.mtx.Unlock()
+		call.wg.Done()
+	}()
+
+	call.data, call.err = fn()
+	return call.data, call.err
+}
+
+// Shared returns the number of calls which were answered with the result of
+// another in-flight call.
+func (c *Coalescer) Shared() uint64 {
+	return atomic.LoadUint64(&c.shared)
+}
@@ -0,0 +1,70 @@
+package common
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalescer(t *testing.T) {
+	var c Coalescer
+	var calls int
+	release := make(chan struct{})
+	fn := func() ([]byte, error) {
+		calls++
+		<-release
+		return []byte("payload"), nil
+	}
+
+	const n = 5
+	var wg sync.WaitGroup
+	results := make([][]byte, n)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		data, err := c.Do(1, fn)
+		require.NoError(t, err)
+		results[0] = data
+	}()
+
+	// Wait for the first call to be in flight before starting the others.
+	for {
+		c.mtx.Lock()
+		started := len(c.calls) == 1
+		c.mtx.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, err := c.Do(1, fn)
+			require.NoError(t, err)
+			results[i] = data
+		}(i)
+	}
+
+	for c.Shared() != n-1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, 1, calls)
+	for _, r := range results {
+		require.Equal(t, []byte("payload"), r)
+	}
+
+	// Completed calls aren't reused.
+	errTest := errors.New("test")
+	_, err := c.Do(1, func() ([]byte, error) { return nil, errTest })
+	require.Equal(t, errTest, err)
+	require.Equal(t, uint64(n-1), c.Shared())
+}
@@ -1,11 +1,35 @@
 package common
 
+import (
+	"context"
+	"time"
+)
+
 // QueryResponder represents an interface to a concrete type which responds
 // to query requests.
 type QueryResponder interface {
 	Respond(clientAddress string, buf []byte) ([]byte, error)
 }
 
+// PacketResponder represents an interface to a concrete type which responds
+// to query requests with responses which may span multiple packets.
+type PacketResponder interface {
+	RespondPackets(clientAddress string, buf []byte) ([][]byte, error)
+}
+
+// ContextResponder represents an interface to a concrete type which responds
+// to query requests, bounding the construction of the response by ctx.
+type ContextResponder interface {
+	RespondPacketsContext(ctx context.Context, clientAddress string, buf []byte) ([][]byte, error)
+}
+
+// LocalResponder represents an interface to a concrete type which responds
+// to query requests using the local address the request was received on,
+// allowing a single responder to answer on behalf of multiple servers.
+type LocalResponder interface {
+	RespondLocal(ctx context.Context, localAddress, clientAddress string, buf []byte) ([][]byte, error)
+}
+
 // QueryState represents the state of a currently running game.
 type QueryState struct {
 	CurrentPlayers int32
@@ -14,4 +38,65 @@ type QueryState struct {
 	GameType       string
 	Map            string
 	Port           uint16
+	Rules          Rules
+	Players        []Player
+}
+
+// Player represents a player connected to the server.
+type Player struct {
+	Name  string
+	Score int32
+	// Team is the index of the team the player is on.
+	Team uint16
+	// Connected is how long the player has been connected.
+	Connected time.Duration
+}
+
+// Rules is a set of named server rules. Supported value types are string,
+// bool and all integer and float types. Each responder encodes the rules in
+// its native rules format.
+type Rules map[string]interface{}
+
+// StateProvider represents a source of the QueryState which responders
+// respond to queries with, allowing embedders to supply live data from their
+// game loop. Snapshot is called for each query so must be safe for
+// concurrent use.
+type StateProvider interface {
+	Snapshot() QueryState
 }
+
+// VersionedStateProvider is a StateProvider which reports a version which
+// changes whenever its state changes, allowing responders to cache encoded
+// responses until it does.
+type VersionedStateProvider interface {
+	StateProvider
+	Version() uint64
+}
+
+// StaticState is a VersionedStateProvider which always returns the same
+// QueryState.
+type StaticState QueryState
+
+// Snapshot implements StateProvider.
+func (s StaticState) Snapshot() QueryState {
+	return QueryState(s)
+}
+
+// Version implements VersionedStateProvider, the state never changes.
+func (s StaticState) Version() uint64 {
+	return 0
+}
+
+// StateFunc is a function which returns the current QueryState, allowing
+// responders to pull live state from a host application.
+type StateFunc func() QueryState
+
+// Snapshot implements StateProvider by calling f.
+func (f StateFunc) Snapshot() QueryState {
+	return f()
+}
+
+// StateContextFunc is a function which returns the current QueryState, or an
+// error if it couldn't be obtained before ctx is done. This allows slow state
+// sources, such as a snapshot from a game thread, to be bounded.
+type StateContextFunc func(ctx context.Context) (QueryState, error)
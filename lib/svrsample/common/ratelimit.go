@@ -0,0 +1,91 @@
+package common
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// bucket is the token bucket of a client host.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter limits the rate of requests from each client host using a token
+// bucket. Hosts whose bucket has refilled are expired so memory use is bounded
+// by the number of hosts recently seen. It's safe for concurrent use.
+type RateLimiter struct {
+	rate      float64
+	burst     float64
+	mtx       sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter returns a RateLimiter which allows rate requests per second
+// from each client host with bursts of up to burst requests.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow returns true if a request from clientAddress is within the rate limit,
+// consuming a token if it is. Requests are limited by the host of the address
+// so a client can't avoid the limit by changing its source port.
+func (l *RateLimiter) Allow(clientAddress string, now time.Time) bool {
+	host, _, err := net.SplitHostPort(clientAddress)
+	if err != nil {
+		host = clientAddress
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[host]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[host] = b
+	} else if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep removes the buckets which have refilled since they were last used,
+// l.mtx must be held.
+func (l *RateLimiter) sweep(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastSweep) <= refill {
+		return
+	}
+
+	for host, b := range l.buckets {
+		if now.Sub(b.last) > refill {
+			delete(l.buckets, host)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Len returns the number of client hosts being tracked.
+func (l *RateLimiter) Len() int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return len(l.buckets)
+}
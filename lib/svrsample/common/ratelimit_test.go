@@ -0,0 +1,28 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(2, 2)
+	now := time.Now()
+
+	require.True(t, l.Allow("10.0.0.1:1000", now))
+	require.True(t, l.Allow("10.0.0.1:1001", now))
+	// Changing the source port doesn't bypass the limit.
+	require.False(t, l.Allow("10.0.0.1:1002", now))
+	require.True(t, l.Allow("10.0.0.2:1000", now))
+
+	// Tokens are refilled at the rate.
+	require.True(t, l.Allow("10.0.0.1:1000", now.Add(time.Millisecond*500)))
+	require.False(t, l.Allow("10.0.0.1:1000", now.Add(time.Millisecond*500)))
+	require.Equal(t, 2, l.Len())
+
+	// Refilled buckets are expired.
+	require.True(t, l.Allow("10.0.0.3:1000", now.Add(time.Second*5)))
+	require.Equal(t, 1, l.Len())
+}
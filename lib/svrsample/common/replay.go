@@ -0,0 +1,150 @@
+package common
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrReplay is returned by responders when a request is a replay of a
+	// recently answered request.
+	ErrReplay = errors.New("replayed request")
+
+	// ErrBlocked is returned by responders when requests from a client are
+	// blocked.
+	ErrBlocked = errors.New("client blocked")
+)
+
+// RejectReason is the reason a responder rejected a request.
+type RejectReason string
+
+const (
+	// RejectReplay indicates the request was a replay of a recent request.
+	RejectReplay RejectReason = "replay"
+
+	// RejectForged indicates the request had a missing or invalid challenge.
+	RejectForged RejectReason = "forged"
+
+	// RejectMalformed indicates the request couldn't be decoded.
+	RejectMalformed RejectReason = "malformed"
+
+	// RejectBlocked indicates the client was blocked.
+	RejectBlocked RejectReason = "blocked"
+)
+
+// RejectHook is called when a responder rejects a request from clientAddress,
+// allowing a host server to log or block abusive sources.
+type RejectHook func(clientAddress string, reason RejectReason)
+
+// BlockFunc returns true if requests from clientAddress should be rejected
+// without being processed.
+type BlockFunc func(clientAddress string) bool
+
+// RequestStats are the number of requests handled by a responder by outcome.
+type RequestStats struct {
+	Accepted  uint64
+	Replayed  uint64
+	Forged    uint64
+	Malformed uint64
+	Blocked   uint64
+}
+
+// RequestCounters counts requests by outcome. It's safe for concurrent use.
+type RequestCounters struct {
+	accepted  uint64
+	replayed  uint64
+	forged    uint64
+	malformed uint64
+	blocked   uint64
+}
+
+// Accept increments the accepted count.
+func (c *RequestCounters) Accept() {
+	atomic.AddUint64(&c.accepted, 1)
+}
+
+// Reject increments the count for reason.
+func (c *RequestCounters) Reject(reason RejectReason) {
+	switch reason {
+	case RejectReplay:
+		atomic.AddUint64(&c.replayed, 1)
+	case RejectForged:
+		atomic.AddUint64(&c.forged, 1)
+	case RejectMalformed:
+		atomic.AddUint64(&c.malformed, 1)
+	case RejectBlocked:
+		atomic.AddUint64(&c.blocked, 1)
+	}
+}
+
+// Stats returns the current counts.
+func (c *RequestCounters) Stats() RequestStats {
+	return RequestStats{
+		Accepted:  atomic.LoadUint64(&c.accepted),
+		Replayed:  atomic.LoadUint64(&c.replayed),
+		Forged:    atomic.LoadUint64(&c.forged),
+		Malformed: atomic.LoadUint64(&c.malformed),
+		Blocked:   atomic.LoadUint64(&c.blocked),
+	}
+}
+
+// replayEntry is the last request answered for a client.
+type replayEntry struct {
+	req  string
+	seen time.Time
+}
+
+// ReplayTracker tracks the last request answered for each client so replays
+// within a window can be detected. Entries older than the window are expired
+// so memory use is bounded by the number of clients seen within the window.
+// It's safe for concurrent use.
+type ReplayTracker struct {
+	window    time.Duration
+	mtx       sync.Mutex
+	entries   map[string]replayEntry
+	lastSweep time.Time
+}
+
+// NewReplayTracker returns a ReplayTracker which detects replays within window.
+func NewReplayTracker(window time.Duration) *ReplayTracker {
+	return &ReplayTracker{
+		window:  window,
+		entries: make(map[string]replayEntry),
+	}
+}
+
+// Seen returns true if req is the last request recorded for clientAddress
+// within the window.
+func (t *ReplayTracker) Seen(clientAddress string, req []byte, now time.Time) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	e, ok := t.entries[clientAddress]
+	return ok && now.Sub(e.seen) <= t.window && e.req == string(req)
+}
+
+// Record records req as the last request answered for clientAddress.
+func (t *ReplayTracker) Record(clientAddress string, req []byte, now time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.entries[clientAddress] = replayEntry{req: string(req), seen: now}
+	if now.Sub(t.lastSweep) > t.window {
+		for k, e := range t.entries {
+			if now.Sub(e.seen) > t.window {
+				delete(t.entries, k)
+			}
+		}
+		t.lastSweep = now
+	}
+}
+
+// Len returns the number of clients being tracked.
+func (t *ReplayTracker) Len() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return len(t.entries)
+}
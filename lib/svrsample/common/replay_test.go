@@ -0,0 +1,46 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayTracker(t *testing.T) {
+	rt := NewReplayTracker(time.Second)
+	now := time.Now()
+	req := []byte{1, 2, 3}
+
+	require.False(t, rt.Seen("a", req, now))
+	rt.Record("a", req, now)
+	require.True(t, rt.Seen("a", req, now.Add(time.Second)))
+	require.False(t, rt.Seen("a", []byte{1, 2, 4}, now))
+	require.False(t, rt.Seen("b", req, now))
+
+	// Outside the window.
+	require.False(t, rt.Seen("a", req, now.Add(time.Second*2)))
+
+	// Expired entries are removed.
+	rt.Record("b", req, now.Add(time.Second*3))
+	require.Equal(t, 1, rt.Len())
+}
+
+func TestRequestCounters(t *testing.T) {
+	var c RequestCounters
+	c.Accept()
+	c.Accept()
+	c.Reject(RejectReplay)
+	c.Reject(RejectForged)
+	c.Reject(RejectMalformed)
+	c.Reject(RejectBlocked)
+	c.Reject(RejectBlocked)
+
+	require.Equal(t, RequestStats{
+		Accepted:  2,
+		Replayed:  1,
+		Forged:    1,
+		Malformed: 1,
+		Blocked:   2,
+	}, c.Stats())
+}
@@ -0,0 +1,22 @@
+package common
+
+import (
+	"context"
+)
+
+// RespondPackets returns the response packets from r for the query buf from
+// clientAddress, using the most capable interface r implements.
+func RespondPackets(ctx context.Context, r QueryResponder, clientAddress string, buf []byte) ([][]byte, error) {
+	switch r := r.(type) {
+	case ContextResponder:
+		return r.RespondPacketsContext(ctx, clientAddress, buf)
+	case PacketResponder:
+		return r.RespondPackets(clientAddress, buf)
+	}
+
+	resp, err := r.Respond(clientAddress, buf)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{resp}, nil
+}
@@ -0,0 +1,40 @@
+package common
+
+const (
+	// IPv4UDPOverhead is the size in bytes of the IPv4 and UDP headers of a packet.
+	IPv4UDPOverhead = 20 + 8
+
+	// IPv6UDPOverhead is the size in bytes of the IPv6 and UDP headers of a packet.
+	IPv6UDPOverhead = 40 + 8
+)
+
+// SizeReport describes the encoded size of a query response.
+type SizeReport struct {
+	// Protocol is the protocol used to encode the response.
+	Protocol string
+
+	// Header is the size in bytes of the packet header.
+	Header int
+
+	// Chunks is the size in bytes of each encoded chunk keyed by name.
+	Chunks map[string]int
+}
+
+// Total returns the total size in bytes of the encoded response.
+func (r SizeReport) Total() int {
+	n := r.Header
+	for _, c := range r.Chunks {
+		n += c
+	}
+	return n
+}
+
+// Fits returns true if the response fits in a single IPv4 packet for the given mtu.
+func (r SizeReport) Fits(mtu int) bool {
+	return r.Total()+IPv4UDPOverhead <= mtu
+}
+
+// FitsIPv6 returns true if the response fits in a single IPv6 packet for the given mtu.
+func (r SizeReport) FitsIPv6(mtu int) bool {
+	return r.Total()+IPv6UDPOverhead <= mtu
+}
@@ -0,0 +1,89 @@
+package common
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// AtomicState is a VersionedStateProvider which a host application publishes complete
+// states to, typically once per game tick. Each published state is immutable
+// and swapped atomically, so a response never mixes fields from two different
+// states. Snapshot never blocks and is safe for concurrent use with Store and
+// Update.
+type AtomicState struct {
+	v   atomic.Value
+	mtx sync.Mutex
+}
+
+// versionedState is a state published to an AtomicState.
+type versionedState struct {
+	state   QueryState
+	version uint64
+}
+
+// NewAtomicState returns a new AtomicState which initially provides state.
+func NewAtomicState(state QueryState) *AtomicState {
+	s := &AtomicState{}
+	s.Store(state)
+	return s
+}
+
+// Snapshot implements StateProvider. The returned state is shared with other
+// readers so its rules and players must not be modified.
+func (s *AtomicState) Snapshot() QueryState {
+	if vs, ok := s.v.Load().(*versionedState); ok {
+		return vs.state
+	}
+	return QueryState{}
+}
+
+// Version implements VersionedStateProvider, the version is incremented each
+// time a state is published.
+func (s *AtomicState) Version() uint64 {
+	if vs, ok := s.v.Load().(*versionedState); ok {
+		return vs.version
+	}
+	return 0
+}
+
+// Store publishes state. Its rules and players are copied so the caller may
+// continue to modify them without affecting the published state.
+func (s *AtomicState) Store(state QueryState) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.store(state)
+}
+
+// Update publishes the result of applying f to a copy of the current state.
+// Concurrent updates are serialised so none are lost.
+func (s *AtomicState) Update(f func(state *QueryState)) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	st := s.Snapshot().clone()
+	f(&st)
+	s.store(st)
+}
+
+// store publishes a copy of state, s.mtx must be held.
+func (s *AtomicState) store(state QueryState) {
+	s.v.Store(&versionedState{state: state.clone(), version: s.Version() + 1})
+}
+
+// clone returns a copy of qs which shares no memory with it.
+func (qs QueryState) clone() QueryState {
+	if qs.Rules != nil {
+		rules := make(Rules, len(qs.Rules))
+		for k, v := range qs.Rules {
+			rules[k] = v
+		}
+		qs.Rules = rules
+	}
+
+	if qs.Players != nil {
+		qs.Players = append([]Player(nil), qs.Players...)
+	}
+
+	return qs
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAtomicState(t *testing.T) {
+	var s AtomicState
+	require.Equal(t, QueryState{}, s.Snapshot())
+
+	rules := Rules{"mode": "ctf"}
+	players := []Player{{Name: "a"}}
+	s.Store(QueryState{Map: "first", Rules: rules, Players: players})
+
+	// Modifying the stored values must not affect the published state.
+	rules["mode"] = "dm"
+	players[0].Name = "b"
+	st := s.Snapshot()
+	require.Equal(t, "first", st.Map)
+	require.Equal(t, "ctf", st.Rules["mode"])
+	require.Equal(t, "a", st.Players[0].Name)
+
+	s.Update(func(st *QueryState) {
+		st.Map = "second"
+		st.Rules["round"] = 2
+	})
+	require.Equal(t, "second", s.Snapshot().Map)
+	require.Len(t, s.Snapshot().Rules, 2)
+	require.Len(t, st.Rules, 1)
+}
+
+func TestAtomicStateConsistent(t *testing.T) {
+	s := NewAtomicState(QueryState{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := int32(1); i <= 1000; i++ {
+			s.Update(func(st *QueryState) {
+				st.CurrentPlayers = i
+				st.MaxPlayers = i
+			})
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		st := s.Snapshot()
+		require.Equal(t, st.CurrentPlayers, st.MaxPlayers)
+	}
+	wg.Wait()
+	require.Equal(t, int32(1000), s.Snapshot().CurrentPlayers)
+}
@@ -3,9 +3,14 @@ package common
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"math"
 	"reflect"
 )
 
+// ErrStringTooLong is returned when a string is too long to be encoded.
+var ErrStringTooLong = errors.New("string too long")
+
 // WireEncoder is an interface which allows for different query implementations
 // to write data to a byte buffer in a specific format.
 type WireEncoder interface {
@@ -17,7 +22,12 @@ type WireEncoder interface {
 type Encoder struct{}
 
 // WriteString writes a string to the provided buffer.
+// Strings longer than 255 bytes return ErrStringTooLong.
 func (e *Encoder) WriteString(resp *bytes.Buffer, s string) error {
+	if len(s) > math.MaxUint8 {
+		return ErrStringTooLong
+	}
+
 	if err := binary.Write(resp, binary.BigEndian, byte(len(s))); err != nil {
 		return err
 	}
@@ -0,0 +1,183 @@
+// Package fake provides generators of realistic looking server states for
+// demos and tests.
+package fake
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+)
+
+var (
+	// DefaultMaps is the default pool of map names.
+	DefaultMaps = []string{
+		"Harbour", "Dustbowl", "Frostbite", "Canyon Run", "Old Town", "Reactor",
+		"Skyline", "Quarry", "Lighthouse", "Eclipse", "Foundry", "Glacier Pass",
+	}
+
+	// DefaultGameTypes is the default pool of game types.
+	DefaultGameTypes = []string{
+		"Capture the Flag", "Deathmatch", "Team Deathmatch", "King of the Hill", "Domination", "Search and Destroy",
+	}
+
+	// DefaultRegions is the default pool of regions used by name templates.
+	DefaultRegions = []string{"EU West", "EU North", "US East", "US West", "Asia", "Oceania", "Brazil"}
+
+	// DefaultNameTemplates is the default pool of server name templates.
+	// Templates are executed with Name.
+	DefaultNameTemplates = []string{
+		"{{.Region}} {{.GameType}} #{{.Number}}",
+		"[{{.Region}}] {{.Map}} 24/7",
+		"Official {{.GameType}} Server {{.Number}}",
+		"{{.Region}} Casual | {{.Map}}",
+	}
+
+	// DefaultPlayerNames is the default pool of player names.
+	DefaultPlayerNames = []string{
+		"Sparrow", "n00bslayer", "CaptainCrunch", "xX_Viper_Xx", "Moonwalker", "Tankbuster", "PixelPirate",
+		"GhostRider", "Bandit", "LuckyShot", "Nightowl", "Frosty", "Rook", "Maverick", "Zephyr", "Blitz",
+	}
+
+	// ErrEmptyPool is returned by options if a pool is empty.
+	ErrEmptyPool = errors.New("empty pool")
+)
+
+// Name is the data a server name template is executed with.
+type Name struct {
+	Region   string
+	GameType string
+	Map      string
+	Number   int
+}
+
+// Generator generates random server states. It's safe for concurrent use.
+type Generator struct {
+	maps        []string
+	gameTypes   []string
+	regions     []string
+	templates   []*template.Template
+	playerNames []string
+	maxPlayers  int32
+
+	mtx sync.Mutex
+	rnd *rand.Rand
+}
+
+// New returns a new Generator configured by options.
+func New(options ...Option) (*Generator, error) {
+	g := &Generator{
+		maps:        DefaultMaps,
+		gameTypes:   DefaultGameTypes,
+		regions:     DefaultRegions,
+		playerNames: DefaultPlayerNames,
+		maxPlayers:  16,
+		rnd:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	if err := WithNameTemplates(DefaultNameTemplates...)(g); err != nil {
+		return nil, err
+	}
+
+	for _, o := range options {
+		if err := o(g); err != nil {
+			return nil, err
+		}
+	}
+
+	return g, nil
+}
+
+// pick returns a random element of pool, g.mtx must be held.
+func (g *Generator) pick(pool []string) string {
+	return pool[g.rnd.Intn(len(pool))]
+}
+
+// Map returns a random map name.
+func (g *Generator) Map() string {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	return g.pick(g.maps)
+}
+
+// ServerName returns a random server name for gameType and mapName.
+func (g *Generator) ServerName(gameType, mapName string) string {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	return g.serverName(gameType, mapName)
+}
+
+// serverName returns a random server name, g.mtx must be held.
+func (g *Generator) serverName(gameType, mapName string) string {
+	t := g.templates[g.rnd.Intn(len(g.templates))]
+	n := Name{
+		Region:   g.pick(g.regions),
+		GameType: gameType,
+		Map:      mapName,
+		Number:   g.rnd.Intn(99) + 1,
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, n); err != nil {
+		// Templates are validated by WithNameTemplates so this
+		// shouldn't happen.
+		return gameType
+	}
+	return buf.String()
+}
+
+// Players returns n players with random names, scores, teams and connection
+// times. Names are unique while the pool has enough names, after which they
+// are suffixed with a number.
+func (g *Generator) Players(n int) []common.Player {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	return g.players(n)
+}
+
+// players returns n random players, g.mtx must be held.
+func (g *Generator) players(n int) []common.Player {
+	players := make([]common.Player, n)
+	perm := g.rnd.Perm(len(g.playerNames))
+	for i := range players {
+		name := g.playerNames[perm[i%len(perm)]]
+		if i >= len(perm) {
+			name += strconv.Itoa(i / len(perm))
+		}
+
+		players[i] = common.Player{
+			Name:      name,
+			Score:     int32(g.rnd.Intn(50)),
+			Team:      uint16(i % 2),
+			Connected: time.Duration(g.rnd.Intn(3600)) * time.Second,
+		}
+	}
+	return players
+}
+
+// State returns a random state with a random number of players.
+func (g *Generator) State() common.QueryState {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	gameType := g.pick(g.gameTypes)
+	mapName := g.pick(g.maps)
+	players := g.players(g.rnd.Intn(int(g.maxPlayers) + 1))
+
+	return common.QueryState{
+		CurrentPlayers: int32(len(players)),
+		MaxPlayers:     g.maxPlayers,
+		ServerName:     g.serverName(gameType, mapName),
+		GameType:       gameType,
+		Map:            mapName,
+		Players:        players,
+	}
+}
@@ -0,0 +1,58 @@
+package fake
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator(t *testing.T) {
+	g, err := New(
+		WithSeed(1),
+		WithMaps("Harbour"),
+		WithGameTypes("CTF"),
+		WithRegions("EU"),
+		WithNameTemplates("{{.Region}} {{.GameType}} on {{.Map}}"),
+		WithPlayerNames("a", "b"),
+		WithMaxPlayers(4),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		s := g.State()
+		require.Equal(t, "EU CTF on Harbour", s.ServerName)
+		require.Equal(t, "Harbour", s.Map)
+		require.Equal(t, int32(4), s.MaxPlayers)
+		require.True(t, s.CurrentPlayers <= s.MaxPlayers)
+		require.Len(t, s.Players, int(s.CurrentPlayers))
+	}
+
+	players := g.Players(5)
+	names := make(map[string]bool)
+	for _, p := range players {
+		names[p.Name] = true
+	}
+	require.Len(t, names, 5, "names must be unique")
+
+	// The same seed generates the same states.
+	g1, err := New(WithSeed(42))
+	require.NoError(t, err)
+	g2, err := New(WithSeed(42))
+	require.NoError(t, err)
+	require.Equal(t, g1.State(), g2.State())
+}
+
+func TestGeneratorOptions(t *testing.T) {
+	_, err := New(WithMaps())
+	require.True(t, errors.Is(err, ErrEmptyPool))
+
+	_, err = New(WithNameTemplates("{{.Unclosed"))
+	require.Error(t, err)
+
+	_, err = New(WithNameTemplates("{{.Missing}}"))
+	require.Error(t, err)
+
+	_, err = New(WithMaxPlayers(-1))
+	require.Error(t, err)
+}
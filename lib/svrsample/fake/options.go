@@ -0,0 +1,99 @@
+package fake
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"text/template"
+)
+
+// Option represents a Generator option.
+type Option func(*Generator) error
+
+// WithSeed seeds the generator so the same states are generated each run.
+func WithSeed(seed int64) Option {
+	return func(g *Generator) error {
+		g.rnd = rand.New(rand.NewSource(seed))
+		return nil
+	}
+}
+
+// WithMaps sets the pool of map names.
+func WithMaps(maps ...string) Option {
+	return func(g *Generator) error {
+		if len(maps) == 0 {
+			return fmt.Errorf("maps: %w", ErrEmptyPool)
+		}
+		g.maps = maps
+		return nil
+	}
+}
+
+// WithGameTypes sets the pool of game types.
+func WithGameTypes(gameTypes ...string) Option {
+	return func(g *Generator) error {
+		if len(gameTypes) == 0 {
+			return fmt.Errorf("game types: %w", ErrEmptyPool)
+		}
+		g.gameTypes = gameTypes
+		return nil
+	}
+}
+
+// WithRegions sets the pool of regions used by name templates.
+func WithRegions(regions ...string) Option {
+	return func(g *Generator) error {
+		if len(regions) == 0 {
+			return fmt.Errorf("regions: %w", ErrEmptyPool)
+		}
+		g.regions = regions
+		return nil
+	}
+}
+
+// WithNameTemplates sets the pool of server name templates. Each is a Go
+// text/template which is executed with Name e.g. "{{.Region}} #{{.Number}}".
+func WithNameTemplates(templates ...string) Option {
+	return func(g *Generator) error {
+		if len(templates) == 0 {
+			return fmt.Errorf("name templates: %w", ErrEmptyPool)
+		}
+
+		g.templates = make([]*template.Template, len(templates))
+		for i, text := range templates {
+			t, err := template.New("name").Parse(text)
+			if err != nil {
+				return fmt.Errorf("name template %q: %w", text, err)
+			}
+
+			// Check the template only references fields of Name.
+			if err = t.Execute(ioutil.Discard, Name{}); err != nil {
+				return fmt.Errorf("name template %q: %w", text, err)
+			}
+			g.templates[i] = t
+		}
+		return nil
+	}
+}
+
+// WithPlayerNames sets the pool of player names.
+func WithPlayerNames(names ...string) Option {
+	return func(g *Generator) error {
+		if len(names) == 0 {
+			return fmt.Errorf("player names: %w", ErrEmptyPool)
+		}
+		g.playerNames = names
+		return nil
+	}
+}
+
+// WithMaxPlayers sets the max players of generated states.
+func WithMaxPlayers(n int32) Option {
+	return func(g *Generator) error {
+		if n < 0 {
+			return fmt.Errorf("max players %d must not be negative", n)
+		}
+		g.maxPlayers = n
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+// Package multi provides a responder which answers queries on behalf of
+// multiple logical servers, allowing a host agent to answer queries for all
+// the game server processes on a machine.
+package multi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+)
+
+var (
+	// ErrNoRoute is returned by NewResponder if the route function is nil.
+	ErrNoRoute = errors.New("no route function")
+
+	// ErrUnknownInstance is returned if a query is routed to an instance
+	// which hasn't been added.
+	ErrUnknownInstance = errors.New("unknown instance")
+)
+
+// RouteFunc returns the key of the instance which should answer the query buf
+// received on localAddress from clientAddress, and the query to pass to it.
+// This allows instance ids embedded in the query to be stripped.
+type RouteFunc func(localAddress, clientAddress string, buf []byte) (key string, query []byte, err error)
+
+// PortRoute is a RouteFunc which routes queries to the instance keyed by the
+// port they were received on.
+func PortRoute(localAddress, clientAddress string, buf []byte) (string, []byte, error) {
+	_, port, err := net.SplitHostPort(localAddress)
+	if err != nil {
+		return "", nil, err
+	}
+	return port, buf, nil
+}
+
+// Responder answers queries using the responder of the instance selected by
+// its route function. It's safe for concurrent use.
+type Responder struct {
+	route     RouteFunc
+	mtx       sync.RWMutex
+	instances map[string]common.QueryResponder
+}
+
+// NewResponder returns a new Responder which routes queries using route.
+func NewResponder(route RouteFunc) (*Responder, error) {
+	if route == nil {
+		return nil, ErrNoRoute
+	}
+
+	return &Responder{
+		route:     route,
+		instances: make(map[string]common.QueryResponder),
+	}, nil
+}
+
+// Add adds or replaces the responder for the instance key.
+func (r *Responder) Add(key string, qr common.QueryResponder) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.instances[key] = qr
+}
+
+// Remove removes the instance key.
+func (r *Responder) Remove(key string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	delete(r.instances, key)
+}
+
+// Keys returns the sorted keys of the instances.
+func (r *Responder) Keys() []string {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	keys := make([]string, 0, len(r.instances))
+	for k := range r.instances {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Respond implements common.QueryResponder. The local address is unknown so
+// route functions which depend on it will fail, use RespondLocal instead.
+func (r *Responder) Respond(clientAddress string, buf []byte) ([]byte, error) {
+	pkts, err := r.RespondLocal(context.Background(), "", clientAddress, buf)
+	if err != nil {
+		return nil, err
+	} else if len(pkts) != 1 {
+		return nil, fmt.Errorf("response requires %d packets", len(pkts))
+	}
+
+	return pkts[0], nil
+}
+
+// RespondLocal implements common.LocalResponder.
+func (r *Responder) RespondLocal(ctx context.Context, localAddress, clientAddress string, buf []byte) ([][]byte, error) {
+	key, query, err := r.route(localAddress, clientAddress, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mtx.RLock()
+	qr, ok := r.instances[key]
+	r.mtx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownInstance, key)
+	}
+
+	return common.RespondPackets(ctx, qr, clientAddress, query)
+}
@@ -0,0 +1,75 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	sqpsample "github.com/multiplay/go-svrquery/lib/svrsample/protocol/sqp"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponderPortRoute(t *testing.T) {
+	r, err := NewResponder(PortRoute)
+	require.NoError(t, err)
+
+	s, err := server.New(server.WithAddresses("127.0.0.1:0", "127.0.0.1:0"), server.WithResponder(r))
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	addrs := s.Addrs()
+	require.Len(t, addrs, 2)
+	for i, addr := range addrs {
+		qr, err := sqpsample.NewQueryResponder(common.QueryState{ServerName: "instance" + strconv.Itoa(i)})
+		require.NoError(t, err)
+		r.Add(strconv.Itoa(addr.(*net.UDPAddr).Port), qr)
+	}
+	require.Len(t, r.Keys(), 2)
+
+	for i, addr := range addrs {
+		c, err := svrquery.NewClient("sqp", addr.String())
+		require.NoError(t, err)
+		defer c.Close()
+
+		resp, err := c.Query()
+		require.NoError(t, err)
+		require.Equal(t, "instance"+strconv.Itoa(i), resp.(*sqp.QueryResponse).ServerInfo.ServerName)
+	}
+}
+
+func TestResponderInstanceRoute(t *testing.T) {
+	// Queries are prefixed with the instance id.
+	r, err := NewResponder(func(localAddress, clientAddress string, buf []byte) (string, []byte, error) {
+		return string(buf[:1]), buf[1:], nil
+	})
+	require.NoError(t, err)
+	r.Add("a", echoResponder("a"))
+	r.Add("b", echoResponder("b"))
+
+	resp, err := r.Respond("client:1234", []byte("bquery"))
+	require.NoError(t, err)
+	require.Equal(t, "b:query", string(resp))
+
+	r.Remove("b")
+	require.Equal(t, []string{"a"}, r.Keys())
+	_, err = r.Respond("client:1234", []byte("bquery"))
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrUnknownInstance))
+
+	_, err = NewResponder(nil)
+	require.Equal(t, ErrNoRoute, err)
+}
+
+// echoResponder responds with its name and the query.
+type echoResponder string
+
+func (e echoResponder) Respond(clientAddress string, buf []byte) ([]byte, error) {
+	return append([]byte(e+":"), buf...), nil
+}
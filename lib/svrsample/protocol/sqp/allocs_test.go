@@ -0,0 +1,22 @@
+//go:build !race
+// +build !race
+
+// The race detector randomly drops items from sync.Pool, so allocations can't
+// be reliably counted with it enabled.
+
+package sqp
+
+import "testing"
+
+func TestAppendChunksAllocs(t *testing.T) {
+	buf := make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		var err error
+		if buf, err = appendChunks(buf[:0], chunkServerInfo|chunkServerRules, benchState); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations got %v", allocs)
+	}
+}
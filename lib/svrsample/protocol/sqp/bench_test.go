@@ -0,0 +1,67 @@
+package sqp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+)
+
+var benchState = common.QueryState{
+	CurrentPlayers: 12,
+	MaxPlayers:     64,
+	ServerName:     "Benchmark Server",
+	GameType:       "Capture the Flag",
+	Map:            "Harbour",
+	Port:           7777,
+	Rules: common.Rules{
+		"mode":     "ctf",
+		"round":    3,
+		"ff":       true,
+		"gravity":  9.8,
+		"password": false,
+	},
+}
+
+func BenchmarkEncodeChunks(b *testing.B) {
+	buf := make([]byte, 0, 1024)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = appendChunks(buf[:0], chunkServerInfo|chunkServerRules, benchState); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRespond(b *testing.B) {
+	benchmarkRespond(b)
+}
+
+func BenchmarkRespondCached(b *testing.B) {
+	benchmarkRespond(b, WithChunkCache(time.Second))
+}
+
+func benchmarkRespond(b *testing.B, options ...Option) {
+	q, err := NewQueryResponder(benchState, options...)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	addr := "127.0.0.1:65534"
+	query := []byte{1, 0, 0, 0, 0, 0, 1, chunkServerInfo | chunkServerRules}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		chal, err := q.Respond(addr, []byte{0, 0, 0, 0, 0})
+		if err != nil {
+			b.Fatal(err)
+		}
+		copy(query[1:5], chal[1:5])
+
+		if _, err := q.Respond(addr, query); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
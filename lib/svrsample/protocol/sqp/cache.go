@@ -0,0 +1,92 @@
+package sqp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+)
+
+// cacheEntry is an encoded chunk.
+type cacheEntry struct {
+	data      []byte
+	built     time.Time
+	version   uint64
+	versioned bool
+}
+
+// chunkCache caches encoded chunks.
+type chunkCache struct {
+	// ttls are the times each chunk of chunkEncoders is cached for, a chunk
+	// with a zero ttl isn't cached.
+	ttls    [len(chunkEncoders)]time.Duration
+	mtx     sync.Mutex
+	entries [len(chunkEncoders)]cacheEntry
+}
+
+// valid returns true if e can be used at now for the state version.
+func (e *cacheEntry) valid(ttl time.Duration, version uint64, versioned bool, now time.Time) bool {
+	return e.data != nil &&
+		now.Sub(e.built) < ttl &&
+		e.versioned == versioned &&
+		e.version == version
+}
+
+// appendCached appends the chunks requested by requestedChunks to b, only
+// encoding those which aren't cached or whose cache entry has expired. If the
+// state provider is versioned, entries also expire when its version changes.
+// The state is only obtained if a chunk has to be encoded.
+func (q *QueryResponder) appendCached(ctx context.Context, b []byte, requestedChunks byte, now time.Time) ([]byte, error) {
+	var version uint64
+	vp, versioned := q.provider.(common.VersionedStateProvider)
+	if versioned && q.stateContextFunc == nil {
+		version = vp.Version()
+	} else {
+		versioned = false
+	}
+
+	c := q.cache
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var state common.QueryState
+	var loaded bool
+	var err error
+	for i := range chunkEncoders {
+		enc := &chunkEncoders[i]
+		if requestedChunks&enc.chunk == 0 {
+			continue
+		}
+
+		e := &c.entries[i]
+		if c.ttls[i] > 0 && e.valid(c.ttls[i], version, versioned, now) {
+			b = append(b, e.data...)
+			continue
+		}
+
+		if !loaded {
+			if state, err = q.currentState(ctx); err != nil {
+				return b, err
+			}
+			loaded = true
+		}
+
+		n := len(b)
+		if b, err = appendChunk(b, enc, state); err != nil {
+			return b, err
+		}
+
+		if c.ttls[i] > 0 {
+			// Copy as b is a pooled buffer.
+			*e = cacheEntry{
+				data:      append([]byte(nil), b[n:]...),
+				built:     now,
+				version:   version,
+				versioned: versioned,
+			}
+		}
+	}
+
+	return b, nil
+}
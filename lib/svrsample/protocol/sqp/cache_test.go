@@ -0,0 +1,140 @@
+package sqp
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/stretchr/testify/require"
+)
+
+// query performs a challenge and query for chunks against q.
+func query(t *testing.T, q *QueryResponder, chunks byte) []byte {
+	t.Helper()
+
+	addr := "client-addr:65534"
+	resp, err := q.Respond(addr, []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+
+	resp, err = q.Respond(addr, bytes.Join([][]byte{{1}, resp[1:5], {0, 1}, {chunks}}, nil))
+	require.NoError(t, err)
+	return resp[headerSize:]
+}
+
+func TestChunkCache(t *testing.T) {
+	var calls int
+	state := common.QueryState{ServerName: "first", Players: []common.Player{{Name: "a"}}}
+	q, err := NewQueryResponder(common.QueryState{},
+		WithStateFunc(func() common.QueryState {
+			calls++
+			return state
+		}),
+		WithChunkCache(time.Minute),
+		WithChunkCacheTTL("players", 0),
+	)
+	require.NoError(t, err)
+
+	expected, err := appendChunks(nil, chunkServerInfo, state)
+	require.NoError(t, err)
+
+	require.Equal(t, expected, query(t, q, chunkServerInfo))
+	require.Equal(t, 1, calls)
+
+	// The cached chunk is used even though the state changed.
+	state.ServerName = "second"
+	require.Equal(t, expected, query(t, q, chunkServerInfo))
+	require.Equal(t, 1, calls)
+
+	// Players aren't cached so the state is obtained.
+	expected, err = appendChunks(nil, chunkServerInfo|chunkPlayerInfo, common.QueryState{ServerName: "first", Players: state.Players})
+	require.NoError(t, err)
+	require.Equal(t, expected, query(t, q, chunkServerInfo|chunkPlayerInfo))
+	require.Equal(t, 2, calls)
+}
+
+func TestChunkCacheVersioned(t *testing.T) {
+	state := common.NewAtomicState(common.QueryState{ServerName: "first"})
+	q, err := NewQueryResponder(common.QueryState{}, WithStateProvider(state), WithChunkCache(time.Hour))
+	require.NoError(t, err)
+
+	for _, name := range []string{"first", "second"} {
+		state.Update(func(qs *common.QueryState) {
+			qs.ServerName = name
+		})
+
+		expected, err := appendChunks(nil, chunkServerInfo, state.Snapshot())
+		require.NoError(t, err)
+		require.Equal(t, expected, query(t, q, chunkServerInfo))
+	}
+}
+
+func TestChunkCacheOptions(t *testing.T) {
+	_, err := NewQueryResponder(common.QueryState{}, WithChunkCache(0))
+	require.Error(t, err)
+
+	_, err = NewQueryResponder(common.QueryState{}, WithChunkCacheTTL("teams", time.Second))
+	require.Error(t, err)
+
+	_, err = NewQueryResponder(common.QueryState{}, WithChunkCacheTTL("rules", -time.Second))
+	require.Error(t, err)
+}
+
+func TestCoalescing(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	state := common.QueryState{ServerName: "coalesced", MaxPlayers: 8}
+	q, err := NewQueryResponder(common.QueryState{},
+		WithStateFunc(func() common.QueryState {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return state
+		}),
+		WithCoalescing(),
+	)
+	require.NoError(t, err)
+
+	const n = 5
+	var wg sync.WaitGroup
+	challenges := make([][]byte, n)
+	responses := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		addr := "client-addr:" + strconv.Itoa(i)
+		chal, err := q.Respond(addr, []byte{0, 0, 0, 0, 0})
+		require.NoError(t, err)
+		challenges[i] = chal[1:5]
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := q.Respond(addr, bytes.Join([][]byte{{1}, challenges[i], {0, 1}, {chunkServerInfo}}, nil))
+			require.NoError(t, err)
+			responses[i] = resp
+		}(i)
+	}
+
+	// Release the state once all but the first query are waiting for it.
+	for q.Coalesced() != n-1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	expected, err := appendChunks(nil, chunkServerInfo, state)
+	require.NoError(t, err)
+	for i, resp := range responses {
+		require.Equal(t, challenges[i], resp[1:5])
+		require.Equal(t, expected, resp[headerSize:])
+	}
+
+	// Subsequent queries encode a new payload.
+	state.ServerName = "updated"
+	expected, err = appendChunks(nil, chunkServerInfo, state)
+	require.NoError(t, err)
+	require.Equal(t, expected, query(t, q, chunkServerInfo))
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
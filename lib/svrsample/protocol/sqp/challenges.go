@@ -0,0 +1,95 @@
+package sqp
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultChallengeTTL is the default time a client has to use a challenge.
+const DefaultChallengeTTL = time.Second * 30
+
+// challengeEntry is a challenge issued to a client.
+type challengeEntry struct {
+	value  uint32
+	issued time.Time
+}
+
+// challengeStore stores the challenges issued to clients. Challenges which
+// aren't used within the ttl are expired, so memory use is bounded by the
+// number of clients seen within the ttl rather than growing with every client
+// which requests a challenge but never queries. It's safe for concurrent use.
+type challengeStore struct {
+	ttl time.Duration
+
+	// now returns the current time, it's replaced in tests.
+	now func() time.Time
+
+	mtx       sync.Mutex
+	entries   map[string]challengeEntry
+	lastSweep time.Time
+}
+
+// newChallengeStore returns a challengeStore which expires challenges after ttl.
+func newChallengeStore(ttl time.Duration) *challengeStore {
+	return &challengeStore{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]challengeEntry),
+	}
+}
+
+// issue stores v as the challenge of clientAddress and returns it. If reuse is
+// true and clientAddress has an unexpired challenge, it's returned instead.
+func (s *challengeStore) issue(clientAddress string, v uint32, reuse bool) uint32 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	now := s.now()
+	if e, ok := s.entries[clientAddress]; ok && reuse && now.Sub(e.issued) <= s.ttl {
+		return e.value
+	}
+
+	s.entries[clientAddress] = challengeEntry{value: v, issued: now}
+	if now.Sub(s.lastSweep) > s.ttl {
+		s.sweep(now)
+	}
+
+	return v
+}
+
+// take removes and returns the challenge of clientAddress, ok is false if it
+// has no unexpired challenge.
+func (s *challengeStore) take(clientAddress string) (v uint32, ok bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	e, ok := s.entries[clientAddress]
+	if !ok {
+		return 0, false
+	}
+
+	delete(s.entries, clientAddress)
+	return e.value, s.now().Sub(e.issued) <= s.ttl
+}
+
+// len returns the number of stored challenges.
+func (s *challengeStore) len() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return len(s.entries)
+}
+
+// sweep removes expired challenges. Maps don't shrink when entries are
+// deleted, so the live entries are copied to a new map to release the memory
+// of a burst of clients.
+func (s *challengeStore) sweep(now time.Time) {
+	entries := make(map[string]challengeEntry, len(s.entries))
+	for k, e := range s.entries {
+		if now.Sub(e.issued) <= s.ttl {
+			entries[k] = e
+		}
+	}
+	s.entries = entries
+	s.lastSweep = now
+}
@@ -0,0 +1,75 @@
+package sqp
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/leaktest"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChallengeStore(t *testing.T) {
+	s := newChallengeStore(time.Second)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	require.Equal(t, uint32(1), s.issue("a", 1, false))
+	require.Equal(t, uint32(2), s.issue("a", 2, false))
+	require.Equal(t, uint32(2), s.issue("a", 3, true))
+
+	v, ok := s.take("a")
+	require.True(t, ok)
+	require.Equal(t, uint32(2), v)
+
+	// Challenges are consumed.
+	_, ok = s.take("a")
+	require.False(t, ok)
+
+	// Expired challenges can't be used or reused.
+	s.issue("b", 4, false)
+	s.issue("c", 6, false)
+	now = now.Add(time.Second * 2)
+	require.Equal(t, uint32(5), s.issue("b", 5, true))
+	_, ok = s.take("c")
+	require.False(t, ok)
+
+	// Expired challenges are removed.
+	now = now.Add(time.Second * 2)
+	s.issue("d", 7, false)
+	require.Equal(t, 1, s.len())
+}
+
+func TestChallengesBounded(t *testing.T) {
+	q, err := NewQueryResponder(common.QueryState{}, WithChallengeTTL(time.Second))
+	require.NoError(t, err)
+
+	_, err = NewQueryResponder(common.QueryState{}, WithChallengeTTL(0))
+	require.Error(t, err)
+
+	now := time.Now()
+	q.challenges.now = func() time.Time { return now }
+
+	// Clients which request a challenge but never query.
+	before := leaktest.HeapAlloc()
+	for i := 0; i < 20000; i++ {
+		_, err := q.Respond("client-addr:"+strconv.Itoa(i), []byte{0, 0, 0, 0, 0})
+		require.NoError(t, err)
+	}
+	require.Equal(t, 20000, q.challenges.len())
+
+	// Challenges are kept until they expire.
+	now = now.Add(time.Second)
+	_, err = q.Respond("client-addr:0", []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	require.Equal(t, 20000, q.challenges.len())
+
+	now = now.Add(time.Millisecond)
+	_, err = q.Respond("client-addr:0", []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	require.Equal(t, 1, q.challenges.len())
+
+	after := leaktest.HeapAlloc()
+	require.True(t, after < before+256<<10, "heap grew from %d to %d bytes", before, after)
+}
@@ -0,0 +1,188 @@
+package sqp
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+)
+
+// Option represents a QueryResponder option.
+type Option func(*QueryResponder) error
+
+// WithStateProvider configures the responder to obtain the state for each
+// query from p instead of using the static state it was created with.
+func WithStateProvider(p common.StateProvider) Option {
+	return func(q *QueryResponder) error {
+		q.provider = p
+		return nil
+	}
+}
+
+// WithStateFunc configures the responder to obtain the state for each query from f
+// instead of using the static state it was created with.
+func WithStateFunc(f common.StateFunc) Option {
+	return WithStateProvider(f)
+}
+
+// WithStateContextFunc configures the responder to obtain the state for each
+// query from f, passing the context of the query. This takes precedence over
+// WithStateProvider.
+func WithStateContextFunc(f common.StateContextFunc) Option {
+	return func(q *QueryResponder) error {
+		q.stateContextFunc = f
+		return nil
+	}
+}
+
+// WithMaxPacketSize sets the maximum size of response packets, responses which
+// exceed it are split over multiple packets by RespondPackets. This can be used
+// to avoid IP fragmentation on paths which drop fragmented packets.
+func WithMaxPacketSize(size int) Option {
+	return func(q *QueryResponder) error {
+		if size <= headerSize {
+			return fmt.Errorf("max packet size %d must be greater than header size %d", size, headerSize)
+		}
+		q.maxPacketSize = size
+		return nil
+	}
+}
+
+// WithPadding pads each query response packet with zeros to size bytes, so
+// responses have a fixed size regardless of the server state. This prevents
+// servers being fingerprinted by the size of their responses and simplifies
+// filtering by anti-DDoS appliances. The padding isn't included in the
+// payload length of the packet header. Challenge responses aren't padded to
+// avoid amplifying spoofed challenge requests.
+func WithPadding(size int) Option {
+	return func(q *QueryResponder) error {
+		if size <= headerSize || size > math.MaxUint16 {
+			return fmt.Errorf("padding %d must be greater than header size %d and at most %d", size, headerSize, math.MaxUint16)
+		}
+		q.padding = size
+		return nil
+	}
+}
+
+// WithIdempotentChallenge configures the responder to return the currently stored
+// challenge when a client re-sends a challenge request before using it, instead of
+// rotating it. This prevents a retransmitted challenge request from invalidating
+// an in-flight handshake.
+func WithIdempotentChallenge() Option {
+	return func(q *QueryResponder) error {
+		q.idempotentChallenge = true
+		return nil
+	}
+}
+
+// WithChallengeTTL sets the time a client has to use a challenge before it
+// expires, the default is DefaultChallengeTTL. Expiring challenges bounds the
+// memory used by clients which request a challenge but never query.
+func WithChallengeTTL(ttl time.Duration) Option {
+	return func(q *QueryResponder) error {
+		if ttl <= 0 {
+			return fmt.Errorf("challenge ttl %v must be positive", ttl)
+		}
+		q.challengeTTL = ttl
+		return nil
+	}
+}
+
+// WithCompatibility sets how the responder handles queries from clients using
+// a newer version of SQP, the default is Strict.
+func WithCompatibility(c Compatibility) Option {
+	return func(q *QueryResponder) error {
+		switch c {
+		case Strict, Lenient:
+			q.compatibility = c
+			return nil
+		}
+		return fmt.Errorf("unknown compatibility %d", c)
+	}
+}
+
+// WithChunkCache enables caching of the encoded chunks of responses for up
+// to ttl. If the state provider implements common.VersionedStateProvider the
+// chunks are also rebuilt when its version changes, so ttl bounds how long a
+// chunk is cached when the state is unchanged. Otherwise responses may be
+// stale for up to ttl.
+func WithChunkCache(ttl time.Duration) Option {
+	return func(q *QueryResponder) error {
+		if ttl <= 0 {
+			return fmt.Errorf("chunk cache ttl %v must be positive", ttl)
+		}
+		if q.cache == nil {
+			q.cache = &chunkCache{}
+		}
+		for i := range q.cache.ttls {
+			q.cache.ttls[i] = ttl
+		}
+		return nil
+	}
+}
+
+// WithChunkCacheTTL sets the time the chunk, one of info, rules or players,
+// is cached for, overriding the ttl set by a preceding WithChunkCache. A ttl
+// of zero disables caching of the chunk.
+func WithChunkCacheTTL(chunk string, ttl time.Duration) Option {
+	return func(q *QueryResponder) error {
+		if ttl < 0 {
+			return fmt.Errorf("chunk cache ttl %v must not be negative", ttl)
+		}
+		for i, enc := range chunkEncoders {
+			if enc.name == chunk {
+				if q.cache == nil {
+					q.cache = &chunkCache{}
+				}
+				q.cache.ttls[i] = ttl
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown chunk %q", chunk)
+	}
+}
+
+// WithCoalescing enables coalescing of identical concurrent queries, so
+// during a burst of queries requesting the same chunks the response payload
+// is encoded once and shared, with each client's challenge set in its own
+// packets. Unlike WithChunkCache responses are never stale, as only queries
+// which arrive while the payload is being encoded share it. The payload is
+// encoded with the context of the first query.
+func WithCoalescing() Option {
+	return func(q *QueryResponder) error {
+		q.coalescer = &common.Coalescer{}
+		return nil
+	}
+}
+
+// WithReplayWindow enables replay protection, rejecting a query which is
+// identical to the last query answered for the same client within window.
+// Replays are rejected without consuming the clients current challenge.
+func WithReplayWindow(window time.Duration) Option {
+	return func(q *QueryResponder) error {
+		if window <= 0 {
+			return fmt.Errorf("replay window %v must be positive", window)
+		}
+		q.replays = common.NewReplayTracker(window)
+		return nil
+	}
+}
+
+// WithRejectHook sets a hook which is called when a request is rejected,
+// allowing the host server to log or block abusive sources.
+func WithRejectHook(h common.RejectHook) Option {
+	return func(q *QueryResponder) error {
+		q.rejectHook = h
+		return nil
+	}
+}
+
+// WithBlockFunc sets a function which is called for each request to
+// determine if the client should be blocked.
+func WithBlockFunc(f common.BlockFunc) Option {
+	return func(q *QueryResponder) error {
+		q.block = f
+		return nil
+	}
+}
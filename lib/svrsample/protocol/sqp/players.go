@@ -0,0 +1,59 @@
+package sqp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+)
+
+// ErrTooManyPlayers is returned if the number of players can't be encoded.
+var ErrTooManyPlayers = errors.New("too many players")
+
+// playerFields are the fields of each record in the PlayerInfo chunk.
+var playerFields = [...]struct {
+	name     string
+	dataType byte
+}{
+	{name: "name", dataType: dataTypeString},
+	{name: "score", dataType: dataTypeUint32},
+	{name: "team", dataType: dataTypeUint16},
+	{name: "connected", dataType: dataTypeUint32},
+}
+
+// appendPlayers appends players to b in the SQP PlayerInfo chunk format.
+// Scores are encoded as the two's complement of the score and the connected
+// duration in seconds.
+func appendPlayers(b []byte, players []common.Player) ([]byte, error) {
+	if len(players) > math.MaxUint16 {
+		return b, ErrTooManyPlayers
+	}
+
+	b = common.AppendUint16(b, uint16(len(players)))
+	if len(players) == 0 {
+		// The header is only required if there are records.
+		return b, nil
+	}
+
+	var err error
+	b = append(b, byte(len(playerFields)))
+	for _, f := range playerFields {
+		if b, err = common.AppendString(b, f.name); err != nil {
+			return b, err
+		}
+		b = append(b, f.dataType)
+	}
+
+	for i, p := range players {
+		if b, err = common.AppendString(b, p.Name); err != nil {
+			return b, fmt.Errorf("player %d: %w", i, err)
+		}
+		b = common.AppendUint32(b, uint32(p.Score))
+		b = common.AppendUint16(b, p.Team)
+		b = common.AppendUint32(b, uint32(p.Connected/time.Second))
+	}
+
+	return b, nil
+}
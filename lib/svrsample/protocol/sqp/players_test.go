@@ -0,0 +1,54 @@
+package sqp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodePlayers(t *testing.T) {
+	header := []byte{
+		4,
+		4, 'n', 'a', 'm', 'e', dataTypeString,
+		5, 's', 'c', 'o', 'r', 'e', dataTypeUint32,
+		4, 't', 'e', 'a', 'm', dataTypeUint16,
+		9, 'c', 'o', 'n', 'n', 'e', 'c', 't', 'e', 'd', dataTypeUint32,
+	}
+
+	cases := []struct {
+		name     string
+		players  []common.Player
+		expected []byte
+		err      bool
+	}{
+		{name: "none", expected: []byte{0, 0}},
+		{
+			name: "players",
+			players: []common.Player{
+				{Name: "a", Score: 10, Team: 1, Connected: time.Minute},
+				{Name: "b", Score: -1, Connected: time.Second * 1500},
+			},
+			expected: append(append([]byte{0, 2}, header...),
+				1, 'a', 0, 0, 0, 10, 0, 1, 0, 0, 0, 60,
+				1, 'b', 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0x05, 0xdc,
+			),
+		},
+		{name: "long-name", players: []common.Player{{Name: strings.Repeat("a", 256)}}, err: true},
+		{name: "too-many", players: make([]common.Player, 1<<16), err: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := appendPlayers(nil, tc.players)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, b)
+		})
+	}
+}
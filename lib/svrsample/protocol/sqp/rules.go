@@ -0,0 +1,119 @@
+package sqp
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+)
+
+// SQP dynamic value data types.
+const (
+	dataTypeByte byte = iota
+	dataTypeUint16
+	dataTypeUint32
+	dataTypeUint64
+	dataTypeString
+)
+
+// namesPool holds scratch slices used to sort rule names.
+var namesPool = sync.Pool{
+	New: func() interface{} {
+		return new([]string)
+	},
+}
+
+// appendRules appends rules to b in the SQP ServerRules chunk format.
+// Rules are written in name order. Values are encoded using the closest
+// SQP type, negative integers and floats are encoded as strings.
+func appendRules(b []byte, rules common.Rules) ([]byte, error) {
+	np := namesPool.Get().(*[]string)
+	defer namesPool.Put(np)
+
+	names := (*np)[:0]
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	*np = names
+
+	var err error
+	for _, name := range names {
+		if b, err = common.AppendString(b, name); err != nil {
+			return b, fmt.Errorf("rule %q: %w", name, err)
+		}
+
+		if b, err = appendRuleValue(b, rules[name]); err != nil {
+			return b, fmt.Errorf("rule %q: %w", name, err)
+		}
+	}
+
+	return b, nil
+}
+
+// appendRuleValue appends the type and value of v to b.
+func appendRuleValue(b []byte, v interface{}) ([]byte, error) {
+	// Avoid reflection for the common types.
+	switch v := v.(type) {
+	case string:
+		return common.AppendString(append(b, dataTypeString), v)
+	case bool:
+		if v {
+			return append(b, dataTypeByte, 1), nil
+		}
+		return append(b, dataTypeByte, 0), nil
+	case int:
+		return appendRuleInt(b, int64(v)), nil
+	case float64:
+		return appendRuleFloat(b, v, 64), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return common.AppendString(append(b, dataTypeString), rv.String())
+	case reflect.Bool:
+		return appendRuleValue(b, rv.Bool())
+	case reflect.Uint8:
+		return append(b, dataTypeByte, byte(rv.Uint())), nil
+	case reflect.Uint16:
+		return common.AppendUint16(append(b, dataTypeUint16), uint16(rv.Uint())), nil
+	case reflect.Uint32:
+		return common.AppendUint32(append(b, dataTypeUint32), uint32(rv.Uint())), nil
+	case reflect.Uint, reflect.Uint64:
+		return common.AppendUint64(append(b, dataTypeUint64), rv.Uint()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendRuleInt(b, rv.Int()), nil
+	case reflect.Float32, reflect.Float64:
+		return appendRuleFloat(b, rv.Float(), rv.Type().Bits()), nil
+	}
+
+	return b, fmt.Errorf("unsupported rule type %T", v)
+}
+
+// appendRuleInt appends i as a uint64 if it's not negative, otherwise as a string.
+func appendRuleInt(b []byte, i int64) []byte {
+	if i >= 0 {
+		return common.AppendUint64(append(b, dataTypeUint64), uint64(i))
+	}
+
+	// The length of a formatted int64 always fits in a byte.
+	b = append(b, dataTypeString, 0)
+	n := len(b)
+	b = strconv.AppendInt(b, i, 10)
+	b[n-1] = byte(len(b) - n)
+	return b
+}
+
+// appendRuleFloat appends f as a string.
+func appendRuleFloat(b []byte, f float64, bitSize int) []byte {
+	// The length of a formatted float always fits in a byte.
+	b = append(b, dataTypeString, 0)
+	n := len(b)
+	b = strconv.AppendFloat(b, f, 'g', -1, bitSize)
+	b[n-1] = byte(len(b) - n)
+	return b
+}
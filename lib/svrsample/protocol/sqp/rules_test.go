@@ -0,0 +1,50 @@
+package sqp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeRules(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    interface{}
+		expected []byte
+		err      bool
+	}{
+		{name: "string", value: "ctf", expected: []byte{dataTypeString, 3, 'c', 't', 'f'}},
+		{name: "true", value: true, expected: []byte{dataTypeByte, 1}},
+		{name: "false", value: false, expected: []byte{dataTypeByte, 0}},
+		{name: "uint8", value: uint8(5), expected: []byte{dataTypeByte, 5}},
+		{name: "uint16", value: uint16(258), expected: []byte{dataTypeUint16, 1, 2}},
+		{name: "uint32", value: uint32(258), expected: []byte{dataTypeUint32, 0, 0, 1, 2}},
+		{name: "uint64", value: uint64(258), expected: []byte{dataTypeUint64, 0, 0, 0, 0, 0, 0, 1, 2}},
+		{name: "int", value: 258, expected: []byte{dataTypeUint64, 0, 0, 0, 0, 0, 0, 1, 2}},
+		{name: "negative-int", value: int32(-3), expected: []byte{dataTypeString, 2, '-', '3'}},
+		{name: "float64", value: 1.5, expected: []byte{dataTypeString, 3, '1', '.', '5'}},
+		{name: "float32", value: float32(0.1), expected: []byte{dataTypeString, 3, '0', '.', '1'}},
+		{name: "long-string", value: strings.Repeat("a", 256), err: true},
+		{name: "unsupported", value: []string{"a"}, err: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := appendRules(nil, common.Rules{"r": tc.value})
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, append([]byte{1, 'r'}, tc.expected...), b)
+		})
+	}
+}
+
+func TestEncodeRulesOrder(t *testing.T) {
+	b, err := appendRules(nil, common.Rules{"b": true, "a": false})
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 'a', dataTypeByte, 0, 1, 'b', dataTypeByte, 1}, b)
+}
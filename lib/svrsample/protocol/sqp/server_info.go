@@ -39,3 +39,18 @@ func (si ServerInfo) Size() uint32 {
 			2, // Port
 	)
 }
+
+// AppendTo appends the wire encoding of si to b.
+func (si ServerInfo) AppendTo(b []byte) ([]byte, error) {
+	b = common.AppendUint16(b, si.CurrentPlayers)
+	b = common.AppendUint16(b, si.MaxPlayers)
+
+	var err error
+	for _, s := range [...]string{si.ServerName, si.GameType, si.BuildID, si.GameMap} {
+		if b, err = common.AppendString(b, s); err != nil {
+			return b, err
+		}
+	}
+
+	return common.AppendUint16(b, si.Port), nil
+}
@@ -0,0 +1,42 @@
+package sqp
+
+import (
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+)
+
+const (
+	// headerSize is the size of the query response header.
+	headerSize = 1 + // Header
+		4 + // Challenge
+		2 + // SQPVersion
+		1 + // CurrentPacketNum
+		1 + // LastPacketNum
+		2 // PayloadLength
+
+	// chunkLengthSize is the size of the length which prefixes each chunk.
+	chunkLengthSize = 4
+)
+
+// Sizes returns the size of each part of a response to a query for all
+// chunks encoded from state.
+func Sizes(state common.QueryState) (common.SizeReport, error) {
+	rules, err := appendRules(nil, state.Rules)
+	if err != nil {
+		return common.SizeReport{}, err
+	}
+
+	players, err := appendPlayers(nil, state.Players)
+	if err != nil {
+		return common.SizeReport{}, err
+	}
+
+	return common.SizeReport{
+		Protocol: "sqp",
+		Header:   headerSize,
+		Chunks: map[string]int{
+			"server_info":  chunkLengthSize + int(QueryStateToServerInfo(state).Size()),
+			"server_rules": chunkLengthSize + len(rules),
+			"player_info":  chunkLengthSize + len(players),
+		},
+	}, nil
+}
@@ -0,0 +1,50 @@
+package sqp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizes(t *testing.T) {
+	state := common.QueryState{
+		CurrentPlayers: 1,
+		MaxPlayers:     2,
+		ServerName:     "Name",
+		GameType:       "Game Type",
+		Map:            "Map",
+		Port:           1000,
+		Rules: common.Rules{
+			"mode": "ctf",
+		},
+		Players: []common.Player{
+			{Name: "a"},
+		},
+	}
+	r, err := Sizes(state)
+	require.NoError(t, err)
+	require.Equal(t, "sqp", r.Protocol)
+	require.Equal(t, 4+2+2+5+10+1+4+2, r.Chunks["server_info"])
+	require.Equal(t, 4+5+1+4, r.Chunks["server_rules"])
+	require.Equal(t, 4+2+31+2+4+2+4, r.Chunks["player_info"])
+
+	// Verify against the encoded response.
+	q, err := NewQueryResponder(state)
+	require.NoError(t, err)
+
+	addr := "client-addr:65534"
+	resp, err := q.Respond(addr, []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	resp, err = q.Respond(addr, bytes.Join([][]byte{{1}, resp[1:5], {0, 1}, {chunkServerInfo | chunkServerRules | chunkPlayerInfo}}, nil))
+	require.NoError(t, err)
+	require.Len(t, resp, r.Total())
+
+	require.True(t, r.Fits(1500))
+	require.False(t, r.Fits(r.Total()+common.IPv4UDPOverhead-1))
+	require.False(t, r.FitsIPv6(r.Total()+common.IPv4UDPOverhead))
+
+	_, err = Sizes(common.QueryState{Rules: common.Rules{"invalid": struct{}{}}})
+	require.Error(t, err)
+}
@@ -2,133 +2,409 @@ package sqp
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/multiplay/go-svrquery/lib/svrsample/common"
 )
 
 // QueryResponder responds to queries
 type QueryResponder struct {
-	challenges sync.Map
-	enc        *common.Encoder
-	state      common.QueryState
+	// counters is first to ensure 64-bit alignment for atomic access.
+	counters            common.RequestCounters
+	challenges          *challengeStore
+	challengeTTL        time.Duration
+	replays             *common.ReplayTracker
+	rejectHook          common.RejectHook
+	block               common.BlockFunc
+	provider            common.StateProvider
+	stateContextFunc    common.StateContextFunc
+	idempotentChallenge bool
+	compatibility       Compatibility
+	cache               *chunkCache
+	coalescer           *common.Coalescer
+	maxPacketSize       int
+	padding             int
 }
 
-// challengeWireFormat describes the format of an SQP challenge response
-type challengeWireFormat struct {
-	Header    byte
-	Challenge uint32
-}
+// Compatibility determines how a responder handles queries from clients
+// using a newer version of SQP.
+type Compatibility int
+
+const (
+	// Strict rejects queries for other SQP versions or unknown chunks.
+	Strict Compatibility = iota
+
+	// Lenient responds to queries for newer SQP versions using the version
+	// supported by the responder. Requested chunks which the responder
+	// doesn't implement are signalled as omitted with a zero chunk length.
+	Lenient
+)
 
-// queryWireFormat describes the format of an SQP query response
-type queryWireFormat struct {
-	Header           byte
-	Challenge        uint32
-	SQPVersion       uint16
-	CurrentPacketNum byte
-	LastPacketNum    byte
-	PayloadLength    uint16
-	ServerInfoLength uint32
-	ServerInfo       ServerInfo
+// payloadPool holds scratch buffers used to encode query response payloads.
+var payloadPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
 }
 
+// Query requested chunks
+const (
+	chunkServerInfo byte = 1 << iota
+	chunkServerRules
+	chunkPlayerInfo
+	chunkTeamInfo
+
+	// chunksKnown are the chunks defined by the SQP version supported.
+	chunksKnown = chunkServerInfo | chunkServerRules | chunkPlayerInfo | chunkTeamInfo
+
+	// chunksImplemented are the chunks which the responder encodes.
+	chunksImplemented = chunkServerInfo | chunkServerRules | chunkPlayerInfo
+)
+
+// version is the SQP version supported by the responder.
+const version uint16 = 1
+
 // NewQueryResponder returns creates a new responder capable of responding
 // to SQP-formatted queries.
-func NewQueryResponder(state common.QueryState) (*QueryResponder, error) {
-	q := &QueryResponder{
-		enc:   &common.Encoder{},
-		state: state,
+func NewQueryResponder(state common.QueryState, options ...Option) (*QueryResponder, error) {
+	q := &QueryResponder{provider: common.StaticState(state), challengeTTL: DefaultChallengeTTL}
+
+	for _, o := range options {
+		if err := o(q); err != nil {
+			return nil, err
+		}
+	}
+
+	if q.maxPacketSize > 0 && q.padding > q.maxPacketSize {
+		return nil, fmt.Errorf("padding %d exceeds max packet size %d", q.padding, q.maxPacketSize)
 	}
+	q.challenges = newChallengeStore(q.challengeTTL)
+
 	return q, nil
 }
 
 // Respond writes a query response to the requester in the SQP wire protocol.
+// An error is returned if the response exceeds the max packet size, use
+// RespondPackets to support multi-packet responses.
 func (q *QueryResponder) Respond(clientAddress string, buf []byte) ([]byte, error) {
+	return q.RespondContext(context.Background(), clientAddress, buf)
+}
+
+// RespondContext is like Respond but the construction of the response is
+// bounded by ctx.
+func (q *QueryResponder) RespondContext(ctx context.Context, clientAddress string, buf []byte) ([]byte, error) {
+	pkts, err := q.RespondPacketsContext(ctx, clientAddress, buf)
+	if err != nil {
+		return nil, err
+	} else if len(pkts) != 1 {
+		return nil, fmt.Errorf("response requires %d packets", len(pkts))
+	}
+
+	return pkts[0], nil
+}
+
+// RespondPackets writes a query response to the requester in the SQP wire protocol
+// splitting it over multiple packets if it exceeds the max packet size.
+func (q *QueryResponder) RespondPackets(clientAddress string, buf []byte) ([][]byte, error) {
+	return q.RespondPacketsContext(context.Background(), clientAddress, buf)
+}
+
+// RespondPacketsContext is like RespondPackets but the construction of the
+// response is bounded by ctx.
+func (q *QueryResponder) RespondPacketsContext(ctx context.Context, clientAddress string, buf []byte) ([][]byte, error) {
+	// Ensure challenges are keyed the same for all representations of an address.
+	clientAddress = common.NormalizeAddress(clientAddress)
+
+	if q.block != nil && q.block(clientAddress) {
+		return nil, q.reject(clientAddress, common.RejectBlocked, common.ErrBlocked)
+	}
+
 	switch {
 	case isChallenge(buf):
-		return q.handleChallenge(clientAddress)
+		resp, err := q.handleChallenge(clientAddress)
+		if err != nil {
+			return nil, err
+		}
+		q.counters.Accept()
+		return [][]byte{resp}, nil
 
 	case isQuery(buf):
-		return q.handleQuery(clientAddress, buf)
+		pkts, err := q.handleQuery(ctx, clientAddress, buf)
+		if err != nil {
+			return nil, err
+		}
+		q.counters.Accept()
+		return pkts, nil
+	}
+
+	return nil, q.reject(clientAddress, common.RejectMalformed, errors.New("unsupported query"))
+}
+
+// Stats returns the number of requests handled by outcome.
+func (q *QueryResponder) Stats() common.RequestStats {
+	return q.counters.Stats()
+}
+
+// Coalesced returns the number of queries which were answered with the
+// payload encoded for an identical concurrent query, see WithCoalescing.
+func (q *QueryResponder) Coalesced() uint64 {
+	if q.coalescer == nil {
+		return 0
 	}
+	return q.coalescer.Shared()
+}
 
-	return nil, errors.New("unsupported query")
+// reject records the rejection of a request from clientAddress for reason and
+// returns err.
+func (q *QueryResponder) reject(clientAddress string, reason common.RejectReason, err error) error {
+	q.counters.Reject(reason)
+	if q.rejectHook != nil {
+		q.rejectHook(clientAddress, reason)
+	}
+	return err
 }
 
 // isChallenge determines if the input buffer corresponds to a challenge packet.
 func isChallenge(buf []byte) bool {
-	return bytes.Equal(buf[0:5], []byte{0, 0, 0, 0, 0})
+	return len(buf) >= 5 && bytes.Equal(buf[0:5], []byte{0, 0, 0, 0, 0})
 }
 
 // isQuery determines if the input buffer corresponds to a query packet.
 func isQuery(buf []byte) bool {
-	return buf[0] == 1
+	return len(buf) > 0 && buf[0] == 1
 }
 
-// handleChallenge handles an incoming challenge packet.
-func (q *QueryResponder) handleChallenge(clientAddress string) ([]byte, error) {
-	v := rand.Uint32()
-	q.challenges.Store(clientAddress, v)
-
-	resp := bytes.NewBuffer(nil)
-	err := common.WireWrite(
-		resp,
-		q.enc,
-		challengeWireFormat{
-			Header:    0,
-			Challenge: v,
-		},
-	)
+// currentState returns the state to respond with, or an error if ctx is done.
+func (q *QueryResponder) currentState(ctx context.Context) (common.QueryState, error) {
+	if q.stateContextFunc == nil {
+		return q.provider.Snapshot(), ctx.Err()
+	}
+
+	state, err := q.stateContextFunc(ctx)
 	if err != nil {
-		return nil, err
+		return state, err
 	}
+	return state, ctx.Err()
+}
+
+// handleChallenge handles an incoming challenge packet.
+func (q *QueryResponder) handleChallenge(clientAddress string) ([]byte, error) {
+	// With idempotent challenges, reuse any challenge which hasn't been
+	// consumed by a query yet.
+	v := q.challenges.issue(clientAddress, rand.Uint32(), q.idempotentChallenge)
 
-	return resp.Bytes(), nil
+	return common.AppendUint32(append(make([]byte, 0, 5), 0), v), nil
 }
 
 // handleQuery handles an incoming query packet.
-func (q *QueryResponder) handleQuery(clientAddress string, buf []byte) ([]byte, error) {
-	expectedChallenge, ok := q.challenges.LoadAndDelete(clientAddress)
-	if !ok {
-		return nil, errors.New("no challenge")
+func (q *QueryResponder) handleQuery(ctx context.Context, clientAddress string, buf []byte) ([][]byte, error) {
+	if len(buf) < 8 {
+		return nil, q.reject(clientAddress, common.RejectMalformed, errors.New("packet not long enough"))
 	}
 
-	if len(buf) < 8 {
-		return nil, errors.New("packet not long enough")
+	// Check for replays before the challenge so a replay can't consume the
+	// challenge of a legitimate in-flight handshake.
+	now := time.Now()
+	if q.replays != nil && q.replays.Seen(clientAddress, buf, now) {
+		return nil, q.reject(clientAddress, common.RejectReplay, common.ErrReplay)
+	}
+
+	expectedChallenge, ok := q.challenges.take(clientAddress)
+	if !ok {
+		return nil, q.reject(clientAddress, common.RejectForged, errors.New("no challenge"))
 	}
 
 	// Challenge doesn't match, return with no response
-	if binary.BigEndian.Uint32(buf[1:5]) != expectedChallenge.(uint32) {
-		return nil, errors.New("challenge mismatch")
+	if binary.BigEndian.Uint32(buf[1:5]) != expectedChallenge {
+		return nil, q.reject(clientAddress, common.RejectForged, errors.New("challenge mismatch"))
+	}
+
+	if err := q.checkQuery(binary.BigEndian.Uint16(buf[5:7]), buf[7]); err != nil {
+		return nil, q.reject(clientAddress, common.RejectMalformed, err)
 	}
 
-	if binary.BigEndian.Uint16(buf[5:7]) != 1 {
-		return nil, fmt.Errorf("unsupported sqp version: %d", buf[6])
+	if q.replays != nil {
+		q.replays.Record(clientAddress, buf, now)
 	}
 
 	requestedChunks := buf[7]
-	wantsServerInfo := requestedChunks&0x1 == 1
-	f := queryWireFormat{
-		Header:     1,
-		Challenge:  expectedChallenge.(uint32),
-		SQPVersion: 1,
+	if q.coalescer != nil {
+		// The payload is shared by concurrent queries so can't use a pooled
+		// buffer, each query still gets its own packets with its challenge.
+		payload, err := q.coalescer.Do(requestedChunks, func() ([]byte, error) {
+			return q.encodePayload(ctx, nil, requestedChunks, now)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return q.packetize(expectedChallenge, payload)
 	}
 
-	resp := bytes.NewBuffer(nil)
+	pp := payloadPool.Get().(*[]byte)
+	defer payloadPool.Put(pp)
 
-	if wantsServerInfo {
-		f.ServerInfo = QueryStateToServerInfo(q.state)
-		f.ServerInfoLength = f.ServerInfo.Size()
-		f.PayloadLength += uint16(f.ServerInfoLength) + 4
+	payload, err := q.encodePayload(ctx, (*pp)[:0], requestedChunks, now)
+	*pp = payload
+	if err != nil {
+		return nil, err
 	}
 
-	if err := common.WireWrite(resp, q.enc, f); err != nil {
-		return nil, err
+	return q.packetize(expectedChallenge, payload)
+}
+
+// encodePayload appends the complete payload of the response to a query
+// requesting requestedChunks to b.
+func (q *QueryResponder) encodePayload(ctx context.Context, b []byte, requestedChunks byte, now time.Time) ([]byte, error) {
+	b, err := q.appendPayload(ctx, b, requestedChunks, now)
+	if err == nil && q.compatibility == Lenient {
+		b = appendOmitted(b, requestedChunks)
+	}
+	return b, err
+}
+
+// appendPayload appends the payload of the response to a query requesting
+// requestedChunks to b.
+func (q *QueryResponder) appendPayload(ctx context.Context, b []byte, requestedChunks byte, now time.Time) ([]byte, error) {
+	if q.cache != nil {
+		return q.appendCached(ctx, b, requestedChunks, now)
+	}
+
+	state, err := q.currentState(ctx)
+	if err != nil {
+		return b, err
+	}
+	return appendChunks(b, requestedChunks, state)
+}
+
+// checkQuery returns an error if a query for SQP version v requesting chunks
+// can't be answered with the compatibility of the responder.
+func (q *QueryResponder) checkQuery(v uint16, chunks byte) error {
+	switch {
+	case v == version:
+	case q.compatibility == Lenient && v > version:
+		// Respond with the highest version we both support.
+	default:
+		return fmt.Errorf("unsupported sqp version: %d", v)
+	}
+
+	if q.compatibility == Strict && chunks&^chunksKnown != 0 {
+		return fmt.Errorf("unknown chunks requested: 0x%02x", chunks&^chunksKnown)
+	}
+
+	return nil
+}
+
+// packetize splits payload into packets no larger than the max packet size,
+// padding each packet with zeros to the padding size if set. The padding is
+// not included in the payload length of the packet header.
+func (q *QueryResponder) packetize(challenge uint32, payload []byte) ([][]byte, error) {
+	fragmentSize := math.MaxUint16
+	if q.maxPacketSize > 0 {
+		fragmentSize = q.maxPacketSize - headerSize
 	}
 
-	return resp.Bytes(), nil
+	numPkts := (len(payload) + fragmentSize - 1) / fragmentSize
+	if numPkts == 0 {
+		numPkts = 1
+	} else if numPkts > math.MaxUint8+1 {
+		return nil, fmt.Errorf("payload too large: %d bytes", len(payload))
+	}
+
+	// All packets share a single allocation.
+	buf := make([]byte, 0, numPkts*headerSize+len(payload)+numPkts*q.padding)
+	pkts := make([][]byte, numPkts)
+	for i := range pkts {
+		fragment := payload
+		if len(fragment) > fragmentSize {
+			fragment = fragment[:fragmentSize]
+		}
+		payload = payload[len(fragment):]
+
+		start := len(buf)
+		buf = appendHeader(buf, challenge, byte(i), byte(numPkts-1), uint16(len(fragment)))
+		buf = append(buf, fragment...)
+		for len(buf)-start < q.padding {
+			buf = append(buf, 0)
+		}
+		pkts[i] = buf[start:len(buf):len(buf)]
+	}
+
+	return pkts, nil
+}
+
+// appendHeader appends a query response header to b.
+func appendHeader(b []byte, challenge uint32, curPkt, lastPkt byte, payloadLen uint16) []byte {
+	b = append(b, 1)
+	b = common.AppendUint32(b, challenge)
+	b = common.AppendUint16(b, version)
+	b = append(b, curPkt, lastPkt)
+	return common.AppendUint16(b, payloadLen)
+}
+
+// appendOmitted appends a zero length chunk to b for each chunk requested by
+// requestedChunks which isn't implemented. Chunks are encoded in bit order
+// and the implemented chunks are the lowest, so these always follow them.
+func appendOmitted(b []byte, requestedChunks byte) []byte {
+	for omitted := requestedChunks &^ chunksImplemented; omitted != 0; omitted &= omitted - 1 {
+		b = append(b, 0, 0, 0, 0)
+	}
+	return b
+}
+
+// chunkEncoder encodes a chunk from a state.
+type chunkEncoder struct {
+	chunk  byte
+	name   string
+	encode func(b []byte, state common.QueryState) ([]byte, error)
+}
+
+// chunkEncoders are the encoders of the implemented chunks in the order they
+// are sent.
+var chunkEncoders = [...]chunkEncoder{
+	{chunk: chunkServerInfo, name: "info", encode: func(b []byte, state common.QueryState) ([]byte, error) {
+		return QueryStateToServerInfo(state).AppendTo(b)
+	}},
+	{chunk: chunkServerRules, name: "rules", encode: func(b []byte, state common.QueryState) ([]byte, error) {
+		return appendRules(b, state.Rules)
+	}},
+	{chunk: chunkPlayerInfo, name: "players", encode: func(b []byte, state common.QueryState) ([]byte, error) {
+		return appendPlayers(b, state.Players)
+	}},
+}
+
+// appendChunks appends the encoded chunks requested by requestedChunks
+// containing the data from state to b.
+func appendChunks(b []byte, requestedChunks byte, state common.QueryState) ([]byte, error) {
+	var err error
+	for i := range chunkEncoders {
+		if requestedChunks&chunkEncoders[i].chunk == 0 {
+			continue
+		}
+
+		if b, err = appendChunk(b, &chunkEncoders[i], state); err != nil {
+			return b, err
+		}
+	}
+
+	return b, nil
+}
+
+// appendChunk appends the chunk encoded by enc, prefixed by its length, to b.
+func appendChunk(b []byte, enc *chunkEncoder, state common.QueryState) ([]byte, error) {
+	n := len(b)
+	b = append(b, 0, 0, 0, 0) // Chunk length
+	b, err := enc.encode(b, state)
+	if err != nil {
+		return b, err
+	}
+	common.PutUint32(b, n, uint32(len(b)-n-chunkLengthSize))
+	return b, nil
 }
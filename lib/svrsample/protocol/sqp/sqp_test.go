@@ -2,7 +2,10 @@ package sqp
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"testing"
+	"time"
 
 	"github.com/multiplay/go-svrquery/lib/svrsample/common"
 	"github.com/stretchr/testify/require"
@@ -53,3 +56,263 @@ func Test_Respond(t *testing.T) {
 		resp,
 	)
 }
+
+func Test_RespondIdempotentChallenge(t *testing.T) {
+	q, err := NewQueryResponder(common.QueryState{
+		CurrentPlayers: 1,
+		MaxPlayers:     2,
+	}, WithIdempotentChallenge())
+	require.NoError(t, err)
+
+	addr := "client-addr:65534"
+
+	first, err := q.Respond(addr, []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+
+	// A retransmitted challenge request must not rotate the challenge.
+	second, err := q.Respond(addr, []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+
+	// The stored challenge is consumed by the query.
+	_, err = q.Respond(addr, bytes.Join([][]byte{{1}, first[1:5], {0, 1}, {1}}, nil))
+	require.NoError(t, err)
+
+	_, err = q.Respond(addr, bytes.Join([][]byte{{1}, first[1:5], {0, 1}, {1}}, nil))
+	require.Error(t, err)
+}
+
+func Test_RespondIPv6(t *testing.T) {
+	q, err := NewQueryResponder(common.QueryState{})
+	require.NoError(t, err)
+
+	resp, err := q.Respond("[2001:db8::1]:65534", []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+
+	// Equivalent representation of the same address must match the challenge.
+	_, err = q.Respond("[2001:DB8:0::1]:65534", bytes.Join([][]byte{{1}, resp[1:5], {0, 1}, {1}}, nil))
+	require.NoError(t, err)
+
+	// Same host different port must not.
+	resp, err = q.Respond("[2001:db8::1]:65534", []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	_, err = q.Respond("[2001:db8::1]:65535", bytes.Join([][]byte{{1}, resp[1:5], {0, 1}, {1}}, nil))
+	require.Error(t, err)
+}
+
+func Test_RespondPadding(t *testing.T) {
+	_, err := NewQueryResponder(common.QueryState{}, WithPadding(headerSize))
+	require.Error(t, err)
+	_, err = NewQueryResponder(common.QueryState{}, WithPadding(512), WithMaxPacketSize(256))
+	require.Error(t, err)
+
+	tests := []struct {
+		name  string
+		state common.QueryState
+	}{
+		{name: "empty", state: common.QueryState{}},
+		{name: "named", state: common.QueryState{ServerName: "a server name", Map: "a map", MaxPlayers: 16}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := NewQueryResponder(tc.state, WithPadding(256))
+			require.NoError(t, err)
+
+			addr := "client-addr:65534"
+			chal, err := q.Respond(addr, []byte{0, 0, 0, 0, 0})
+			require.NoError(t, err)
+			require.Len(t, chal, 5, "challenge responses aren't padded")
+
+			resp, err := q.Respond(addr, bytes.Join([][]byte{{1}, chal[1:5], {0, 1}, {1}}, nil))
+			require.NoError(t, err)
+			require.Len(t, resp, 256)
+
+			// The declared length excludes the padding.
+			payloadLen := int(binary.BigEndian.Uint16(resp[9:11]))
+			infoLen := int(binary.BigEndian.Uint32(resp[headerSize:]))
+			require.Equal(t, chunkLengthSize+infoLen, payloadLen)
+			require.Equal(t, make([]byte, 256-headerSize-payloadLen), resp[headerSize+payloadLen:])
+		})
+	}
+
+	// Packets of multi-packet responses are each padded.
+	q, err := NewQueryResponder(common.QueryState{
+		ServerName: "a long enough server name to need several packets",
+	}, WithMaxPacketSize(headerSize+16), WithPadding(headerSize+16))
+	require.NoError(t, err)
+
+	addr := "client-addr:65534"
+	chal, err := q.Respond(addr, []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	pkts, err := q.RespondPackets(addr, bytes.Join([][]byte{{1}, chal[1:5], {0, 1}, {1}}, nil))
+	require.NoError(t, err)
+	require.True(t, len(pkts) > 1)
+	for _, p := range pkts {
+		require.Len(t, p, headerSize+16)
+	}
+}
+
+func Test_RespondPackets(t *testing.T) {
+	_, err := NewQueryResponder(common.QueryState{}, WithMaxPacketSize(headerSize))
+	require.Error(t, err)
+
+	q, err := NewQueryResponder(common.QueryState{
+		ServerName: "a long enough server name to need several packets",
+	}, WithMaxPacketSize(headerSize+16))
+	require.NoError(t, err)
+
+	addr := "client-addr:65534"
+	chal, err := q.Respond(addr, []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+
+	query := bytes.Join([][]byte{{1}, chal[1:5], {0, 1}, {1}}, nil)
+	pkts, err := q.RespondPackets(addr, query)
+	require.NoError(t, err)
+	require.True(t, len(pkts) > 1)
+
+	var payload []byte
+	for i, p := range pkts {
+		require.True(t, len(p) <= headerSize+16)
+		require.Equal(t, chal[1:5], p[1:5])
+		require.Equal(t, byte(i), p[7])
+		require.Equal(t, byte(len(pkts)-1), p[8])
+		require.Equal(t, uint16(len(p)-headerSize), uint16(p[9])<<8|uint16(p[10]))
+		payload = append(payload, p[headerSize:]...)
+	}
+
+	// The reassembled payload is a single server info chunk.
+	require.Equal(t, uint32(len(payload)-chunkLengthSize), binary.BigEndian.Uint32(payload))
+
+	// Multi-packet responses can't be returned by Respond.
+	chal, err = q.Respond(addr, []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	_, err = q.Respond(addr, bytes.Join([][]byte{{1}, chal[1:5], {0, 1}, {1}}, nil))
+	require.Error(t, err)
+}
+
+func Test_RespondReplay(t *testing.T) {
+	var rejected []common.RejectReason
+	q, err := NewQueryResponder(
+		common.QueryState{},
+		WithReplayWindow(time.Minute),
+		WithRejectHook(func(clientAddress string, reason common.RejectReason) {
+			rejected = append(rejected, reason)
+		}),
+		WithBlockFunc(func(clientAddress string) bool {
+			return clientAddress == "127.0.0.2:65534"
+		}),
+	)
+	require.NoError(t, err)
+
+	addr := "127.0.0.1:65534"
+	chal, err := q.Respond(addr, []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	query := bytes.Join([][]byte{{1}, chal[1:5], {0, 1}, {1}}, nil)
+	_, err = q.Respond(addr, query)
+	require.NoError(t, err)
+
+	// A replay must not consume a new challenge.
+	chal, err = q.Respond(addr, []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	_, err = q.Respond(addr, query)
+	require.Equal(t, common.ErrReplay, err)
+	_, err = q.Respond(addr, bytes.Join([][]byte{{1}, chal[1:5], {0, 1}, {1}}, nil))
+	require.NoError(t, err)
+
+	// Forged and malformed queries.
+	_, err = q.Respond(addr, []byte{1, 9, 9, 9, 9, 0, 1, 1})
+	require.Error(t, err)
+	_, err = q.Respond(addr, []byte{1, 0})
+	require.Error(t, err)
+
+	_, err = q.Respond("127.0.0.2:65534", []byte{0, 0, 0, 0, 0})
+	require.Equal(t, common.ErrBlocked, err)
+
+	require.Equal(t, []common.RejectReason{
+		common.RejectReplay,
+		common.RejectForged,
+		common.RejectMalformed,
+		common.RejectBlocked,
+	}, rejected)
+	require.Equal(t, common.RequestStats{
+		Accepted:  4,
+		Replayed:  1,
+		Forged:    1,
+		Malformed: 1,
+		Blocked:   1,
+	}, q.Stats())
+
+	_, err = NewQueryResponder(common.QueryState{}, WithReplayWindow(0))
+	require.Error(t, err)
+}
+
+func Test_RespondContext(t *testing.T) {
+	q, err := NewQueryResponder(common.QueryState{}, WithStateContextFunc(func(ctx context.Context) (common.QueryState, error) {
+		<-ctx.Done()
+		return common.QueryState{}, ctx.Err()
+	}))
+	require.NoError(t, err)
+
+	addr := "client-addr:65534"
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	// Challenges don't require the state.
+	resp, err := q.RespondContext(ctx, addr, []byte{0, 0, 0, 0, 0})
+	require.NoError(t, err)
+
+	_, err = q.RespondContext(ctx, addr, bytes.Join([][]byte{{1}, resp[1:5], {0, 1}, {1}}, nil))
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func Test_RespondCompatibility(t *testing.T) {
+	info := []byte{0x0, 0x0, 0x0, 0xa, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}
+	cases := []struct {
+		name          string
+		compatibility Compatibility
+		version       uint16
+		chunks        byte
+		payload       []byte
+		err           bool
+	}{
+		{name: "strict", compatibility: Strict, version: 1, chunks: chunkServerInfo, payload: info},
+		{name: "strict-newer-version", compatibility: Strict, version: 2, chunks: chunkServerInfo, err: true},
+		{name: "strict-unknown-chunks", compatibility: Strict, version: 1, chunks: chunkServerInfo | 0x10, err: true},
+		{name: "lenient-newer-version", compatibility: Lenient, version: 2, chunks: chunkServerInfo, payload: info},
+		{name: "lenient-older-version", compatibility: Lenient, version: 0, chunks: chunkServerInfo, err: true},
+		{
+			name:          "lenient-omitted-chunks",
+			compatibility: Lenient,
+			version:       1,
+			chunks:        chunkServerInfo | chunkTeamInfo | 0x10,
+			payload:       append(append([]byte{}, info...), 0, 0, 0, 0, 0, 0, 0, 0),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := NewQueryResponder(common.QueryState{}, WithCompatibility(tc.compatibility))
+			require.NoError(t, err)
+
+			addr := "client-addr:65534"
+			resp, err := q.Respond(addr, []byte{0, 0, 0, 0, 0})
+			require.NoError(t, err)
+
+			query := bytes.Join([][]byte{{1}, resp[1:5], {0, 0}, {tc.chunks}}, nil)
+			binary.BigEndian.PutUint16(query[5:7], tc.version)
+			resp, err = q.Respond(addr, query)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, version, binary.BigEndian.Uint16(resp[5:7]))
+			require.Equal(t, len(tc.payload), int(binary.BigEndian.Uint16(resp[9:11])))
+			require.Equal(t, tc.payload, resp[11:])
+		})
+	}
+
+	_, err := NewQueryResponder(common.QueryState{}, WithCompatibility(Compatibility(-1)))
+	require.Error(t, err)
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	sqpsample "github.com/multiplay/go-svrquery/lib/svrsample/protocol/sqp"
+)
+
+// Option represents a Proxy option.
+type Option func(*Proxy) error
+
+// WithCacheTTL sets the time the state of a backend is cached for.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(p *Proxy) error {
+		p.ttl = ttl
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout for queries to backends.
+func WithTimeout(timeout time.Duration) Option {
+	return func(p *Proxy) error {
+		p.timeout = timeout
+		return nil
+	}
+}
+
+// WithRateLimit limits the requests from each client host to rate per second
+// with bursts of up to burst requests. Requests over the limit are dropped.
+func WithRateLimit(rate float64, burst int) Option {
+	return func(p *Proxy) error {
+		if rate <= 0 || burst < 1 {
+			return fmt.Errorf("invalid rate limit %v burst %d", rate, burst)
+		}
+		p.limiter = common.NewRateLimiter(rate, burst)
+		return nil
+	}
+}
+
+// WithResponderOptions sets options which are applied to the responder of
+// each backend e.g. sqp.WithReplayWindow.
+func WithResponderOptions(options ...sqpsample.Option) Option {
+	return func(p *Proxy) error {
+		p.rOptions = options
+		return nil
+	}
+}
+
+// WithQueryOptions sets options which are applied to the client used to query
+// each backend e.g. svrquery.WithPlayerNameFilter.
+func WithQueryOptions(options ...svrquery.Option) Option {
+	return func(p *Proxy) error {
+		p.qOptions = options
+		return nil
+	}
+}
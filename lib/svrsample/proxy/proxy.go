@@ -0,0 +1,192 @@
+// Package proxy provides a host agent which answers SQP queries on behalf of
+// the game servers on a host. Clients query the proxy, which performs the
+// challenge handshake itself and answers from a cached state obtained by
+// querying the local query listener of each game server, so the game servers
+// only receive a bounded rate of queries however many clients there are.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/multiplay/go-svrquery/lib/svrsample/multi"
+	sqpsample "github.com/multiplay/go-svrquery/lib/svrsample/protocol/sqp"
+)
+
+var (
+	// DefaultCacheTTL is the default time a backend state is cached for.
+	DefaultCacheTTL = time.Second
+
+	// ErrUnexpectedResponse is returned if a backend responds with an
+	// unexpected response type.
+	ErrUnexpectedResponse = errors.New("unexpected response")
+)
+
+// Proxy answers queries using the cached state of the backend selected by
+// its route function. It's safe for concurrent use.
+type Proxy struct {
+	ttl       time.Duration
+	timeout   time.Duration
+	limiter   *common.RateLimiter
+	rOptions  []sqpsample.Option
+	qOptions  []svrquery.Option
+	responder *multi.Responder
+}
+
+// New returns a new Proxy which routes queries to backends using route.
+func New(route multi.RouteFunc, options ...Option) (*Proxy, error) {
+	r, err := multi.NewResponder(route)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{
+		ttl:       DefaultCacheTTL,
+		timeout:   svrquery.DefaultTimeout,
+		responder: r,
+	}
+
+	for _, o := range options {
+		if err := o(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Add adds or replaces the backend for key which is queried using SQP at
+// addr, typically a loopback address.
+func (p *Proxy) Add(key, addr string) error {
+	b := &backend{addr: addr, ttl: p.ttl, timeout: p.timeout, options: p.qOptions}
+	// Identical concurrent queries are answered from a single encode of the
+	// cached state, reducing CPU during query storms.
+	options := append([]sqpsample.Option{sqpsample.WithStateContextFunc(b.snapshot), sqpsample.WithCoalescing()}, p.rOptions...)
+	if p.limiter != nil {
+		options = append(options, sqpsample.WithBlockFunc(func(clientAddress string) bool {
+			return !p.limiter.Allow(clientAddress, time.Now())
+		}))
+	}
+
+	r, err := sqpsample.NewQueryResponder(common.QueryState{}, options...)
+	if err != nil {
+		return err
+	}
+
+	p.responder.Add(key, r)
+	return nil
+}
+
+// Remove removes the backend for key.
+func (p *Proxy) Remove(key string) {
+	p.responder.Remove(key)
+}
+
+// Keys returns the sorted keys of the backends.
+func (p *Proxy) Keys() []string {
+	return p.responder.Keys()
+}
+
+// Respond implements common.QueryResponder.
+func (p *Proxy) Respond(clientAddress string, buf []byte) ([]byte, error) {
+	return p.responder.Respond(clientAddress, buf)
+}
+
+// RespondLocal implements common.LocalResponder.
+func (p *Proxy) RespondLocal(ctx context.Context, localAddress, clientAddress string, buf []byte) ([][]byte, error) {
+	return p.responder.RespondLocal(ctx, localAddress, clientAddress, buf)
+}
+
+// backend is a game server query listener whose state is cached.
+type backend struct {
+	addr    string
+	ttl     time.Duration
+	timeout time.Duration
+	options []svrquery.Option
+
+	// mtx is held while querying so concurrent requests share a query.
+	mtx     sync.Mutex
+	state   common.QueryState
+	err     error
+	fetched time.Time
+}
+
+// snapshot returns the cached state of b, querying it if the cache expired.
+// Failures are also cached so an unresponsive backend isn't queried for
+// every request.
+func (b *backend) snapshot(ctx context.Context) (common.QueryState, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return common.QueryState{}, err
+	}
+
+	if time.Since(b.fetched) >= b.ttl {
+		b.state, b.err = b.query()
+		b.fetched = time.Now()
+	}
+	return b.state, b.err
+}
+
+// query queries the state of b.
+func (b *backend) query() (common.QueryState, error) {
+	options := append([]svrquery.Option{
+		svrquery.WithTimeout(b.timeout),
+		svrquery.WithArg(sqp.ChunksArg, "info,rules,players"),
+	}, b.options...)
+	c, err := svrquery.NewClient("sqp", b.addr, options...)
+	if err != nil {
+		return common.QueryState{}, err
+	}
+	defer c.Close()
+
+	resp, err := c.Query()
+	if err != nil {
+		return common.QueryState{}, err
+	}
+
+	qr, ok := resp.(*sqp.QueryResponse)
+	if !ok {
+		return common.QueryState{}, ErrUnexpectedResponse
+	}
+	return responseState(qr), nil
+}
+
+// responseState returns the QueryState equivalent to qr.
+func responseState(qr *sqp.QueryResponse) common.QueryState {
+	var qs common.QueryState
+	if si := qr.ServerInfo; si != nil {
+		qs.CurrentPlayers = int32(si.CurrentPlayers)
+		qs.MaxPlayers = int32(si.MaxPlayers)
+		qs.ServerName = si.ServerName
+		qs.GameType = si.GameType
+		qs.Map = si.Map
+		qs.Port = si.Port
+	}
+
+	if qr.ServerRules != nil {
+		qs.Rules = make(common.Rules, len(qr.ServerRules.Rules))
+		for name, v := range qr.ServerRules.Rules {
+			qs.Rules[name] = v.Value
+		}
+	}
+
+	if qr.PlayerInfo != nil {
+		for _, p := range qr.PlayerInfo.Roster() {
+			qs.Players = append(qs.Players, common.Player{
+				Name:      p.Name,
+				Score:     p.Score,
+				Team:      p.Team,
+				Connected: p.Connected,
+			})
+		}
+	}
+
+	return qs
+}
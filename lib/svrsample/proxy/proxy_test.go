@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/multiplay/go-svrquery/lib/svrsample/multi"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer starts a server with options and returns it.
+func startServer(t *testing.T, options ...server.Option) *server.Server {
+	t.Helper()
+
+	s, err := server.New(options...)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	return s
+}
+
+// query queries addr using SQP.
+func query(addr string) (*sqp.QueryResponse, error) {
+	c, err := svrquery.NewClient("sqp", addr,
+		svrquery.WithTimeout(time.Millisecond*200),
+		svrquery.WithArg(sqp.ChunksArg, "info,rules,players"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	r, err := c.Query()
+	if err != nil {
+		return nil, err
+	}
+	return r.(*sqp.QueryResponse), nil
+}
+
+func TestProxy(t *testing.T) {
+	var calls int32
+	backend := startServer(t,
+		server.WithAddress("127.0.0.1:0"),
+		server.WithProtocol("sqp"),
+		server.WithStateFunc(func() common.QueryState {
+			atomic.AddInt32(&calls, 1)
+			return common.QueryState{
+				CurrentPlayers: 1,
+				MaxPlayers:     8,
+				ServerName:     "backend",
+				Map:            "harbour",
+				Rules:          common.Rules{"mode": "ctf"},
+				Players:        []common.Player{{Name: "alice", Score: 3, Connected: time.Minute}},
+			}
+		}),
+	)
+	defer backend.Shutdown(context.Background())
+
+	p, err := New(multi.PortRoute, WithCacheTTL(time.Minute))
+	require.NoError(t, err)
+	front := startServer(t, server.WithAddress("127.0.0.1:0"), server.WithResponder(p))
+	defer front.Shutdown(context.Background())
+
+	port := strconv.Itoa(front.Addr().(*net.UDPAddr).Port)
+	require.NoError(t, p.Add(port, backend.Addr().String()))
+	require.Equal(t, []string{port}, p.Keys())
+
+	for i := 0; i < 3; i++ {
+		qr, err := query(front.Addr().String())
+		require.NoError(t, err)
+		require.Equal(t, "backend", qr.ServerInfo.ServerName)
+		require.Equal(t, "harbour", qr.ServerInfo.Map)
+		require.Equal(t, uint16(8), qr.ServerInfo.MaxPlayers)
+		require.Equal(t, "ctf", qr.ServerRules.Rules["mode"].String())
+		require.Equal(t, []sqp.Player{{Name: "alice", Score: 3, Connected: time.Minute}}, qr.PlayerInfo.Roster())
+	}
+
+	// The backend state was cached.
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	p.Remove(port)
+	_, err = query(front.Addr().String())
+	require.Error(t, err)
+}
+
+func TestProxyRateLimit(t *testing.T) {
+	backend := startServer(t,
+		server.WithAddress("127.0.0.1:0"),
+		server.WithProtocol("sqp"),
+		server.WithState(common.QueryState{ServerName: "backend"}),
+	)
+	defer backend.Shutdown(context.Background())
+
+	route := func(localAddress, clientAddress string, buf []byte) (string, []byte, error) {
+		return "backend", buf, nil
+	}
+	p, err := New(route, WithRateLimit(0.1, 2))
+	require.NoError(t, err)
+	require.NoError(t, p.Add("backend", backend.Addr().String()))
+	front := startServer(t, server.WithAddress("127.0.0.1:0"), server.WithResponder(p))
+	defer front.Shutdown(context.Background())
+
+	// A query is a challenge and query request.
+	_, err = query(front.Addr().String())
+	require.NoError(t, err)
+
+	_, err = query(front.Addr().String())
+	require.Error(t, err)
+
+	_, err = New(route, WithRateLimit(0, 1))
+	require.Error(t, err)
+}
+
+func TestProxyQueryOptions(t *testing.T) {
+	backend := startServer(t,
+		server.WithAddress("127.0.0.1:0"),
+		server.WithProtocol("sqp"),
+		server.WithState(common.QueryState{
+			CurrentPlayers: 1,
+			Players:        []common.Player{{Name: "alice", Score: 3}},
+		}),
+	)
+	defer backend.Shutdown(context.Background())
+
+	route := func(localAddress, clientAddress string, buf []byte) (string, []byte, error) {
+		return "backend", buf, nil
+	}
+	p, err := New(route, WithQueryOptions(svrquery.WithPlayerNameFilter(svrquery.RedactNames())))
+	require.NoError(t, err)
+	require.NoError(t, p.Add("backend", backend.Addr().String()))
+	front := startServer(t, server.WithAddress("127.0.0.1:0"), server.WithResponder(p))
+	defer front.Shutdown(context.Background())
+
+	qr, err := query(front.Addr().String())
+	require.NoError(t, err)
+	require.Equal(t, []sqp.Player{{Name: svrquery.RedactedName, Score: 3}}, qr.PlayerInfo.Roster())
+}
@@ -22,3 +22,39 @@ func GetResponder(proto string, state common.QueryState) (common.QueryResponder,
 	}
 	return nil, fmt.Errorf("%w: %s", ErrProtoNotFound, proto)
 }
+
+// GetResponderFunc gets the appropriate responder for the protocol provided
+// which obtains the state to respond with from f for each query.
+func GetResponderFunc(proto string, f common.StateFunc) (common.QueryResponder, error) {
+	return GetResponderProvider(proto, f)
+}
+
+// GetResponderProvider gets the appropriate responder for the protocol
+// provided which obtains the state to respond with from p for each query.
+func GetResponderProvider(proto string, p common.StateProvider) (common.QueryResponder, error) {
+	switch proto {
+	case "sqp":
+		return sqp.NewQueryResponder(common.QueryState{}, sqp.WithStateProvider(p))
+	}
+	return nil, fmt.Errorf("%w: %s", ErrProtoNotFound, proto)
+}
+
+// GetResponderContextFunc gets the appropriate responder for the protocol
+// provided which obtains the state to respond with from f for each query.
+func GetResponderContextFunc(proto string, f common.StateContextFunc) (common.QueryResponder, error) {
+	switch proto {
+	case "sqp":
+		return sqp.NewQueryResponder(common.QueryState{}, sqp.WithStateContextFunc(f))
+	}
+	return nil, fmt.Errorf("%w: %s", ErrProtoNotFound, proto)
+}
+
+// Sizes returns the encoded size of each part of a response to a query for
+// all chunks of state using the protocol provided.
+func Sizes(proto string, state common.QueryState) (common.SizeReport, error) {
+	switch proto {
+	case "sqp":
+		return sqp.Sizes(state)
+	}
+	return common.SizeReport{}, fmt.Errorf("%w: %s", ErrProtoNotFound, proto)
+}
@@ -0,0 +1,3 @@
+// Package server provides a query server which can be embedded in a game server
+// to answer queries using the svrsample responders.
+package server
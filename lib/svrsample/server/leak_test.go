@@ -0,0 +1,36 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/leaktest"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerLeaks(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// Repeatedly start, query and shutdown so per lifecycle leaks accumulate.
+	for i := 0; i < 3; i++ {
+		s, err := New(
+			WithAddress("127.0.0.1:0"),
+			WithProtocol("sqp"),
+			WithState(common.QueryState{ServerName: "leaks", MaxPlayers: 2}),
+		)
+		require.NoError(t, err)
+		require.NoError(t, s.Start(context.Background()))
+
+		for j := 0; j < 5; j++ {
+			c, err := svrquery.NewClient("sqp", s.Addr().String())
+			require.NoError(t, err)
+			_, err = c.Query()
+			require.NoError(t, err)
+			require.NoError(t, c.Close())
+		}
+
+		require.NoError(t, s.Shutdown(context.Background()))
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+)
+
+// Option represents a Server option.
+type Option func(*Server) error
+
+// WithAddress sets the address the server listens on e.g. ":12121".
+func WithAddress(addr string) Option {
+	return func(s *Server) error {
+		s.addr = addr
+		return nil
+	}
+}
+
+// WithAddresses sets multiple addresses the server listens on, this overrides
+// WithAddress and WithDualStack. Combined with a common.LocalResponder this
+// allows a single server to answer queries on behalf of multiple servers
+// using their query ports.
+func WithAddresses(addrs ...string) Option {
+	return func(s *Server) error {
+		s.addrs = addrs
+		return nil
+	}
+}
+
+// WithNetwork sets the network the server listens on e.g. "udp4".
+func WithNetwork(network string) Option {
+	return func(s *Server) error {
+		s.network = network
+		return nil
+	}
+}
+
+// WithDualStack configures the server to listen on separate IPv4 and IPv6
+// sockets using the same port. The host of the address must be empty.
+func WithDualStack() Option {
+	return func(s *Server) error {
+		s.dualStack = true
+		return nil
+	}
+}
+
+// WithProtocol sets the protocol the server responds to queries with.
+func WithProtocol(proto string) Option {
+	return func(s *Server) error {
+		s.proto = proto
+		return nil
+	}
+}
+
+// WithState sets a static state which the server responds to queries with.
+func WithState(state common.QueryState) Option {
+	return WithStateProvider(common.StaticState(state))
+}
+
+// WithStateFunc sets the function called to pull the current state from the
+// host application for each query.
+func WithStateFunc(f common.StateFunc) Option {
+	return WithStateProvider(f)
+}
+
+// WithStateProvider sets the provider the current state is pulled from for
+// each query.
+func WithStateProvider(p common.StateProvider) Option {
+	return func(s *Server) error {
+		s.provider = p
+		return nil
+	}
+}
+
+// WithStateContextFunc sets the function called to pull the current state
+// from the host application for each query. The context passed to f is done
+// when the response timeout expires or the server is shutdown, allowing slow
+// state sources to be bounded. This takes precedence over WithStateProvider.
+func WithStateContextFunc(f common.StateContextFunc) Option {
+	return func(s *Server) error {
+		s.stateCtxFunc = f
+		return nil
+	}
+}
+
+// WithResponder sets a custom responder used to respond to queries, this
+// overrides the responder selected by WithProtocol.
+func WithResponder(r common.QueryResponder) Option {
+	return func(s *Server) error {
+		s.responder = r
+		return nil
+	}
+}
+
+// WithLogger sets the logger used to report errors processing queries.
+func WithLogger(l *log.Logger) Option {
+	return func(s *Server) error {
+		s.logger = l
+		return nil
+	}
+}
+
+// WithWriteTimeout sets the write timeout for responses.
+func WithWriteTimeout(t time.Duration) Option {
+	return func(s *Server) error {
+		s.writeTimeout = t
+		return nil
+	}
+}
+
+// WithResponseTimeout sets the maximum time taken to construct a response,
+// after which the query is dropped. This only applies to responders which
+// implement common.ContextResponder. The default of zero means no timeout.
+func WithResponseTimeout(t time.Duration) Option {
+	return func(s *Server) error {
+		s.respTimeout = t
+		return nil
+	}
+}
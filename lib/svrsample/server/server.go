@@ -0,0 +1,353 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrsample"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+)
+
+var (
+	// DefaultNetwork is the default network a server listens on.
+	DefaultNetwork = "udp"
+
+	// DefaultWriteTimeout is the default write timeout for responses.
+	DefaultWriteTimeout = time.Second
+
+	// ErrStarted is returned by Start if the server has already been started.
+	ErrStarted = errors.New("server already started")
+
+	// ErrNotStarted is returned by Shutdown if the server has not been started.
+	ErrNotStarted = errors.New("server not started")
+
+	// ErrNoResponder is returned by New if neither a protocol or responder is configured.
+	ErrNoResponder = errors.New("no protocol or responder")
+
+	// ErrNoState is returned by New if a protocol is configured without a state.
+	ErrNoState = errors.New("no state")
+)
+
+const (
+	// readBufferSize is the size of the buffer used to read queries.
+	readBufferSize = 1472
+)
+
+// Server answers queries received on UDP sockets using a responder.
+type Server struct {
+	network      string
+	addr         string
+	addrs        []string
+	dualStack    bool
+	proto        string
+	provider     common.StateProvider
+	stateCtxFunc common.StateContextFunc
+	responder    common.QueryResponder
+	logger       *log.Logger
+	writeTimeout time.Duration
+	respTimeout  time.Duration
+
+	mtx sync.Mutex
+	l   *listener
+}
+
+// listener represents the sockets of a started server.
+type listener struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	conns     []net.PacketConn
+	stopping  chan struct{}
+	done      chan struct{}
+	stopOnce  sync.Once
+	closeOnce sync.Once
+}
+
+// New creates a new server configured by options.
+// Either WithProtocol and a state or WithResponder must be specified.
+func New(options ...Option) (*Server, error) {
+	s := &Server{
+		network:      DefaultNetwork,
+		writeTimeout: DefaultWriteTimeout,
+		logger:       log.New(ioutil.Discard, "", 0),
+	}
+
+	for _, o := range options {
+		if err := o(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.responder != nil {
+		return s, nil
+	}
+
+	var err error
+	switch {
+	case s.proto == "":
+		return nil, ErrNoResponder
+	case s.stateCtxFunc != nil:
+		s.responder, err = svrsample.GetResponderContextFunc(s.proto, s.stateCtxFunc)
+	case s.provider != nil:
+		s.responder, err = svrsample.GetResponderProvider(s.proto, s.provider)
+	default:
+		return nil, ErrNoState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Start starts listening for and responding to queries in the background.
+// The server is shutdown without waiting for in-flight responses if ctx is
+// cancelled, which also cancels the construction of in-flight responses.
+func (s *Server) Start(ctx context.Context) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.l != nil {
+		return ErrStarted
+	}
+
+	conns, err := s.listen(ctx)
+	if err != nil {
+		return err
+	}
+
+	lctx, cancel := context.WithCancel(ctx)
+	l := &listener{
+		ctx:      lctx,
+		cancel:   cancel,
+		conns:    conns,
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	s.l = l
+
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		wg.Add(1)
+		go func(conn net.PacketConn) {
+			defer wg.Done()
+			s.serve(l, conn)
+		}(conn)
+	}
+
+	go func() {
+		wg.Wait()
+		s.close(l)
+		l.cancel()
+
+		s.mtx.Lock()
+		s.l = nil
+		s.mtx.Unlock()
+
+		close(l.done)
+	}()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.stop()
+			s.close(l)
+		case <-l.done:
+		}
+	}()
+
+	return nil
+}
+
+// listen opens the sockets for the server.
+func (s *Server) listen(ctx context.Context) ([]net.PacketConn, error) {
+	var lc net.ListenConfig
+	if len(s.addrs) > 0 {
+		conns := make([]net.PacketConn, 0, len(s.addrs))
+		for _, addr := range s.addrs {
+			conn, err := lc.ListenPacket(ctx, s.network, addr)
+			if err != nil {
+				for _, c := range conns {
+					_ = c.Close()
+				}
+				return nil, err
+			}
+			conns = append(conns, conn)
+		}
+		return conns, nil
+	}
+
+	if !s.dualStack {
+		conn, err := lc.ListenPacket(ctx, s.network, s.addr)
+		if err != nil {
+			return nil, err
+		}
+		return []net.PacketConn{conn}, nil
+	}
+
+	host, _, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn4, err := lc.ListenPacket(ctx, "udp4", s.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the same port for both stacks, which matters if a dynamic port was requested.
+	port := strconv.Itoa(conn4.LocalAddr().(*net.UDPAddr).Port)
+	conn6, err := lc.ListenPacket(ctx, "udp6", net.JoinHostPort(host, port))
+	if err != nil {
+		_ = conn4.Close()
+		return nil, err
+	}
+
+	return []net.PacketConn{conn4, conn6}, nil
+}
+
+// Addr returns the address the server is listening on or nil if it is not started.
+// If the server is listening on multiple sockets the address of the first is returned.
+func (s *Server) Addr() net.Addr {
+	addrs := s.Addrs()
+	if len(addrs) == 0 {
+		return nil
+	}
+	return addrs[0]
+}
+
+// Addrs returns the addresses the server is listening on.
+func (s *Server) Addrs() []net.Addr {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.l == nil {
+		return nil
+	}
+
+	addrs := make([]net.Addr, len(s.l.conns))
+	for i, conn := range s.l.conns {
+		addrs[i] = conn.LocalAddr()
+	}
+	return addrs
+}
+
+// Shutdown gracefully shuts down the server. It stops reading new queries and
+// waits for the responses currently being processed to be sent, or ctx to be
+// done, before closing the sockets. If ctx is done first the construction of
+// in-flight responses is cancelled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mtx.Lock()
+	l := s.l
+	s.mtx.Unlock()
+
+	if l == nil {
+		return ErrNotStarted
+	}
+
+	l.stop()
+
+	// Unblock pending reads so no new queries are accepted.
+	for _, conn := range l.conns {
+		if err := conn.SetReadDeadline(time.Now()); err != nil {
+			s.close(l)
+			return err
+		}
+	}
+
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		s.close(l)
+		return ctx.Err()
+	}
+}
+
+// stop flags that the listener is shutting down.
+func (l *listener) stop() {
+	l.stopOnce.Do(func() {
+		close(l.stopping)
+	})
+}
+
+// isStopping returns true if the listener is shutting down.
+func (l *listener) isStopping() bool {
+	select {
+	case <-l.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// close closes the sockets of l.
+func (s *Server) close(l *listener) {
+	l.stop()
+	l.closeOnce.Do(func() {
+		for _, conn := range l.conns {
+			if err := conn.Close(); err != nil {
+				s.logger.Println("close", err)
+			}
+		}
+	})
+}
+
+// serve processes queries received on conn until l is shutdown.
+func (s *Server) serve(l *listener, conn net.PacketConn) {
+	buf := make([]byte, readBufferSize)
+	for {
+		n, to, err := conn.ReadFrom(buf)
+		if err != nil {
+			if l.isStopping() {
+				return
+			}
+			s.logger.Println("read from udp", err)
+			continue
+		}
+
+		s.respond(l.ctx, conn, to, buf[:n])
+	}
+}
+
+// respond sends the response to query req to the client at addr.
+func (s *Server) respond(ctx context.Context, conn net.PacketConn, to net.Addr, req []byte) {
+	if s.respTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.respTimeout)
+		defer cancel()
+	}
+
+	pkts, err := s.packets(ctx, conn.LocalAddr().String(), to.String(), req)
+	if err != nil {
+		s.logger.Println("error responding to query", err)
+		return
+	}
+
+	if err = conn.SetWriteDeadline(time.Now().Add(s.writeTimeout)); err != nil {
+		s.logger.Println("error setting write deadline", err)
+		return
+	}
+
+	for _, pkt := range pkts {
+		if _, err = conn.WriteTo(pkt, to); err != nil {
+			s.logger.Println("error writing response", err)
+			return
+		}
+	}
+}
+
+// packets returns the response packets for query req received on local from
+// the client at addr.
+func (s *Server) packets(ctx context.Context, local, addr string, req []byte) ([][]byte, error) {
+	if lr, ok := s.responder.(common.LocalResponder); ok {
+		return lr.RespondLocal(ctx, local, addr, req)
+	}
+	return common.RespondPackets(ctx, s.responder, addr, req)
+}
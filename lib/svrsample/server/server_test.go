@@ -0,0 +1,409 @@
+package server
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	sqpsample "github.com/multiplay/go-svrquery/lib/svrsample/protocol/sqp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		name    string
+		options []Option
+		err     error
+	}{
+		{
+			name:    "protocol",
+			options: []Option{WithProtocol("sqp"), WithState(common.QueryState{})},
+		},
+		{
+			name:    "responder",
+			options: []Option{WithResponder(&mockResponder{})},
+		},
+		{
+			name: "no-responder",
+			err:  ErrNoResponder,
+		},
+		{
+			name:    "no-state",
+			options: []Option{WithProtocol("sqp")},
+			err:     ErrNoState,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := New(tc.options...)
+			if tc.err != nil {
+				require.Equal(t, tc.err, err)
+				require.Nil(t, s)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, s)
+		})
+	}
+
+	_, err := New(WithProtocol("my-protocol"), WithState(common.QueryState{}))
+	require.Error(t, err)
+}
+
+func TestServer(t *testing.T) {
+	var players int32
+	s, err := New(
+		WithAddress("127.0.0.1:0"),
+		WithProtocol("sqp"),
+		WithStateFunc(func() common.QueryState {
+			return common.QueryState{
+				CurrentPlayers: atomic.AddInt32(&players, 1),
+				MaxPlayers:     10,
+				ServerName:     "live",
+			}
+		}),
+	)
+	require.NoError(t, err)
+	require.Nil(t, s.Addr())
+
+	ctx := context.Background()
+	require.NoError(t, s.Start(ctx))
+	require.Equal(t, ErrStarted, s.Start(ctx))
+
+	c, err := svrquery.NewClient("sqp", s.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	for i := 1; i <= 2; i++ {
+		r, err := c.Query()
+		require.NoError(t, err)
+		qr := r.(*sqp.QueryResponse)
+		require.Equal(t, "live", qr.ServerInfo.ServerName)
+		require.Equal(t, uint16(i), qr.ServerInfo.CurrentPlayers)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	require.NoError(t, s.Shutdown(ctx))
+	require.Nil(t, s.Addr())
+	require.Equal(t, ErrNotStarted, s.Shutdown(ctx))
+}
+
+// gameState is a StateProvider updated by a game loop.
+type gameState struct {
+	mtx   sync.Mutex
+	state common.QueryState
+}
+
+func (g *gameState) Snapshot() common.QueryState {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.state
+}
+
+func (g *gameState) setMap(m string) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.state.Map = m
+}
+
+func TestServerStateProvider(t *testing.T) {
+	g := &gameState{state: common.QueryState{ServerName: "provider", Map: "first"}}
+	s, err := New(WithAddress("127.0.0.1:0"), WithProtocol("sqp"), WithStateProvider(g))
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	c, err := svrquery.NewClient("sqp", s.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	for _, m := range []string{"first", "second"} {
+		g.setMap(m)
+		r, err := c.Query()
+		require.NoError(t, err)
+		require.Equal(t, m, r.(*sqp.QueryResponse).ServerInfo.Map)
+	}
+}
+
+func TestServerRules(t *testing.T) {
+	s, err := New(
+		WithAddress("127.0.0.1:0"),
+		WithProtocol("sqp"),
+		WithState(common.QueryState{
+			Rules: common.Rules{
+				"mode":    "ctf",
+				"round":   3,
+				"ff":      true,
+				"gravity": 9.8,
+			},
+		}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	c, err := svrquery.NewClient("sqp", s.Addr().String(), svrquery.WithArg(sqp.ChunksArg, "info,rules"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	r, err := c.Query()
+	require.NoError(t, err)
+	qr := r.(*sqp.QueryResponse)
+	require.Equal(t, sqp.ServerInfo|sqp.ServerRules, qr.Chunks)
+	require.Len(t, qr.ServerRules.Rules, 4)
+	require.Equal(t, "ctf", qr.ServerRules.Rules["mode"].String())
+	require.Equal(t, uint64(3), qr.ServerRules.Rules["round"].Uint64())
+	require.Equal(t, byte(1), qr.ServerRules.Rules["ff"].Byte())
+	require.Equal(t, "9.8", qr.ServerRules.Rules["gravity"].String())
+}
+
+func TestServerPlayers(t *testing.T) {
+	players := []common.Player{
+		{Name: "alice", Score: 12, Team: 1, Connected: time.Minute},
+		{Name: "bob", Score: -3, Connected: time.Second * 5},
+	}
+	s, err := New(
+		WithAddress("127.0.0.1:0"),
+		WithProtocol("sqp"),
+		WithState(common.QueryState{CurrentPlayers: 2, Players: players}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	c, err := svrquery.NewClient("sqp", s.Addr().String(), svrquery.WithArg(sqp.ChunksArg, "info,players"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	r, err := c.Query()
+	require.NoError(t, err)
+	qr := r.(*sqp.QueryResponse)
+	require.Equal(t, sqp.ServerInfo|sqp.PlayerInfo, qr.Chunks)
+	require.Equal(t, []sqp.Player{
+		{Name: "alice", Score: 12, Team: 1, Connected: time.Minute},
+		{Name: "bob", Score: -3, Connected: time.Second * 5},
+	}, qr.PlayerInfo.Roster())
+}
+
+func TestServerLenient(t *testing.T) {
+	r, err := sqpsample.NewQueryResponder(common.QueryState{ServerName: "lenient"}, sqpsample.WithCompatibility(sqpsample.Lenient))
+	require.NoError(t, err)
+
+	s, err := New(WithAddress("127.0.0.1:0"), WithResponder(r))
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	c, err := svrquery.NewClient("sqp", s.Addr().String(), svrquery.WithArg(sqp.ChunksArg, "info,teams"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	resp, err := c.Query()
+	require.NoError(t, err)
+	qr := resp.(*sqp.QueryResponse)
+	require.Equal(t, "lenient", qr.ServerInfo.ServerName)
+	require.Equal(t, sqp.TeamInfo, qr.IgnoredChunks())
+}
+
+func TestServerMultiPacket(t *testing.T) {
+	rules := make(common.Rules)
+	for i := 0; i < 50; i++ {
+		rules["rule"+strconv.Itoa(i)] = i
+	}
+	r, err := sqpsample.NewQueryResponder(common.QueryState{ServerName: "multi", Rules: rules}, sqpsample.WithMaxPacketSize(256))
+	require.NoError(t, err)
+
+	s, err := New(WithAddress("127.0.0.1:0"), WithResponder(r))
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	c, err := svrquery.NewClient("sqp", s.Addr().String(), svrquery.WithArg(sqp.ChunksArg, "info,rules"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	res, err := sqp.ProbeMTU(c)
+	require.NoError(t, err)
+	require.False(t, res.Blackhole)
+	require.Equal(t, 256, res.MaxPacket)
+	require.True(t, res.Steps[1].Packets > 2)
+
+	resp, err := c.Query()
+	require.NoError(t, err)
+	qr := resp.(*sqp.QueryResponse)
+	require.Equal(t, "multi", qr.ServerInfo.ServerName)
+	require.Len(t, qr.ServerRules.Rules, 50)
+}
+
+func TestServerPadding(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []sqpsample.Option
+	}{
+		{name: "single", options: []sqpsample.Option{sqpsample.WithPadding(1200)}},
+		{name: "multi", options: []sqpsample.Option{sqpsample.WithMaxPacketSize(256), sqpsample.WithPadding(256)}},
+	}
+
+	rules := make(common.Rules)
+	for i := 0; i < 20; i++ {
+		rules["rule"+strconv.Itoa(i)] = i
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := sqpsample.NewQueryResponder(common.QueryState{ServerName: "padded", Rules: rules}, tc.options...)
+			require.NoError(t, err)
+
+			s, err := New(WithAddress("127.0.0.1:0"), WithResponder(r))
+			require.NoError(t, err)
+			require.NoError(t, s.Start(context.Background()))
+			defer s.Shutdown(context.Background())
+
+			c, err := svrquery.NewClient("sqp", s.Addr().String(), svrquery.WithArg(sqp.ChunksArg, "info,rules"))
+			require.NoError(t, err)
+			defer c.Close()
+
+			// Repeated queries ensure the padding isn't read as the following
+			// challenge response.
+			for i := 0; i < 3; i++ {
+				resp, err := c.Query()
+				require.NoError(t, err)
+				qr := resp.(*sqp.QueryResponse)
+				require.Equal(t, "padded", qr.ServerInfo.ServerName)
+				require.Len(t, qr.ServerRules.Rules, 20)
+			}
+		})
+	}
+}
+
+func TestServerContextCancel(t *testing.T) {
+	s, err := New(WithAddress("127.0.0.1:0"), WithResponder(&mockResponder{}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, s.Start(ctx))
+	cancel()
+
+	require.Eventually(t, func() bool {
+		return s.Addr() == nil
+	}, time.Second, time.Millisecond*10)
+
+	// Server can be restarted once stopped.
+	require.NoError(t, s.Start(context.Background()))
+	require.NoError(t, s.Shutdown(context.Background()))
+}
+
+func TestServerResponseTimeout(t *testing.T) {
+	var calls int32
+	s, err := New(
+		WithAddress("127.0.0.1:0"),
+		WithProtocol("sqp"),
+		WithResponseTimeout(time.Millisecond*10),
+		WithStateContextFunc(func(ctx context.Context) (common.QueryState, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				<-ctx.Done()
+				return common.QueryState{}, ctx.Err()
+			}
+			return common.QueryState{ServerName: "slow"}, nil
+		}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	c, err := svrquery.NewClient("sqp", s.Addr().String(), svrquery.WithTimeout(time.Millisecond*500))
+	require.NoError(t, err)
+	defer c.Close()
+
+	// The first query is dropped as the state wasn't available in time.
+	_, err = c.Query()
+	require.Error(t, err)
+
+	r, err := c.Query()
+	require.NoError(t, err)
+	require.Equal(t, "slow", r.(*sqp.QueryResponse).ServerInfo.ServerName)
+}
+
+func TestServerShutdownCancelsResponse(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	s, err := New(
+		WithAddress("127.0.0.1:0"),
+		WithProtocol("sqp"),
+		WithStateContextFunc(func(ctx context.Context) (common.QueryState, error) {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return common.QueryState{}, ctx.Err()
+		}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+
+	c, err := svrquery.NewClient("sqp", s.Addr().String(), svrquery.WithTimeout(time.Second))
+	require.NoError(t, err)
+	defer c.Close()
+
+	go func() { _, _ = c.Query() }()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, s.Shutdown(ctx))
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("in-flight response not cancelled")
+	}
+}
+
+func TestServerDualStack(t *testing.T) {
+	if pc, err := net.ListenPacket("udp6", "[::1]:0"); err != nil {
+		t.Skip("ipv6 not available:", err)
+	} else {
+		pc.Close()
+	}
+
+	s, err := New(
+		WithAddress(":0"),
+		WithDualStack(),
+		WithProtocol("sqp"),
+		WithState(common.QueryState{ServerName: "dual"}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Shutdown(context.Background())
+
+	addrs := s.Addrs()
+	require.Len(t, addrs, 2)
+	port := strconv.Itoa(addrs[0].(*net.UDPAddr).Port)
+	require.Equal(t, port, strconv.Itoa(addrs[1].(*net.UDPAddr).Port))
+
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		t.Run(host, func(t *testing.T) {
+			c, err := svrquery.NewClient("sqp", net.JoinHostPort(host, port))
+			require.NoError(t, err)
+			defer c.Close()
+
+			r, err := c.Query()
+			require.NoError(t, err)
+			require.Equal(t, "dual", r.(*sqp.QueryResponse).ServerInfo.ServerName)
+		})
+	}
+}
+
+type mockResponder struct{}
+
+func (m *mockResponder) Respond(clientAddress string, buf []byte) ([]byte, error) {
+	return buf, nil
+}
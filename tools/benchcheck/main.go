@@ -0,0 +1,208 @@
+// Command benchcheck compares the results of benchmarks against a stored
+// baseline and fails if any allocate more, so regressions in protocol decode
+// paths are caught before merging. Both the results and the baseline are the
+// output of go test -bench, which is also the format used by benchstat:
+//
+//	go test -run '^$' -bench . -benchmem -count 5 ./lib/svrquery/protocol/... | go run ./tools/benchcheck
+//
+// The baseline is updated by saving the output of the same command to it.
+//
+// Timings vary between machines, so ns/op is only reported unless -same-runner
+// is given, for use with a baseline recorded by benchmarking the merge-base on
+// the same machine, in which case benchmarks which became significantly slower
+// also fail.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// procsSuffix matches the GOMAXPROCS suffix go test adds to benchmark names.
+var procsSuffix = regexp.MustCompile(`-\d+$`)
+
+// benchmark are the results of each run of a benchmark.
+type benchmark struct {
+	ns     []float64
+	bytes  []float64
+	allocs []float64
+}
+
+// limits are the regressions permitted by a comparison.
+type limits struct {
+	// Slowdown is the max fractional increase in ns/op, only checked if
+	// Timing is true.
+	Slowdown float64
+
+	// Timing is true if the baseline was recorded on the same machine, so
+	// ns/op can be compared.
+	Timing bool
+
+	// Growth is the max fractional increase in B/op, allocs/op may never
+	// increase.
+	Growth float64
+}
+
+func main() {
+	baseline := flag.String("baseline", "tools/benchcheck/baseline.txt", "File containing the baseline go test -bench output")
+	var l limits
+	flag.Float64Var(&l.Slowdown, "max-slowdown", 0.3, "Max fractional increase in ns/op, only checked with -same-runner")
+	flag.BoolVar(&l.Timing, "same-runner", false, "The baseline was recorded on this machine e.g. from the merge-base, so ns/op is also checked")
+	flag.Float64Var(&l.Growth, "max-growth", 0.1, "Max fractional increase in B/op")
+	flag.Parse()
+
+	lg := log.New(os.Stderr, "", 0)
+	f, err := os.Open(*baseline)
+	if err != nil {
+		lg.Fatal(err)
+	}
+	defer f.Close()
+
+	base, err := parse(f)
+	if err != nil {
+		lg.Fatalf("baseline: %v", err)
+	}
+
+	cur, err := parse(os.Stdin)
+	if err != nil {
+		lg.Fatal(err)
+	} else if len(cur) == 0 {
+		lg.Fatal("no benchmark results")
+	}
+
+	regressions, err := compare(os.Stdout, base, cur, l)
+	if err != nil {
+		lg.Fatal(err)
+	}
+
+	if !l.Timing {
+		fmt.Println("\nns/op is informational as the baseline may be from another machine, see -same-runner.")
+	}
+
+	if len(regressions) > 0 {
+		fmt.Printf("\n%d regression(s):\n", len(regressions))
+		for _, r := range regressions {
+			fmt.Println(r)
+		}
+		os.Exit(1)
+	}
+}
+
+// parse parses the output of go test -bench from r, returning the results of
+// each benchmark keyed by package and name.
+func parse(r io.Reader) (map[string]*benchmark, error) {
+	benchmarks := make(map[string]*benchmark)
+	var pkg string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "pkg: ") {
+			pkg = strings.TrimPrefix(line, "pkg: ")
+			continue
+		} else if !strings.HasPrefix(line, "Benchmark") {
+			continue
+		}
+
+		// Name, iterations and then pairs of value and unit.
+		fields := strings.Fields(line)
+		if len(fields) < 4 || len(fields)%2 != 0 {
+			continue
+		}
+
+		name := procsSuffix.ReplaceAllString(fields[0], "")
+		if pkg != "" {
+			name = pkg + "." + name
+		}
+		b, ok := benchmarks[name]
+		if !ok {
+			b = &benchmark{}
+			benchmarks[name] = b
+		}
+
+		for i := 2; i < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid %s value %q", name, fields[i+1], fields[i])
+			}
+
+			switch fields[i+1] {
+			case "ns/op":
+				b.ns = append(b.ns, v)
+			case "B/op":
+				b.bytes = append(b.bytes, v)
+			case "allocs/op":
+				b.allocs = append(b.allocs, v)
+			}
+		}
+	}
+
+	return benchmarks, s.Err()
+}
+
+// compare writes a comparison of the median results of cur against base to w
+// and returns the regressions which exceed l. Benchmarks which aren't in base
+// are reported but can't regress.
+func compare(w io.Writer, base, cur map[string]*benchmark, l limits) ([]string, error) {
+	names := make([]string, 0, len(cur))
+	for name := range cur {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "name\tns/op\tdelta\tB/op\tallocs/op")
+
+	var regressions []string
+	for _, name := range names {
+		c := cur[name]
+		b, ok := base[name]
+		if !ok {
+			fmt.Fprintf(tw, "%s\t%.0f\tnew\t%.0f\t%.0f\n", name, median(c.ns), median(c.bytes), median(c.allocs))
+			continue
+		}
+
+		bns, cns := median(b.ns), median(c.ns)
+		delta := "~"
+		if bns > 0 {
+			delta = fmt.Sprintf("%+.1f%%", (cns-bns)*100/bns)
+		}
+		fmt.Fprintf(tw, "%s\t%.0f -> %.0f\t%s\t%.0f -> %.0f\t%.0f -> %.0f\n", name,
+			bns, cns, delta, median(b.bytes), median(c.bytes), median(b.allocs), median(c.allocs))
+
+		if l.Timing && bns > 0 && cns > bns*(1+l.Slowdown) {
+			regressions = append(regressions, fmt.Sprintf("%s: %.0f ns/op exceeds baseline %.0f by more than %.0f%%", name, cns, bns, l.Slowdown*100))
+		}
+		if bb, cb := median(b.bytes), median(c.bytes); cb > bb*(1+l.Growth) {
+			regressions = append(regressions, fmt.Sprintf("%s: %.0f B/op exceeds baseline %.0f by more than %.0f%%", name, cb, bb, l.Growth*100))
+		}
+		if ba, ca := median(b.allocs), median(c.allocs); ca > ba {
+			regressions = append(regressions, fmt.Sprintf("%s: %.0f allocs/op exceeds baseline %.0f", name, ca, ba))
+		}
+	}
+
+	return regressions, tw.Flush()
+}
+
+// median returns the median of v, which is less affected by noisy runs than
+// the mean, or zero if v is empty.
+func median(v []float64) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+
+	s := append([]float64(nil), v...)
+	sort.Float64s(s)
+	if len(s)%2 == 1 {
+		return s[len(s)/2]
+	}
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const baseline = `goos: linux
+goarch: amd64
+pkg: github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp
+BenchmarkQuery/info-8    	 1000000	      1000 ns/op	     448 B/op	      30 allocs/op
+BenchmarkQuery/info-8    	 1000000	      1100 ns/op	     448 B/op	      30 allocs/op
+BenchmarkQuery/info-8    	 1000000	      5000 ns/op	     448 B/op	      30 allocs/op
+BenchmarkQuery/rules-8   	  500000	      2000 ns/op	     848 B/op	      54 allocs/op
+PASS
+ok  	github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp	15.506s
+`
+
+func TestParse(t *testing.T) {
+	b, err := parse(strings.NewReader(baseline))
+	require.NoError(t, err)
+	require.Len(t, b, 2)
+
+	info := b["github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp.BenchmarkQuery/info"]
+	require.NotNil(t, info)
+	require.Equal(t, []float64{1000, 1100, 5000}, info.ns)
+	require.Equal(t, []float64{448, 448, 448}, info.bytes)
+	require.Equal(t, []float64{30, 30, 30}, info.allocs)
+
+	// The outlier doesn't affect the median.
+	require.Equal(t, float64(1100), median(info.ns))
+	require.Equal(t, float64(1050), median(info.ns[:2]))
+	require.Zero(t, median(nil))
+
+	_, err = parse(strings.NewReader("BenchmarkQuery 100 fast ns/op"))
+	require.Error(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	base, err := parse(strings.NewReader(baseline))
+	require.NoError(t, err)
+	tests := []struct {
+		name        string
+		results     string
+		timing      bool
+		regressions int
+	}{
+		{
+			name: "unchanged",
+			results: `pkg: github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp
+BenchmarkQuery/info	1000000	1200 ns/op	448 B/op	30 allocs/op
+BenchmarkQuery/rules	500000	1900 ns/op	848 B/op	54 allocs/op`,
+		},
+		{
+			name: "slower",
+			results: `pkg: github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp
+BenchmarkQuery/info-4	1000000	1500 ns/op	448 B/op	30 allocs/op`,
+			timing:      true,
+			regressions: 1,
+		},
+		{
+			name: "slower-other-runner",
+			results: `pkg: github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp
+BenchmarkQuery/info-4	1000000	1500 ns/op	448 B/op	30 allocs/op`,
+		},
+		{
+			name: "allocs",
+			results: `pkg: github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp
+BenchmarkQuery/rules	500000	2000 ns/op	1000 B/op	55 allocs/op`,
+			regressions: 2,
+		},
+		{
+			name: "new",
+			results: `pkg: github.com/multiplay/go-svrquery/lib/svrquery/protocol/sqp
+BenchmarkQuery/team	500000	9000 ns/op	2000 B/op	90 allocs/op`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cur, err := parse(strings.NewReader(tc.results))
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			regressions, err := compare(&buf, base, cur, limits{Slowdown: 0.3, Growth: 0.1, Timing: tc.timing})
+			require.NoError(t, err)
+			require.Len(t, regressions, tc.regressions, regressions)
+			require.Contains(t, buf.String(), "BenchmarkQuery/")
+		})
+	}
+}
@@ -0,0 +1,17 @@
+#!/bin/sh
+# Benchmarks the merge-base of HEAD and the given branch, default master, on
+# this machine and checks the results of HEAD against it, including ns/op
+# which can't be compared to a baseline recorded on another machine.
+set -e
+
+branch=${1:-master}
+git fetch -q origin "$branch"
+base=$(git merge-base HEAD FETCH_HEAD)
+
+dir=$(mktemp -d)
+trap 'git worktree remove --force "$dir"; rm -f "$dir.txt"' EXIT
+git worktree add -q --detach "$dir" "$base"
+
+bench="go test -run ^$ -bench . -benchmem -count 5 ./lib/svrquery/protocol/..."
+(cd "$dir" && $bench) > "$dir.txt"
+$bench | go run ./tools/benchcheck -baseline "$dir.txt" -same-runner
@@ -0,0 +1,106 @@
+// Command execsign generates keys for, and signs, adapters of the exec
+// protocol so they can be verified by exec.SetPolicy.
+//
+//	go run ./tools/execsign -genkey -key adapter.key
+//	go run ./tools/execsign -key adapter.key /usr/local/bin/my-adapter
+//
+// Generating a key prints its public key, which is trusted by the CLI when in
+// $SVRQUERY_EXEC_TRUSTED_KEYS. Signing writes the signature of each adapter to
+// a file named after it with the exec.SignatureSuffix.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol/exec"
+)
+
+func main() {
+	keyFile := flag.String("key", "", "File containing the private key")
+	genKey := flag.Bool("genkey", false, "Generate a new private key, writing it to -key and printing the public key")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -genkey -key <file>\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s -key <file> <adapter>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *keyFile == "" || *genKey == (flag.NArg() > 0) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	l := log.New(os.Stderr, "", 0)
+	if *genKey {
+		pub, err := generateKey(*keyFile)
+		if err != nil {
+			l.Fatal(err)
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(pub))
+		return
+	}
+
+	key, err := readKey(*keyFile)
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	for _, a := range flag.Args() {
+		if err := sign(key, a); err != nil {
+			l.Fatal(err)
+		}
+	}
+}
+
+// generateKey writes a new private key to file, which mustn't exist, and
+// returns its public key.
+func generateKey(file string) (ed25519.PublicKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = fmt.Fprintln(f, base64.StdEncoding.EncodeToString(priv)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return pub, f.Close()
+}
+
+// readKey reads the private key from file.
+func readKey(file string) (ed25519.PrivateKey, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	} else if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%s: invalid private key size %d", file, len(key))
+	}
+	return key, nil
+}
+
+// sign writes the signature of adapter by key alongside it.
+func sign(key ed25519.PrivateKey, adapter string) error {
+	b, err := ioutil.ReadFile(adapter)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(adapter+exec.SignatureSuffix, exec.Sign(key, b), 0644)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	dir, err := ioutil.TempDir("", "execsign")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "adapter.key")
+	pub, err := generateKey(keyFile)
+	require.NoError(t, err)
+
+	// Existing keys aren't overwritten.
+	_, err = generateKey(keyFile)
+	require.Error(t, err)
+
+	key, err := readKey(keyFile)
+	require.NoError(t, err)
+	require.Equal(t, pub, key.Public())
+
+	adapter := filepath.Join(dir, "adapter")
+	require.NoError(t, ioutil.WriteFile(adapter, []byte("#!/bin/sh\n"), 0700))
+	require.NoError(t, sign(key, adapter))
+
+	b, err := ioutil.ReadFile(adapter + ".sig")
+	require.NoError(t, err)
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	require.NoError(t, err)
+	require.Len(t, sig, ed25519.SignatureSize)
+
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte("c2hvcnQ=\n"), 0600))
+	_, err = readKey(keyFile)
+	require.Error(t, err)
+}
@@ -0,0 +1,156 @@
+// Command newprotocol generates the skeleton of a new protocol, including its
+// client, tests, fixtures and registration, and optionally a sample responder.
+//
+//	go run ./tools/newprotocol -name mygame [-responder]
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// module is the import path of the repository.
+const module = "github.com/multiplay/go-svrquery"
+
+var (
+	// validName matches valid protocol names, which are also package names.
+	validName = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+	// errNoImports is returned if the all package has no import block.
+	errNoImports = errors.New("no import block")
+)
+
+// file is a file generated from a template.
+type file struct {
+	path string
+	tmpl string
+}
+
+// data is the data templates are executed with.
+type data struct {
+	Name   string
+	Module string
+}
+
+func main() {
+	name := flag.String("name", "", "Name of the protocol, which must be a valid package name e.g. mygame")
+	responder := flag.Bool("responder", false, "Also generate a sample responder in lib/svrsample")
+	root := flag.String("root", ".", "Root directory of the repository")
+	flag.Parse()
+
+	l := log.New(os.Stderr, "", 0)
+	if *name == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	files, err := generate(*root, *name, *responder)
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	for _, f := range files {
+		fmt.Println("created", f)
+	}
+	fmt.Println("registered in lib/svrquery/protocol/all/all.go")
+	if *responder {
+		fmt.Printf("add %q to the switches in lib/svrsample/query.go to use the responder\n", *name)
+	}
+}
+
+// generate writes the skeleton of protocol name to root and registers it,
+// returning the files created.
+func generate(root, name string, responder bool) ([]string, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid protocol name %q", name)
+	}
+
+	dirs := []string{filepath.Join(root, "lib", "svrquery", "protocol", name)}
+	files := append([]file{}, clientFiles...)
+	if responder {
+		dirs = append(dirs, filepath.Join(root, "lib", "svrsample", "protocol", name))
+		files = append(files, responderFiles...)
+	}
+
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); err == nil {
+			return nil, fmt.Errorf("%s already exists", dir)
+		}
+	}
+
+	d := data{Name: name, Module: module}
+	created := make([]string, 0, len(files))
+	for _, f := range files {
+		path, err := write(root, f, d)
+		if err != nil {
+			return created, err
+		}
+		created = append(created, path)
+	}
+
+	return created, register(filepath.Join(root, "lib", "svrquery", "protocol", "all", "all.go"), name)
+}
+
+// write executes the path and template of f with d and writes the result
+// below root, formatting Go source files.
+func write(root string, f file, d data) (string, error) {
+	var path bytes.Buffer
+	if err := template.Must(template.New("path").Parse(f.path)).Execute(&path, d); err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	if err := template.Must(template.New(f.path).Parse(f.tmpl)).Execute(&b, d); err != nil {
+		return "", err
+	}
+
+	src := b.Bytes()
+	if strings.HasSuffix(path.String(), ".go") {
+		var err error
+		if src, err = format.Source(src); err != nil {
+			return "", fmt.Errorf("%s: %w", path.String(), err)
+		}
+	}
+
+	p := filepath.Join(root, filepath.FromSlash(path.String()))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+	return p, ioutil.WriteFile(p, src, 0644)
+}
+
+// register adds an import of protocol name to the all package at path.
+func register(path, name string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	i := bytes.Index(b, []byte("import ("))
+	if i == -1 {
+		return fmt.Errorf("%s: %w", path, errNoImports)
+	}
+	end := bytes.IndexByte(b[i:], ')')
+	if end == -1 {
+		return fmt.Errorf("%s: %w", path, errNoImports)
+	}
+	end += i
+
+	imp := fmt.Sprintf("\t_ %q\n", module+"/lib/svrquery/protocol/"+name)
+	src := append(append(append([]byte{}, b[:end]...), imp...), b[end:]...)
+	if src, err = format.Source(src); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return ioutil.WriteFile(path, src, 0644)
+}
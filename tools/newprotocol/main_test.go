@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	root, err := ioutil.TempDir("", "newprotocol")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	all := filepath.Join(root, "lib", "svrquery", "protocol", "all", "all.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(all), 0755))
+	src, err := ioutil.ReadFile(filepath.Join("..", "..", "lib", "svrquery", "protocol", "all", "all.go"))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(all, src, 0644))
+
+	files, err := generate(root, "mygame", true)
+	require.NoError(t, err)
+	require.Len(t, files, len(clientFiles)+len(responderFiles))
+	for _, f := range files {
+		require.FileExists(t, f)
+	}
+
+	b, err := ioutil.ReadFile(all)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `_ "github.com/multiplay/go-svrquery/lib/svrquery/protocol/mygame"`)
+
+	// Existing protocols aren't overwritten.
+	_, err = generate(root, "mygame", false)
+	require.Error(t, err)
+
+	_, err = generate(root, "My-Game", false)
+	require.Error(t, err)
+}
@@ -0,0 +1,208 @@
+package main
+
+// clientFiles are the files of the client implementation of a protocol.
+var clientFiles = []file{
+	{path: "lib/svrquery/protocol/{{.Name}}/doc.go", tmpl: clientDoc},
+	{path: "lib/svrquery/protocol/{{.Name}}/register.go", tmpl: clientRegister},
+	{path: "lib/svrquery/protocol/{{.Name}}/consts.go", tmpl: clientConsts},
+	{path: "lib/svrquery/protocol/{{.Name}}/types.go", tmpl: clientTypes},
+	{path: "lib/svrquery/protocol/{{.Name}}/query.go", tmpl: clientQuery},
+	{path: "lib/svrquery/protocol/{{.Name}}/query_test.go", tmpl: clientQueryTest},
+	{path: "lib/svrquery/protocol/{{.Name}}/testdata/response", tmpl: fixture},
+}
+
+// responderFiles are the files of the sample responder of a protocol.
+var responderFiles = []file{
+	{path: "lib/svrsample/protocol/{{.Name}}/{{.Name}}.go", tmpl: responder},
+	{path: "lib/svrsample/protocol/{{.Name}}/{{.Name}}_test.go", tmpl: responderTest},
+}
+
+// fixture is an example response to the example request.
+const fixture = "\xff\xff\xff\xffR\x02\x08harbour\x00"
+
+const clientDoc = `// Package {{.Name}} provides the protocol implementation for {{.Name}}.
+package {{.Name}}
+`
+
+const clientRegister = `package {{.Name}}
+
+import (
+	"{{.Module}}/lib/svrquery/protocol"
+)
+
+func init() {
+	protocol.MustRegisterChecked("{{.Name}}", newCreator)
+}
+`
+
+const clientConsts = `package {{.Name}}
+
+// TODO: replace the example wire format with that of {{.Name}}.
+var (
+	// RequestPacket is the query request packet.
+	RequestPacket = []byte{0xFF, 0xFF, 0xFF, 0xFF, 'Q'}
+
+	// ResponseHeader is the header of a query response packet.
+	ResponseHeader = []byte{0xFF, 0xFF, 0xFF, 0xFF, 'R'}
+)
+`
+
+const clientTypes = `package {{.Name}}
+
+// Response is the response to a query.
+type Response struct {
+	Players    uint8  ` + "`json:\"num_clients\"`" + `
+	MaxPlayers uint8  ` + "`json:\"max_clients\"`" + `
+	Map        string ` + "`json:\"map\"`" + `
+}
+
+// NumClients implements protocol.Responser.
+func (r *Response) NumClients() int64 {
+	return int64(r.Players)
+}
+
+// MaxClients implements protocol.Responser.
+func (r *Response) MaxClients() int64 {
+	return int64(r.MaxPlayers)
+}
+
+// MapName implements protocol.Mapper.
+func (r *Response) MapName() string {
+	return r.Map
+}
+`
+
+const clientQuery = `package {{.Name}}
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"{{.Module}}/lib/svrquery/common"
+	"{{.Module}}/lib/svrquery/protocol"
+)
+
+type queryer struct {
+	c protocol.Client
+}
+
+func newCreator(c protocol.Client) (protocol.Queryer, error) {
+	return &queryer{c: c}, nil
+}
+
+// Query implements protocol.Queryer.
+func (q *queryer) Query() (protocol.Responser, error) {
+	return q.QueryContext(protocol.NewQueryContext(context.Background(), 0, nil))
+}
+
+// QueryContext implements protocol.ContextQueryer.
+func (q *queryer) QueryContext(qc *protocol.QueryContext) (protocol.Responser, error) {
+	if _, err := q.c.Write(RequestPacket); err != nil {
+		return nil, err
+	}
+
+	n, err := q.c.Read(qc.Buf)
+	if err != nil {
+		return nil, err
+	} else if !bytes.HasPrefix(qc.Buf[:n], ResponseHeader) {
+		return nil, fmt.Errorf("unexpected response header % x", qc.Buf[:n])
+	}
+
+	r := common.NewBinaryReader(qc.Buf[len(ResponseHeader):n], binary.LittleEndian)
+	resp := &Response{}
+	if err = r.Read(&resp.Players); err != nil {
+		return nil, err
+	} else if err = r.Read(&resp.MaxPlayers); err != nil {
+		return nil, err
+	} else if resp.Map, err = r.ReadString(); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+`
+
+const clientQueryTest = `package {{.Name}}
+
+import (
+	"testing"
+
+	"{{.Module}}/lib/svrquery/clienttest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery(t *testing.T) {
+	mc := &clienttest.MockClient{}
+	mc.On("Write", RequestPacket).Return(len(RequestPacket), nil)
+	mc.On("Read", mock.Anything).Return(clienttest.LoadData(t, "testdata", "response"), nil)
+
+	q, err := newCreator(mc)
+	require.NoError(t, err)
+
+	r, err := q.Query()
+	require.NoError(t, err)
+	require.Equal(t, &Response{Players: 2, MaxPlayers: 8, Map: "harbour"}, r)
+}
+`
+
+const responder = `package {{.Name}}
+
+import (
+	"bytes"
+	"errors"
+
+	"{{.Module}}/lib/svrquery/protocol/{{.Name}}"
+	"{{.Module}}/lib/svrsample/common"
+)
+
+// ErrUnknownRequest is returned when a request isn't a query.
+var ErrUnknownRequest = errors.New("unknown request")
+
+// QueryResponder responds to queries using the {{.Name}} protocol.
+type QueryResponder struct {
+	state common.QueryState
+}
+
+// NewQueryResponder returns a QueryResponder which responds with state.
+func NewQueryResponder(state common.QueryState) (*QueryResponder, error) {
+	return &QueryResponder{state: state}, nil
+}
+
+// Respond implements common.QueryResponder.
+func (q *QueryResponder) Respond(clientAddress string, buf []byte) ([]byte, error) {
+	if !bytes.Equal(buf, {{.Name}}.RequestPacket) {
+		return nil, ErrUnknownRequest
+	}
+
+	b := append([]byte{}, {{.Name}}.ResponseHeader...)
+	b = append(b, byte(q.state.CurrentPlayers), byte(q.state.MaxPlayers))
+	b = append(b, q.state.Map...)
+	return append(b, 0), nil
+}
+`
+
+const responderTest = `package {{.Name}}
+
+import (
+	"testing"
+
+	"{{.Module}}/lib/svrquery/protocol/{{.Name}}"
+	"{{.Module}}/lib/svrsample/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRespond(t *testing.T) {
+	q, err := NewQueryResponder(common.QueryState{CurrentPlayers: 2, MaxPlayers: 8, Map: "harbour"})
+	require.NoError(t, err)
+
+	resp, err := q.Respond("", {{.Name}}.RequestPacket)
+	require.NoError(t, err)
+	require.Equal(t, []byte("\xff\xff\xff\xffR\x02\x08harbour\x00"), resp)
+
+	_, err = q.Respond("", []byte("ping"))
+	require.Error(t, err)
+}
+`
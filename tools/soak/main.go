@@ -0,0 +1,79 @@
+// Command soak runs a soak test of the client against a sample server, or
+// an external server, for a long period at a constant rate of queries. It
+// fails if any response can't be decoded or doesn't match the served state,
+// too many queries are lost, memory grows beyond a bound or latency drifts,
+// and is used to validate releases of both the client and sample server.
+//
+//	go run ./tools/soak -duration 4h -qps 500
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+)
+
+func main() {
+	cfg := defaultConfig()
+	flag.StringVar(&cfg.Protocol, "proto", cfg.Protocol, "Protocol to query with")
+	flag.StringVar(&cfg.Address, "addr", "", "Address of the server to query, by default an in-process sample server is started")
+	flag.DurationVar(&cfg.Duration, "duration", cfg.Duration, "Duration of the test")
+	flag.Float64Var(&cfg.QPS, "qps", cfg.QPS, "Queries per second")
+	flag.IntVar(&cfg.Clients, "clients", cfg.Clients, "Number of concurrent clients")
+	flag.DurationVar(&cfg.Window, "window", cfg.Window, "Interval at which results are reported and checked")
+	maxHeap := flag.Uint64("max-heap", cfg.MaxHeap>>20, "Max heap in use after garbage collection in MiB")
+	flag.Float64Var(&cfg.MaxLoss, "max-loss", cfg.MaxLoss, "Max fraction of queries in a window which may time out or fail to send")
+	flag.Float64Var(&cfg.MaxDrift, "max-latency-drift", cfg.MaxDrift, "Max ratio of the p99 latency of a window to that of the first window")
+	flag.DurationVar(&cfg.LatencyFloor, "latency-floor", cfg.LatencyFloor, "p99 latency below which drift is ignored")
+	flag.Int64Var(&cfg.Seed, "seed", cfg.Seed, "Seed for the state of the sample server")
+	flag.Parse()
+	cfg.MaxHeap = *maxHeap << 20
+
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		l.Print("Interrupted, stopping")
+		cancel()
+	}()
+
+	r, err := run(ctx, cfg, l)
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	if len(r.Failures) == 0 {
+		fmt.Printf("PASS %d queries in %d windows\n", r.Queries(), len(r.Windows))
+		return
+	}
+
+	fmt.Printf("FAIL %d queries in %d windows\n", r.Queries(), len(r.Windows))
+	for _, f := range r.Failures {
+		fmt.Println(f)
+	}
+	os.Exit(1)
+}
+
+// defaultConfig returns the default soak test configuration.
+func defaultConfig() config {
+	return config{
+		Protocol:     "sqp",
+		Duration:     time.Hour,
+		QPS:          100,
+		Clients:      4,
+		Window:       time.Minute,
+		MaxHeap:      256 << 20,
+		MaxLoss:      0.001,
+		MaxDrift:     3,
+		LatencyFloor: time.Millisecond * 2,
+		Seed:         1,
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/multiplay/go-svrquery/lib/svrquery"
+	"github.com/multiplay/go-svrquery/lib/svrquery/protocol"
+	"github.com/multiplay/go-svrquery/lib/svrsample/common"
+	"github.com/multiplay/go-svrquery/lib/svrsample/fake"
+	"github.com/multiplay/go-svrquery/lib/svrsample/server"
+)
+
+// config is the configuration of a soak test.
+type config struct {
+	// Protocol is the protocol to query with.
+	Protocol string
+
+	// Address is the address of the server to query, if empty an in-process
+	// sample server is started and responses are checked against its state.
+	Address string
+
+	Duration time.Duration
+	QPS      float64
+	Clients  int
+
+	// Window is the interval at which results are reported and checked.
+	Window time.Duration
+
+	// MaxHeap is the max bytes of heap in use after garbage collection.
+	MaxHeap uint64
+
+	// MaxLoss is the max fraction of queries in a window which may be lost.
+	MaxLoss float64
+
+	// MaxDrift is the max ratio of the p99 latency of a window to the p99
+	// latency of the first window. Windows whose p99 is below LatencyFloor
+	// are never considered to have drifted.
+	MaxDrift     float64
+	LatencyFloor time.Duration
+
+	// Seed is the seed for the state of the sample server.
+	Seed int64
+}
+
+// validate returns an error if c isn't valid.
+func (c config) validate() error {
+	switch {
+	case c.Duration <= 0:
+		return fmt.Errorf("duration %v must be positive", c.Duration)
+	case c.QPS <= 0:
+		return fmt.Errorf("qps %v must be positive", c.QPS)
+	case c.Clients < 1:
+		return fmt.Errorf("clients %d must be at least 1", c.Clients)
+	case c.Window <= 0:
+		return fmt.Errorf("window %v must be positive", c.Window)
+	case c.MaxDrift < 1:
+		return fmt.Errorf("max latency drift %v must be at least 1", c.MaxDrift)
+	}
+	return nil
+}
+
+// result is the result of a single query.
+type result struct {
+	latency time.Duration
+	resp    protocol.Responser
+	err     error
+}
+
+// window are the results of the queries in a reporting window.
+type window struct {
+	Queries      int
+	Lost         int
+	DecodeErrors int
+	Mismatches   int
+
+	// Overruns is the number of queries which weren't sent as all clients
+	// were busy, which indicates the target rate wasn't achieved.
+	Overruns int
+
+	P50  time.Duration
+	P99  time.Duration
+	Heap uint64
+
+	// LastError is the last decode error or mismatch.
+	LastError string
+
+	latencies []time.Duration
+}
+
+// report are the results of a soak test.
+type report struct {
+	Windows  []window
+	Failures []string
+}
+
+// run runs the soak test configured by cfg until its duration has elapsed or
+// ctx is done, logging the results of each window to l.
+func run(ctx context.Context, cfg config, l *log.Logger) (*report, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	address := cfg.Address
+	var expected *common.QueryState
+	if address == "" {
+		g, err := fake.New(fake.WithSeed(cfg.Seed))
+		if err != nil {
+			return nil, err
+		}
+		state := g.State()
+
+		s, err := server.New(
+			server.WithAddress("127.0.0.1:0"),
+			server.WithProtocol(cfg.Protocol),
+			server.WithState(state),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = s.Start(ctx); err != nil {
+			return nil, err
+		}
+		defer s.Shutdown(context.Background())
+
+		address = s.Addr().String()
+		expected = &state
+	}
+
+	clients := make([]*svrquery.Client, cfg.Clients)
+	for i := range clients {
+		c, err := svrquery.NewClient(cfg.Protocol, address)
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+		clients[i] = c
+	}
+
+	l.Printf("Soaking %s %s at %v qps with %d clients for %v", cfg.Protocol, address, cfg.QPS, cfg.Clients, cfg.Duration)
+
+	// The test is ended by cancelling rather than a deadline, so the deadline
+	// of queries near the end of the test isn't shortened causing them to be
+	// reported as lost.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	end := time.AfterFunc(cfg.Duration, cancel)
+	defer end.Stop()
+
+	jobs := make(chan struct{}, len(clients))
+	results := make(chan result, len(clients))
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func(c *svrquery.Client) {
+			defer wg.Done()
+			for range jobs {
+				start := time.Now()
+				resp, err := c.QueryContext(ctx)
+				if ctx.Err() != nil {
+					// The test ended during the query.
+					continue
+				}
+				results <- result{latency: time.Since(start), resp: resp, err: err}
+			}
+		}(c)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	tick := time.NewTicker(time.Duration(float64(time.Second) / cfg.QPS))
+	defer tick.Stop()
+	windows := time.NewTicker(cfg.Window)
+	defer windows.Stop()
+
+	r := &report{}
+	w := &window{}
+	ticks, done := tick.C, ctx.Done()
+	for results != nil {
+		select {
+		case <-ticks:
+			select {
+			case jobs <- struct{}{}:
+			default:
+				w.Overruns++
+			}
+		case <-windows.C:
+			r.add(cfg, w, l)
+			w = &window{}
+		case <-done:
+			close(jobs)
+			ticks, done = nil, nil
+		case res, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			w.record(res, expected)
+		}
+	}
+
+	if w.Queries > 0 {
+		r.add(cfg, w, l)
+	}
+
+	return r, nil
+}
+
+// record records the result of a query in w, checking the response matches
+// expected if not nil.
+func (w *window) record(res result, expected *common.QueryState) {
+	w.Queries++
+
+	var ne net.Error
+	switch {
+	case errors.As(res.err, &ne):
+		w.Lost++
+	case res.err != nil:
+		w.DecodeErrors++
+		w.LastError = res.err.Error()
+	default:
+		w.latencies = append(w.latencies, res.latency)
+		if expected != nil {
+			if err := match(res.resp, expected); err != nil {
+				w.Mismatches++
+				w.LastError = err.Error()
+			}
+		}
+	}
+}
+
+// match returns an error if resp doesn't match the state.
+func match(resp protocol.Responser, state *common.QueryState) error {
+	if n := resp.NumClients(); n != int64(state.CurrentPlayers) {
+		return fmt.Errorf("num clients %d doesn't match %d", n, state.CurrentPlayers)
+	} else if n = resp.MaxClients(); n != int64(state.MaxPlayers) {
+		return fmt.Errorf("max clients %d doesn't match %d", n, state.MaxPlayers)
+	}
+
+	if m, ok := resp.(protocol.Mapper); ok && m.MapName() != state.Map {
+		return fmt.Errorf("map %q doesn't match %q", m.MapName(), state.Map)
+	}
+
+	return nil
+}
+
+// add completes w, checks it and adds it to r.
+func (r *report) add(cfg config, w *window, l *log.Logger) {
+	if len(w.latencies) > 0 {
+		sort.Slice(w.latencies, func(i, j int) bool { return w.latencies[i] < w.latencies[j] })
+		w.P50 = percentile(w.latencies, 50)
+		w.P99 = percentile(w.latencies, 99)
+		w.latencies = nil
+	}
+
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	w.Heap = ms.HeapAlloc
+
+	r.Windows = append(r.Windows, *w)
+	n := len(r.Windows)
+	l.Printf("Window %d: %d queries, p50 %v, p99 %v, lost %d, decode errors %d, mismatches %d, overruns %d, heap %.1fMiB",
+		n, w.Queries, w.P50, w.P99, w.Lost, w.DecodeErrors, w.Mismatches, w.Overruns, float64(w.Heap)/(1<<20))
+
+	for _, f := range r.Windows[0].check(cfg, *w) {
+		r.Failures = append(r.Failures, fmt.Sprintf("window %d: %s", n, f))
+	}
+}
+
+// check returns the reasons w fails the checks of cfg, where baseline is the
+// first window.
+func (baseline window) check(cfg config, w window) []string {
+	var failures []string
+	if w.DecodeErrors > 0 {
+		failures = append(failures, fmt.Sprintf("%d decode errors, last: %s", w.DecodeErrors, w.LastError))
+	}
+	if w.Mismatches > 0 {
+		failures = append(failures, fmt.Sprintf("%d mismatched responses, last: %s", w.Mismatches, w.LastError))
+	}
+	if w.Queries > 0 && float64(w.Lost)/float64(w.Queries) > cfg.MaxLoss {
+		failures = append(failures, fmt.Sprintf("lost %d of %d queries", w.Lost, w.Queries))
+	}
+	if w.Heap > cfg.MaxHeap {
+		failures = append(failures, fmt.Sprintf("heap %d bytes exceeds %d", w.Heap, cfg.MaxHeap))
+	}
+	if w.P99 > cfg.LatencyFloor && float64(w.P99) > float64(baseline.P99)*cfg.MaxDrift {
+		failures = append(failures, fmt.Sprintf("p99 latency %v drifted from %v", w.P99, baseline.P99))
+	}
+	return failures
+}
+
+// Queries returns the total number of queries.
+func (r *report) Queries() int {
+	var n int
+	for _, w := range r.Windows {
+		n += w.Queries
+	}
+	return n
+}
+
+// percentile returns the pth percentile of sorted using the nearest rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Duration = time.Millisecond * 500
+	cfg.Window = time.Millisecond * 200
+	cfg.QPS = 200
+	// Latency on shared test machines is too variable to check drift.
+	cfg.LatencyFloor = time.Second
+
+	r, err := run(context.Background(), cfg, log.New(ioutil.Discard, "", 0))
+	require.NoError(t, err)
+	require.Empty(t, r.Failures)
+	require.True(t, len(r.Windows) >= 2)
+	require.True(t, r.Queries() > 0)
+	for _, w := range r.Windows {
+		require.Zero(t, w.DecodeErrors)
+		require.Zero(t, w.Mismatches)
+	}
+
+	cfg.QPS = 0
+	_, err = run(context.Background(), cfg, log.New(ioutil.Discard, "", 0))
+	require.Error(t, err)
+}
+
+func TestWindowCheck(t *testing.T) {
+	cfg := defaultConfig()
+	baseline := window{Queries: 1000, P99: time.Millisecond * 3, Heap: 1 << 20}
+
+	tests := []struct {
+		name     string
+		w        window
+		failures int
+	}{
+		{name: "pass", w: baseline},
+		{name: "decode", w: window{Queries: 1000, DecodeErrors: 1, LastError: "malformed"}, failures: 1},
+		{name: "mismatch", w: window{Queries: 1000, Mismatches: 2}, failures: 1},
+		{name: "lost", w: window{Queries: 1000, Lost: 2}, failures: 1},
+		{name: "heap", w: window{Queries: 1000, Heap: cfg.MaxHeap + 1}, failures: 1},
+		{name: "drift", w: window{Queries: 1000, P99: time.Millisecond * 10}, failures: 1},
+		{name: "floor", w: window{Queries: 1000, P99: cfg.LatencyFloor}, failures: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Len(t, baseline.check(cfg, tc.w), tc.failures)
+		})
+	}
+}